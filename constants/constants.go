@@ -4,6 +4,8 @@ import (
 	"encoding/hex"
 	"os"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
@@ -23,12 +25,103 @@ func MustDecodeHexPrivateKey(k string) [64]byte {
 	return b
 }
 
-var PRICE price.Price
+func DurationFromEnv(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		panic("can't parse " + key + ": " + err.Error())
+	}
+	return d
+}
+
+func FloatFromEnv(key string, fallback float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		panic("can't parse " + key + ": " + err.Error())
+	}
+	return f
+}
+
+func poolsFromEnv(key string, fallback string) []price.Source {
+	v := os.Getenv(key)
+	if v == "" {
+		v = fallback
+	}
+	sources, err := price.ParsePools(RPC_CLIENT, v)
+	if err != nil {
+		panic("can't parse " + key + ": " + err.Error())
+	}
+	return sources
+}
+
 var RPC_CLIENT *rpc.Client = rpc.New(os.Getenv("RPC_URL"))
+
+// IVY/USDC pools to price IVY from, as ivy_vault:usdc_vault pairs separated
+// by commas. The price is the median of the pools that answer.
+var PRICE_POOLS []price.Source = poolsFromEnv("PRICE_POOLS", price.IVY_POOL.String()+":"+price.USDC_POOL.String())
+
+// How often the price is sampled
+var PRICE_POLL_INTERVAL time.Duration = DurationFromEnv("PRICE_POLL_INTERVAL", time.Minute)
+
+// Prices older than this aren't used to convert between IVY and USD
+var PRICE_MAX_AGE time.Duration = DurationFromEnv("PRICE_MAX_AGE", 5*time.Minute)
+
+// How long price samples are kept
+var PRICE_HISTORY_RETENTION time.Duration = DurationFromEnv("PRICE_HISTORY_RETENTION", 90*24*time.Hour)
+
+var PRICE *price.Oracle = price.New(PRICE_MAX_AGE, PRICE_POOLS...)
 var SPRITE_VAULT [32]byte = solana.MustPublicKeyFromBase58("AVXJfx8UsdkTPBL2UHuVDb3QVPvBw7P1sDH4fRXF1WiH")
-var WITHDRAW_AUTHORITY_KEY [64]byte = MustDecodeHexPrivateKey(os.Getenv("WITHDRAW_AUTHORITY_KEY"))
-var WITHDRAW_AUTHORITY [32]byte = solana.PrivateKey(WITHDRAW_AUTHORITY_KEY[:]).PublicKey()
-var WITHDRAW_AUTHORITY_B58 string = solana.PublicKey(WITHDRAW_AUTHORITY).String()
+
+// The key withdrawals are signed with is decoded on first use, so nothing
+// that never signs one has to configure it
+var withdrawAuthorityKey = sync.OnceValue(func() [64]byte {
+	return MustDecodeHexPrivateKey(os.Getenv("WITHDRAW_AUTHORITY_KEY"))
+})
+
+// WithdrawAuthorityKey returns the private key withdrawals are signed with
+func WithdrawAuthorityKey() [64]byte {
+	return withdrawAuthorityKey()
+}
+
+// WithdrawAuthorityB58 returns the public key withdrawals are signed with
+func WithdrawAuthorityB58() string {
+	key := WithdrawAuthorityKey()
+	return solana.PrivateKey(key[:]).PublicKey().String()
+}
+
+// How long the deposit watcher keeps polling an unpaid deposit
+var DEPOSIT_EXPIRY time.Duration = DurationFromEnv("DEPOSIT_EXPIRY", 24*time.Hour)
+
+// How often the deposit watcher polls pending deposits
+var DEPOSIT_POLL_INTERVAL time.Duration = DurationFromEnv("DEPOSIT_POLL_INTERVAL", 15*time.Second)
+
+// How often the withdrawal reconciler checks pending withdrawals
+var WITHDRAW_POLL_INTERVAL time.Duration = DurationFromEnv("WITHDRAW_POLL_INTERVAL", time.Minute)
+
+// How often the rain scheduler looks for due rains
+var RAIN_SCHEDULE_POLL_INTERVAL time.Duration = DurationFromEnv("RAIN_SCHEDULE_POLL_INTERVAL", time.Minute)
+
+// How often ended raffles are looked for and drawn
+var RAFFLE_DRAW_POLL_INTERVAL time.Duration = DurationFromEnv("RAFFLE_DRAW_POLL_INTERVAL", 15*time.Second)
+
+// How often unlocked vesting is released to its recipients
+var VESTING_RELEASE_POLL_INTERVAL time.Duration = DurationFromEnv("VESTING_RELEASE_POLL_INTERVAL", time.Minute)
+
+// Tips, rains and withdrawals worth at least this many USD must be confirmed
+var CONFIRM_THRESHOLD_USD float64 = FloatFromEnv("CONFIRM_THRESHOLD_USD", 50)
+
+// How long a confirmation prompt can be answered
+var CONFIRM_EXPIRY time.Duration = DurationFromEnv("CONFIRM_EXPIRY", 5*time.Minute)
+
+// A Telegram username not seen for this long may belong to someone else now
+var TELEGRAM_USERNAME_MAX_AGE time.Duration = DurationFromEnv("TELEGRAM_USERNAME_MAX_AGE", 30*24*time.Hour)
 
 const IVY_GREEN = 0x34D399
 const IVY_RED = 0xFF5000
@@ -60,7 +153,7 @@ const ACTIVITY_DELTA_MAX = 1200
 // After this time has passed between messages, activity is reset
 const ACTIVITY_DELTA_RESET = 1800
 
-// Violet's discord ID
+// Violet's discord ID, seeded as the first global admin
 const VIOLET_ID = "1348921951493554277"
 
 // The URL of the aggregator
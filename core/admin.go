@@ -0,0 +1,141 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// ErrForbidden means the caller lacks the role a command requires
+var ErrForbidden = errors.New("You don't have permission to do that")
+
+type RoleResult struct {
+	Scope  string
+	UserID string
+	Role   db.Role
+}
+
+// Authorize is the single permission check for privileged commands. It passes
+// if the caller holds at least role globally or within scope. Chat admins
+// according to the platform count as admins of their own chat.
+func Authorize(database db.Database, req Request, scope string, role db.Role) error {
+	if req.ChatAdmin && scope == req.ChatID && scope != "" {
+		return nil
+	}
+	scopes := []string{db.SCOPE_GLOBAL}
+	if scope != db.SCOPE_GLOBAL {
+		scopes = append(scopes, scope)
+	}
+	for _, s := range scopes {
+		held, err := database.GetRole(s, req.CallerID)
+		if err != nil {
+			return fmt.Errorf("Error checking permissions: %v", err)
+		}
+		if held.Includes(role) {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+// roleScope takes an optional "global" argument off args, defaulting to the
+// chat the command was sent in
+func roleScope(req Request, args []string) (string, []string, error) {
+	var rest []string
+	global := false
+	for _, arg := range args {
+		if arg == db.SCOPE_GLOBAL {
+			global = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	if global {
+		return db.SCOPE_GLOBAL, rest, nil
+	}
+	if req.Private {
+		return "", nil, errors.New("Run this in a server or group, or add global")
+	}
+	return req.ChatID, rest, nil
+}
+
+// GrantRole gives Mentions[0] a role: Args = [role?, "global"?]
+func GrantRole(database db.Database, req Request) (RoleResult, error) {
+	if len(req.Mentions) != 1 {
+		return RoleResult{}, ErrUsage
+	}
+	scope, args, err := roleScope(req, req.Args)
+	if err != nil {
+		return RoleResult{}, err
+	}
+	if len(args) > 1 {
+		return RoleResult{}, ErrUsage
+	}
+	role := db.ROLE_MODERATOR
+	if len(args) == 1 {
+		var ok bool
+		if role, ok = db.ParseRole(args[0]); !ok {
+			return RoleResult{}, fmt.Errorf("Unknown role `%s`", args[0])
+		}
+	}
+	if err := Authorize(database, req, scope, db.ROLE_ADMIN); err != nil {
+		return RoleResult{}, err
+	}
+
+	target := req.Mentions[0]
+	if err := database.GrantRole(scope, target, role, req.CallerID); err != nil {
+		return RoleResult{}, fmt.Errorf("Error granting role: %v", err)
+	}
+	return RoleResult{Scope: scope, UserID: target, Role: role}, nil
+}
+
+// RevokeRole removes Mentions[0]'s role: Args = ["global"?]
+func RevokeRole(database db.Database, req Request) (RoleResult, error) {
+	if len(req.Mentions) != 1 {
+		return RoleResult{}, ErrUsage
+	}
+	scope, args, err := roleScope(req, req.Args)
+	if err != nil {
+		return RoleResult{}, err
+	}
+	if len(args) != 0 {
+		return RoleResult{}, ErrUsage
+	}
+	if err := Authorize(database, req, scope, db.ROLE_ADMIN); err != nil {
+		return RoleResult{}, err
+	}
+
+	target := req.Mentions[0]
+	if target == req.CallerID {
+		// Avoids locking everyone out by accident
+		return RoleResult{}, errors.New("You can't revoke your own role")
+	}
+	held, err := database.RevokeRole(scope, target)
+	if err != nil {
+		return RoleResult{}, fmt.Errorf("Error revoking role: %v", err)
+	}
+	if !held {
+		return RoleResult{}, errors.New("That user has no role here")
+	}
+	return RoleResult{Scope: scope, UserID: target}, nil
+}
+
+// ListRoles returns the roles granted within a scope: Args = ["global"?]
+func ListRoles(database db.Database, req Request) (string, []db.RoleGrant, error) {
+	scope, args, err := roleScope(req, req.Args)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(args) != 0 {
+		return "", nil, ErrUsage
+	}
+	if err := Authorize(database, req, scope, db.ROLE_MODERATOR); err != nil {
+		return "", nil, err
+	}
+	grants, err := database.ListRoles(scope)
+	if err != nil {
+		return "", nil, fmt.Errorf("Error listing roles: %v", err)
+	}
+	return scope, grants, nil
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+	"github.com/ivypowered/ivy-sprite-bot/price"
+)
+
+// Most alerts a user can have at once
+const ALERT_MAX_PER_USER = 10
+
+// Argument that makes an alert fire again each time the price crosses back
+const ALERT_REARM = "rearm"
+
+// FormatPriceUSD writes a USD price with as many decimals as it needs
+func FormatPriceUSD(priceUSD float64) string {
+	return "$" + strconv.FormatFloat(priceUSD, 'f', -1, 64)
+}
+
+// CreateAlert sets an alert on the IVY price for the caller:
+// Args = [above|below, price] or [above|below, price, "rearm"]
+func CreateAlert(database db.Database, req Request) (db.PriceAlert, error) {
+	if len(req.Args) != 2 && !(len(req.Args) == 3 && req.Args[2] == ALERT_REARM) {
+		return db.PriceAlert{}, ErrUsage
+	}
+	direction := db.AlertDirection(strings.ToLower(req.Args[0]))
+	if direction != db.ALERT_ABOVE && direction != db.ALERT_BELOW {
+		return db.PriceAlert{}, ErrUsage
+	}
+	targetUSD, err := strconv.ParseFloat(strings.TrimPrefix(req.Args[1], "$"), 64)
+	if err != nil || math.IsNaN(targetUSD) || math.IsInf(targetUSD, 0) || targetUSD <= 0 {
+		return db.PriceAlert{}, errors.New("Please enter a valid positive price, like 0.05")
+	}
+
+	// An alert that would fire straight away is probably a typo
+	if current, err := constants.PRICE.Get(); err == nil {
+		if direction == db.ALERT_ABOVE && current >= targetUSD {
+			return db.PriceAlert{}, fmt.Errorf("IVY is already above %s, at %s", FormatPriceUSD(targetUSD), FormatPriceUSD(current))
+		}
+		if direction == db.ALERT_BELOW && current <= targetUSD {
+			return db.PriceAlert{}, fmt.Errorf("IVY is already below %s, at %s", FormatPriceUSD(targetUSD), FormatPriceUSD(current))
+		}
+	}
+
+	alerts, err := database.ListPriceAlerts(req.CallerID)
+	if err != nil {
+		return db.PriceAlert{}, errors.New("Error loading alerts")
+	}
+	if len(alerts) >= ALERT_MAX_PER_USER {
+		return db.PriceAlert{}, fmt.Errorf("You can have at most %d alerts, remove one first", ALERT_MAX_PER_USER)
+	}
+
+	alert := db.PriceAlert{
+		UserID:    req.CallerID,
+		Direction: direction,
+		TargetUSD: targetUSD,
+		Rearm:     len(req.Args) == 3,
+		Armed:     true,
+	}
+	alert.AlertID, err = database.CreatePriceAlert(alert)
+	if err != nil {
+		return db.PriceAlert{}, errors.New("Error creating alert")
+	}
+	return alert, nil
+}
+
+// ListAlerts returns the caller's alerts
+func ListAlerts(database db.Database, req Request) ([]db.PriceAlert, error) {
+	alerts, err := database.ListPriceAlerts(req.CallerID)
+	if err != nil {
+		return nil, errors.New("Error loading alerts")
+	}
+	return alerts, nil
+}
+
+// RemoveAlert deletes one of the caller's alerts: Args = [id]
+func RemoveAlert(database db.Database, req Request) (int64, error) {
+	if len(req.Args) != 1 {
+		return 0, ErrUsage
+	}
+	alertID, err := strconv.ParseInt(strings.TrimPrefix(req.Args[0], "#"), 10, 64)
+	if err != nil {
+		return 0, errors.New("Please enter a valid alert ID")
+	}
+	ok, err := database.DeletePriceAlert(alertID, req.CallerID)
+	if err != nil {
+		return 0, errors.New("Error removing alert")
+	}
+	if !ok {
+		return 0, fmt.Errorf("You have no alert #%d", alertID)
+	}
+	return alertID, nil
+}
+
+// CheckPriceAlerts fires the alerts a new price sample reached, returning
+// them, and re-arms the ones it's back on the other side of
+func CheckPriceAlerts(database db.Database, sample price.Sample) ([]db.PriceAlert, error) {
+	triggered, err := database.ListTriggeredPriceAlerts(sample.PriceUSD)
+	if err != nil {
+		return nil, err
+	}
+
+	var fired []db.PriceAlert
+	for _, alert := range triggered {
+		ok, err := database.FirePriceAlert(alert.AlertID)
+		if err != nil {
+			return fired, err
+		}
+		if !ok {
+			// Fired by someone else in the meantime
+			continue
+		}
+		alert.Armed = false
+		alert.FiredAt = sample.Timestamp
+		fired = append(fired, alert)
+	}
+
+	if _, err := database.RearmPriceAlerts(sample.PriceUSD); err != nil {
+		return fired, err
+	}
+	return fired, nil
+}
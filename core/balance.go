@@ -0,0 +1,43 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+type BalanceResult struct {
+	// Spendable balance
+	BalanceRaw uint64
+	// Incoming vestings that haven't unlocked yet
+	LockedRaw uint64
+	// IVY price in USD, 0 if there's no fresh price
+	Price float64
+}
+
+// Balance returns the caller's balance and what's still vesting to them
+func Balance(database db.Database, req Request) (BalanceResult, error) {
+	database.EnsureUserExists(req.CallerID)
+
+	balanceRaw, err := database.GetUserBalanceRaw(req.CallerID)
+	if err != nil {
+		return BalanceResult{}, errors.New("Error checking balance")
+	}
+	lockedRaw, err := LockedRaw(database, req.CallerID)
+	if err != nil {
+		return BalanceResult{}, errors.New("Error checking vestings")
+	}
+
+	// The balance is still worth showing without a price
+	price, err := constants.PRICE.Get()
+	if err != nil {
+		price = 0
+	}
+
+	return BalanceResult{
+		BalanceRaw: balanceRaw,
+		LockedRaw:  lockedRaw,
+		Price:      price,
+	}, nil
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// Most bounties one listing shows
+const BOUNTY_LIST_LIMIT = 10
+
+// Longest description a bounty can have, in characters
+const BOUNTY_MAX_DESCRIPTION = 200
+
+// CreateBounty escrows a reward for a task posted in req.ChatID:
+// Args = [amount, description...]
+func CreateBounty(database db.Database, req Request) (db.Bounty, error) {
+	if req.Private || req.ChatID == "" {
+		return db.Bounty{}, ErrGroupOnly
+	}
+	if len(req.Args) < 2 {
+		return db.Bounty{}, ErrUsage
+	}
+
+	amountRaw, err := req.parseAmount()
+	if err != nil {
+		return db.Bounty{}, err
+	}
+	description := strings.Join(req.Args[1:], " ")
+	if utf8.RuneCountInString(description) > BOUNTY_MAX_DESCRIPTION {
+		return db.Bounty{}, fmt.Errorf("Bounty descriptions can be at most %d characters", BOUNTY_MAX_DESCRIPTION)
+	}
+
+	database.EnsureUserExists(req.CallerID)
+	balanceRaw, err := database.GetUserBalanceRaw(req.CallerID)
+	if err != nil {
+		return db.Bounty{}, errors.New("Error checking balance")
+	}
+	if balanceRaw < amountRaw {
+		return db.Bounty{}, &InsufficientBalanceError{BalanceRaw: balanceRaw}
+	}
+	if err := requireConfirmation(req, ACTION_BOUNTY, amountRaw); err != nil {
+		return db.Bounty{}, err
+	}
+
+	bounty := db.Bounty{
+		CreatorID:   req.CallerID,
+		ChatID:      req.ChatID,
+		Description: description,
+		AmountRaw:   amountRaw,
+		Status:      db.BOUNTY_OPEN,
+	}
+	bounty.BountyID, err = database.CreateBounty(bounty)
+	if err != nil {
+		return db.Bounty{}, errors.New("Error creating bounty: your balance may have changed, please try again")
+	}
+	return bounty, nil
+}
+
+// ownBounty loads one of the caller's open bounties from Args[0]
+func ownBounty(database db.Database, req Request) (db.Bounty, error) {
+	if len(req.Args) != 1 {
+		return db.Bounty{}, ErrUsage
+	}
+	bountyID, err := strconv.ParseInt(strings.TrimPrefix(req.Args[0], "#"), 10, 64)
+	if err != nil {
+		return db.Bounty{}, errors.New("Please enter a valid bounty ID")
+	}
+	bounty, err := database.GetBounty(bountyID)
+	if err == sql.ErrNoRows || (err == nil && bounty.CreatorID != req.CallerID) {
+		return db.Bounty{}, fmt.Errorf("You have no bounty #%d", bountyID)
+	}
+	if err != nil {
+		return db.Bounty{}, errors.New("Error loading bounty")
+	}
+	if bounty.Status != db.BOUNTY_OPEN {
+		return db.Bounty{}, fmt.Errorf("Bounty #%d was already %s", bountyID, bounty.Status)
+	}
+	return bounty, nil
+}
+
+// AwardBounty pays one of the caller's open bounties to Mentions[0]:
+// Args = [id]
+func AwardBounty(database db.Database, req Request) (db.Bounty, error) {
+	if len(req.Mentions) != 1 {
+		return db.Bounty{}, ErrUsage
+	}
+	bounty, err := ownBounty(database, req)
+	if err != nil {
+		return db.Bounty{}, err
+	}
+	winnerID := req.Mentions[0]
+	if winnerID == req.CallerID {
+		return db.Bounty{}, errors.New("You can't award a bounty to yourself, cancel it instead")
+	}
+	if err := ensureRecipient(database, req.CallerID, winnerID); err != nil {
+		return db.Bounty{}, err
+	}
+
+	ok, err := database.AwardBounty(bounty.BountyID, req.CallerID, winnerID)
+	if err != nil {
+		return db.Bounty{}, errors.New("Error awarding bounty")
+	}
+	if !ok {
+		return db.Bounty{}, fmt.Errorf("Bounty #%d is no longer open", bounty.BountyID)
+	}
+	bounty.Status = db.BOUNTY_AWARDED
+	bounty.WinnerID = winnerID
+	return bounty, nil
+}
+
+// CancelBounty refunds one of the caller's open bounties: Args = [id]
+func CancelBounty(database db.Database, req Request) (db.Bounty, error) {
+	bounty, err := ownBounty(database, req)
+	if err != nil {
+		return db.Bounty{}, err
+	}
+	ok, err := database.CancelBounty(bounty.BountyID, req.CallerID)
+	if err != nil {
+		return db.Bounty{}, errors.New("Error cancelling bounty")
+	}
+	if !ok {
+		return db.Bounty{}, fmt.Errorf("Bounty #%d is no longer open", bounty.BountyID)
+	}
+	bounty.Status = db.BOUNTY_CANCELLED
+	return bounty, nil
+}
+
+// ListBounties returns the open bounties of req.ChatID, or in private the
+// caller's own bounties
+func ListBounties(database db.Database, req Request) ([]db.Bounty, error) {
+	var bounties []db.Bounty
+	var err error
+	if req.Private || req.ChatID == "" {
+		bounties, err = database.ListBountiesByCreator(req.CallerID, BOUNTY_LIST_LIMIT)
+	} else {
+		bounties, err = database.ListOpenBounties(req.ChatID, BOUNTY_LIST_LIMIT)
+	}
+	if err != nil {
+		return nil, errors.New("Error loading bounties")
+	}
+	return bounties, nil
+}
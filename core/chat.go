@@ -0,0 +1,41 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// RegisterChat opts the Telegram group req.ChatID in to activity tracking and
+// rain. Discord servers don't need this, they whitelist rain channels instead.
+func RegisterChat(database db.Database, req Request, title string) error {
+	if req.Private || req.ChatID == "" {
+		return ErrGroupOnly
+	}
+	if err := Authorize(database, req, req.ChatID, db.ROLE_ADMIN); err != nil {
+		return err
+	}
+	if err := database.RegisterTelegramChat(req.ChatID, title, req.CallerID); err != nil {
+		return fmt.Errorf("Error registering group: %v", err)
+	}
+	return nil
+}
+
+// UnregisterChat opts the Telegram group req.ChatID back out
+func UnregisterChat(database db.Database, req Request) error {
+	if req.Private || req.ChatID == "" {
+		return ErrGroupOnly
+	}
+	if err := Authorize(database, req, req.ChatID, db.ROLE_ADMIN); err != nil {
+		return err
+	}
+	registered, err := database.UnregisterTelegramChat(req.ChatID)
+	if err != nil {
+		return fmt.Errorf("Error unregistering group: %v", err)
+	}
+	if !registered {
+		return errors.New("This group isn't registered")
+	}
+	return nil
+}
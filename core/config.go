@@ -0,0 +1,173 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// RainConfigKey is one rain setting that can be changed with the config command
+type RainConfigKey struct {
+	Name        string
+	Description string
+	Get         func(rs db.RainSettings) string
+	set         func(rs *db.RainSettings, value string) error
+}
+
+// RAIN_CONFIG_KEYS lists every rain setting, in display order
+var RAIN_CONFIG_KEYS = []RainConfigKey{
+	{
+		Name:        "min_usd",
+		Description: "Minimum rain amount in USD",
+		Get:         func(rs db.RainSettings) string { return fmt.Sprintf("$%.2f", rs.MinAmountUSD) },
+		set: func(rs *db.RainSettings, value string) error {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || v < 0 || v > 1_000_000 {
+				return errors.New("min_usd must be a USD amount, e.g. 0.50")
+			}
+			rs.MinAmountUSD = v
+			return nil
+		},
+	},
+	{
+		Name:        "min_active",
+		Description: "Active users needed for rain to work",
+		Get:         func(rs db.RainSettings) string { return strconv.Itoa(rs.MinActiveCount) },
+		set:         intSetter("min_active", 1, func(rs *db.RainSettings) *int { return &rs.MinActiveCount }),
+	},
+	{
+		Name:        "activity_required",
+		Description: "Activity score needed to receive rain",
+		Get:         func(rs db.RainSettings) string { return strconv.Itoa(rs.ActivityRequirement) },
+		set:         intSetter("activity_required", 1, func(rs *db.RainSettings) *int { return &rs.ActivityRequirement }),
+	},
+	{
+		Name:        "activity_max",
+		Description: "Maximum activity score",
+		Get:         func(rs db.RainSettings) string { return strconv.Itoa(rs.ActivityMax) },
+		set:         intSetter("activity_max", 1, func(rs *db.RainSettings) *int { return &rs.ActivityMax }),
+	},
+	{
+		Name:        "delta_min",
+		Description: "Minimum time between messages to increase score",
+		Get:         func(rs db.RainSettings) string { return formatSeconds(rs.ActivityDeltaMin) },
+		set:         durationSetter("delta_min", func(rs *db.RainSettings) *int64 { return &rs.ActivityDeltaMin }),
+	},
+	{
+		Name:        "delta_max",
+		Description: "Maximum time between messages to increase score",
+		Get:         func(rs db.RainSettings) string { return formatSeconds(rs.ActivityDeltaMax) },
+		set:         durationSetter("delta_max", func(rs *db.RainSettings) *int64 { return &rs.ActivityDeltaMax }),
+	},
+	{
+		Name:        "delta_reset",
+		Description: "Time without messages after which score resets",
+		Get:         func(rs db.RainSettings) string { return formatSeconds(rs.ActivityDeltaReset) },
+		set:         durationSetter("delta_reset", func(rs *db.RainSettings) *int64 { return &rs.ActivityDeltaReset }),
+	},
+}
+
+func intSetter(name string, min int, field func(rs *db.RainSettings) *int) func(*db.RainSettings, string) error {
+	return func(rs *db.RainSettings, value string) error {
+		v, err := strconv.Atoi(value)
+		if err != nil || v < min {
+			return fmt.Errorf("%s must be a whole number of at least %d", name, min)
+		}
+		*field(rs) = v
+		return nil
+	}
+}
+
+func durationSetter(name string, field func(rs *db.RainSettings) *int64) func(*db.RainSettings, string) error {
+	return func(rs *db.RainSettings, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < time.Second {
+			return fmt.Errorf("%s must be a duration of at least 1s, e.g. 90s or 20m", name)
+		}
+		*field(rs) = int64(d / time.Second)
+		return nil
+	}
+}
+
+func formatSeconds(seconds int64) string {
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// validateRainSettings rejects settings that contradict each other
+func validateRainSettings(rs db.RainSettings) error {
+	if rs.ActivityRequirement > rs.ActivityMax {
+		return errors.New("activity_required can't be above activity_max, or nobody could receive rain")
+	}
+	if rs.ActivityDeltaMin > rs.ActivityDeltaMax || rs.ActivityDeltaMax > rs.ActivityDeltaReset {
+		return errors.New("Durations must satisfy delta_min <= delta_max <= delta_reset")
+	}
+	return nil
+}
+
+// RainConfig returns the rain settings of req.ChatID
+func RainConfig(database db.Database, req Request) (db.RainSettings, error) {
+	if req.Private || req.ChatID == "" {
+		return db.RainSettings{}, ErrGroupOnly
+	}
+	settings, err := database.GetRainSettings(req.ChatID)
+	if err != nil {
+		return db.RainSettings{}, errors.New("Error loading rain settings")
+	}
+	return settings, nil
+}
+
+// SetRainConfig changes one rain setting of req.ChatID: Args = [key, value]
+func SetRainConfig(database db.Database, req Request) (db.RainSettings, error) {
+	if req.Private || req.ChatID == "" {
+		return db.RainSettings{}, ErrGroupOnly
+	}
+	if len(req.Args) != 2 {
+		return db.RainSettings{}, ErrUsage
+	}
+	if err := Authorize(database, req, req.ChatID, db.ROLE_MODERATOR); err != nil {
+		return db.RainSettings{}, err
+	}
+
+	var key *RainConfigKey
+	for i := range RAIN_CONFIG_KEYS {
+		if RAIN_CONFIG_KEYS[i].Name == req.Args[0] {
+			key = &RAIN_CONFIG_KEYS[i]
+		}
+	}
+	if key == nil {
+		return db.RainSettings{}, fmt.Errorf("Unknown rain setting %s", req.Args[0])
+	}
+
+	settings, err := database.GetRainSettings(req.ChatID)
+	if err != nil {
+		return db.RainSettings{}, errors.New("Error loading rain settings")
+	}
+	if err := key.set(&settings, req.Args[1]); err != nil {
+		return db.RainSettings{}, err
+	}
+	if err := validateRainSettings(settings); err != nil {
+		return db.RainSettings{}, err
+	}
+	if err := database.SetRainSettings(req.ChatID, settings); err != nil {
+		return db.RainSettings{}, errors.New("Error saving rain settings")
+	}
+	return settings, nil
+}
+
+// ResetRainConfig puts req.ChatID back on the default rain settings
+func ResetRainConfig(database db.Database, req Request) (db.RainSettings, error) {
+	if req.Private || req.ChatID == "" {
+		return db.RainSettings{}, ErrGroupOnly
+	}
+	if err := Authorize(database, req, req.ChatID, db.ROLE_MODERATOR); err != nil {
+		return db.RainSettings{}, err
+	}
+	if err := database.ResetRainSettings(req.ChatID); err != nil {
+		return db.RainSettings{}, errors.New("Error resetting rain settings")
+	}
+	return db.DefaultRainSettings(), nil
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// ActionKind names a command that can be held back for confirmation
+type ActionKind string
+
+const (
+	ACTION_TIP           ActionKind = "tip"
+	ACTION_RAIN          ActionKind = "rain"
+	ACTION_WITHDRAW      ActionKind = "withdraw"
+	ACTION_RAFFLE        ActionKind = "raffle"
+	ACTION_BOUNTY        ActionKind = "bounty"
+	ACTION_VEST          ActionKind = "vest"
+	ACTION_RAIN_SCHEDULE ActionKind = "rain schedule"
+)
+
+// ConfirmationRequiredError is returned instead of running a command worth
+// CONFIRM_THRESHOLD_USD or more, or one that can't be valued, until the
+// caller confirms it
+type ConfirmationRequiredError struct {
+	Kind      ActionKind
+	AmountRaw uint64
+	AmountUSD float64
+	// Whether there was no fresh price to work out AmountUSD with
+	Unpriced bool
+	// The request held back, with its amount already parsed
+	req Request
+}
+
+// How kinds that aren't verbs read in a confirmation question
+var actionPhrases = map[ActionKind]string{
+	ACTION_RAFFLE:        "raffle off",
+	ACTION_BOUNTY:        "put up a bounty of",
+	ACTION_VEST:          "lock up and vest",
+	ACTION_RAIN_SCHEDULE: "schedule a recurring rain of",
+}
+
+func (e *ConfirmationRequiredError) Error() string {
+	phrase, ok := actionPhrases[e.Kind]
+	if !ok {
+		phrase = string(e.Kind)
+	}
+	amountIVY := float64(e.AmountRaw) / constants.IVY_FACTOR
+	if e.Unpriced {
+		return fmt.Sprintf(
+			"Are you sure you want to %s %.9f IVY? Its USD value is unavailable right now.",
+			phrase, amountIVY,
+		)
+	}
+	return fmt.Sprintf(
+		"Are you sure you want to %s %.9f IVY (~$%.2f)?",
+		phrase, amountIVY, e.AmountUSD,
+	)
+}
+
+// ErrActionGone means a confirmation was already answered, expired, or
+// belongs to someone else
+var ErrActionGone = errors.New("This confirmation has expired or isn't yours")
+
+// requireConfirmation holds back large amounts unless req was confirmed
+func requireConfirmation(req Request, kind ActionKind, amountRaw uint64) error {
+	if req.Confirmed {
+		return nil
+	}
+	price, err := constants.PRICE.Get()
+	if err != nil {
+		// Without a fresh price the amount can't be checked against the
+		// threshold, so ask anyway
+		return &ConfirmationRequiredError{Kind: kind, AmountRaw: amountRaw, Unpriced: true, req: req}
+	}
+	amountUSD := float64(amountRaw) / constants.IVY_FACTOR * price
+	if amountUSD < constants.CONFIRM_THRESHOLD_USD {
+		return nil
+	}
+	return &ConfirmationRequiredError{Kind: kind, AmountRaw: amountRaw, AmountUSD: amountUSD, req: req}
+}
+
+// SavePendingAction stores the request confirm held back until its caller
+// confirms or cancels it, returning the action ID to put on the buttons
+func SavePendingAction(database db.Database, confirm *ConfirmationRequiredError) (string, error) {
+	req := confirm.req
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	var id [8]byte
+	rand.Read(id[:])
+	actionID := hex.EncodeToString(id[:])
+	err = database.CreatePendingAction(db.PendingAction{
+		ActionID:  actionID,
+		UserID:    req.CallerID,
+		Kind:      string(confirm.Kind),
+		Payload:   string(payload),
+		ExpiresAt: time.Now().Add(constants.CONFIRM_EXPIRY).Unix(),
+	})
+	if err != nil {
+		return "", errors.New("Error saving confirmation")
+	}
+	return actionID, nil
+}
+
+// TakePendingAction claims a pending action for its caller, returning the
+// request to run again, now marked as confirmed
+func TakePendingAction(database db.Database, callerID, actionID string) (ActionKind, Request, error) {
+	action, err := database.TakePendingAction(actionID, callerID)
+	if err == sql.ErrNoRows {
+		return "", Request{}, ErrActionGone
+	}
+	if err != nil {
+		return "", Request{}, errors.New("Error loading confirmation")
+	}
+	var req Request
+	if err := json.Unmarshal([]byte(action.Payload), &req); err != nil {
+		return "", Request{}, errors.New("Error loading confirmation")
+	}
+	req.Confirmed = true
+	return ActionKind(action.Kind), req, nil
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/price"
+)
+
+func TestRequireConfirmationWithoutPrice(t *testing.T) {
+	previous := constants.PRICE
+	constants.PRICE = price.New(time.Hour)
+	defer func() { constants.PRICE = previous }()
+
+	req := Request{CallerID: "a", Args: []string{"1"}, AmountRaw: 1}
+	var confirm *ConfirmationRequiredError
+	if err := requireConfirmation(req, ACTION_TIP, 1); !errors.As(err, &confirm) || !confirm.Unpriced {
+		t.Fatalf("requireConfirmation without a price = %v", err)
+	}
+	if confirm.req.AmountRaw != 1 {
+		t.Fatalf("held back request = %+v", confirm.req)
+	}
+
+	req.Confirmed = true
+	if err := requireConfirmation(req, ACTION_TIP, 1); err != nil {
+		t.Fatalf("requireConfirmation once confirmed = %v", err)
+	}
+}
+
+func TestParseAmountReplaysConfirmed(t *testing.T) {
+	req := Request{Args: []string{"$1"}, AmountRaw: 42, Confirmed: true}
+	if got, err := req.parseAmount(); err != nil || got != 42 {
+		t.Fatalf("parseAmount of a confirmed request = %d, %v, want 42", got, err)
+	}
+
+	req = Request{Args: []string{"1.5"}}
+	if got, err := req.parseAmount(); err != nil || got != 1_500_000_000 || req.AmountRaw != got {
+		t.Fatalf("parseAmount = %d, %v, AmountRaw %d", got, err, req.AmountRaw)
+	}
+}
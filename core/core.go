@@ -0,0 +1,89 @@
+// Package core implements the wallet commands independently of the chat
+// platform. The discord and telegram packages turn messages into a Request,
+// call into core and render whatever result or error comes back.
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/util"
+)
+
+// Request is a platform-neutral command invocation
+type Request struct {
+	// Database ID of the user running the command
+	CallerID string
+	// Database ID of the server or group the command was sent in, "" in private
+	ChatID string
+	// Whether the command was sent in a DM / private chat
+	Private bool
+	// Users mentioned or replied to, as database IDs
+	Mentions []string
+	// Remaining command arguments, with mentions removed
+	Args []string
+	// Whether the platform considers the caller an admin of ChatID, e.g.
+	// Manage Server on Discord. Only filled in for privileged commands.
+	ChatAdmin bool
+	// Whether the caller already confirmed a large amount
+	Confirmed bool
+	// The amount in Args[0] as parsed when the request was held back, so a
+	// confirmed $ amount isn't converted again at a different price
+	AmountRaw uint64
+}
+
+// ErrUsage means the arguments were malformed; the caller should show usage
+var ErrUsage = errors.New("invalid usage")
+
+// ErrPrivateOnly means the command may only be used in a DM / private chat
+var ErrPrivateOnly = errors.New("command can only be used in private")
+
+// ErrGroupOnly means the command may only be used in a server / group
+var ErrGroupOnly = errors.New("command can only be used in a group")
+
+// InsufficientBalanceError is returned when the caller can't afford an action
+type InsufficientBalanceError struct {
+	BalanceRaw uint64
+}
+
+func (e *InsufficientBalanceError) Error() string {
+	return fmt.Sprintf("Insufficient balance. Your balance: %.9f IVY", float64(e.BalanceRaw)/constants.IVY_FACTOR)
+}
+
+// IsTelegramID reports whether a database ID belongs to a Telegram user
+func IsTelegramID(id string) bool {
+	return strings.HasPrefix(id, "tg:")
+}
+
+// samePlatform reports whether two database IDs live on the same platform
+func samePlatform(a, b string) bool {
+	return IsTelegramID(a) == IsTelegramID(b)
+}
+
+// parseAmount parses the amount in Args[0] and remembers it on req, or
+// returns the amount a confirmed request was shown with
+func (req *Request) parseAmount() (uint64, error) {
+	if req.Confirmed && req.AmountRaw != 0 {
+		return req.AmountRaw, nil
+	}
+	amountRaw, err := parseAmountRaw(req.Args[0])
+	if err != nil {
+		return 0, err
+	}
+	req.AmountRaw = amountRaw
+	return amountRaw, nil
+}
+
+// parseAmountRaw parses a strictly positive IVY or $USD amount into RAW
+func parseAmountRaw(amount string) (uint64, error) {
+	amountRaw, err := util.ParseAmount(amount)
+	if err != nil {
+		return 0, fmt.Errorf("Please enter a valid positive amount (%v)", err)
+	}
+	if amountRaw == 0 {
+		return 0, errors.New("Please enter a valid positive amount")
+	}
+	return amountRaw, nil
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+	"github.com/ivypowered/ivy-sprite-bot/util"
+)
+
+type DepositResult struct {
+	DepositID string
+	AmountRaw uint64
+}
+
+type DepositStatus int
+
+const (
+	// Deposit was already credited before this check
+	DEPOSIT_ALREADY_COMPLETE DepositStatus = iota
+	// Funds haven't arrived on-chain yet
+	DEPOSIT_PENDING
+	// Funds arrived and were credited by this check
+	DEPOSIT_COMPLETED
+)
+
+type DepositCheckResult struct {
+	DepositID  string
+	AmountRaw  uint64
+	Status     DepositStatus
+	BalanceRaw uint64
+}
+
+// DepositURL returns the page where a user completes their deposit
+func DepositURL(depositID, userID, name string) string {
+	return fmt.Sprintf(
+		"https://sprite.ivypowered.com/deposit?deposit_id=%s&user_id=%s&name=%s",
+		depositID,
+		userID,
+		url.QueryEscape(name),
+	)
+}
+
+// CreateDeposit opens a new pending deposit: Args = [amount]
+func CreateDeposit(database db.Database, req Request) (DepositResult, error) {
+	if !req.Private {
+		return DepositResult{}, ErrPrivateOnly
+	}
+	if len(req.Args) != 1 {
+		return DepositResult{}, ErrUsage
+	}
+
+	amountRaw, err := parseAmountRaw(req.Args[0])
+	if err != nil {
+		return DepositResult{}, err
+	}
+
+	database.EnsureUserExists(req.CallerID)
+
+	// Generate deposit ID
+	depositIDBytes := util.GenerateID(amountRaw)
+	depositID := hex.EncodeToString(depositIDBytes[:])
+
+	// Create deposit record
+	err = database.CreateDeposit(depositID, req.CallerID, amountRaw)
+	if err != nil {
+		return DepositResult{}, errors.New("Error creating deposit")
+	}
+
+	return DepositResult{DepositID: depositID, AmountRaw: amountRaw}, nil
+}
+
+// CheckDeposit credits a deposit if it has arrived on-chain: Args = [id prefix]
+func CheckDeposit(database db.Database, req Request) (DepositCheckResult, error) {
+	if !req.Private {
+		return DepositCheckResult{}, ErrPrivateOnly
+	}
+	if len(req.Args) != 1 {
+		return DepositCheckResult{}, ErrUsage
+	}
+
+	// If there's duplicate deposits, we select the latest one!
+	fullDepositID, amountRaw, completed, err := database.FindDepositByPrefix(req.CallerID, req.Args[0])
+	if err == sql.ErrNoRows {
+		return DepositCheckResult{}, errors.New("No deposit found with that ID")
+	} else if err != nil {
+		return DepositCheckResult{}, fmt.Errorf("Error checking deposit: %v", err)
+	}
+
+	result := DepositCheckResult{
+		DepositID: fullDepositID,
+		AmountRaw: amountRaw,
+	}
+	if completed == 1 {
+		result.Status = DEPOSIT_ALREADY_COMPLETE
+		return result, nil
+	}
+
+	// Decode deposit ID
+	depositID32, err := util.DecodeID(fullDepositID)
+	if err != nil {
+		return DepositCheckResult{}, errors.New("Invalid deposit ID format")
+	}
+
+	// Check if deposit is complete on-chain
+	isComplete, err := util.IsDepositComplete(constants.RPC_CLIENT, constants.SPRITE_VAULT, depositID32)
+	if err != nil {
+		return DepositCheckResult{}, fmt.Errorf("Error checking deposit status: %v", err)
+	}
+	if !isComplete {
+		result.Status = DEPOSIT_PENDING
+		return result, nil
+	}
+
+	// Complete the deposit
+	err = database.CompleteDeposit(fullDepositID)
+	if err == sql.ErrNoRows {
+		// The deposit watcher got to it first
+		result.Status = DEPOSIT_ALREADY_COMPLETE
+		return result, nil
+	} else if err != nil {
+		return DepositCheckResult{}, errors.New("Error completing deposit")
+	}
+
+	result.Status = DEPOSIT_COMPLETED
+	result.BalanceRaw, _ = database.GetUserBalanceRaw(req.CallerID)
+	return result, nil
+}
+
+// ListDeposits returns the caller's recent deposits
+func ListDeposits(database db.Database, req Request) ([]db.Deposit, error) {
+	if !req.Private {
+		return nil, ErrPrivateOnly
+	}
+	deposits, err := database.ListDeposits(req.CallerID, 10)
+	if err != nil {
+		return nil, errors.New("Error fetching deposits")
+	}
+	return deposits, nil
+}
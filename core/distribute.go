@@ -0,0 +1,49 @@
+package core
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// splitEven divides totalRaw between n recipients. The first totalRaw % n
+// recipients get one extra RAW, so every unit lands somewhere.
+func splitEven(totalRaw uint64, n int) []uint64 {
+	weights := make([]uint64, n)
+	for i := range weights {
+		weights[i] = 1
+	}
+	return splitWeighted(totalRaw, weights)
+}
+
+// splitWeighted divides totalRaw in proportion to weights, which must not
+// all be zero. Each share is rounded down, then the leftover RAW go one each
+// to the largest rounding losses, earlier recipients first on ties.
+func splitWeighted(totalRaw uint64, weights []uint64) []uint64 {
+	var totalWeight uint64
+	for _, w := range weights {
+		totalWeight += w
+	}
+
+	shares := make([]uint64, len(weights))
+	losses := make([]uint64, len(weights))
+	var assigned uint64
+	for i, w := range weights {
+		// totalRaw * w / totalWeight without overflowing; the quotient fits
+		// since w <= totalWeight
+		hi, lo := bits.Mul64(totalRaw, w)
+		shares[i], losses[i] = bits.Div64(hi, lo, totalWeight)
+		assigned += shares[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return losses[order[a]] > losses[order[b]]
+	})
+	for _, i := range order[:totalRaw-assigned] {
+		shares[i]++
+	}
+	return shares
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func sum(shares []uint64) uint64 {
+	var total uint64
+	for _, s := range shares {
+		total += s
+	}
+	return total
+}
+
+func TestSplitEven(t *testing.T) {
+	shares := splitEven(100, 3)
+	if !slices.Equal(shares, []uint64{34, 33, 33}) {
+		t.Fatalf("splitEven(100, 3) = %v", shares)
+	}
+	shares = splitEven(2, 3)
+	if !slices.Equal(shares, []uint64{1, 1, 0}) {
+		t.Fatalf("splitEven(2, 3) = %v", shares)
+	}
+}
+
+func TestSplitWeighted(t *testing.T) {
+	tests := []struct {
+		total   uint64
+		weights []uint64
+		want    []uint64
+	}{
+		{100, []uint64{1, 1, 2}, []uint64{25, 25, 50}},
+		// 100*3/7 = 42.86, 100*2/7 = 28.57 twice: the largest loss gets the extra unit
+		{100, []uint64{3, 2, 2}, []uint64{43, 29, 28}},
+		{10, []uint64{10, 0}, []uint64{10, 0}},
+		// Large amounts don't overflow
+		{1 << 63, []uint64{10, 10}, []uint64{1 << 62, 1 << 62}},
+	}
+	for _, tt := range tests {
+		shares := splitWeighted(tt.total, tt.weights)
+		if !slices.Equal(shares, tt.want) {
+			t.Errorf("splitWeighted(%d, %v) = %v, want %v", tt.total, tt.weights, shares, tt.want)
+		}
+		if sum(shares) != tt.total {
+			t.Errorf("splitWeighted(%d, %v) lost %d RAW", tt.total, tt.weights, tt.total-sum(shares))
+		}
+	}
+}
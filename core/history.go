@@ -0,0 +1,110 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// Number of ledger entries shown per history page
+const HISTORY_PAGE_SIZE = 10
+
+// Format of the from= and to= history filters
+const HISTORY_DATE_FORMAT = "2006-01-02"
+
+type HistoryResult struct {
+	Entries []db.LedgerEntry
+	Page    int
+	Pages   int
+	Total   int
+}
+
+// History returns a page of the caller's ledger: Args = [page] [kind=...] [from=...] [to=...]
+func History(database db.Database, req Request) (HistoryResult, error) {
+	page, filter, err := parseHistoryArgs(req.Args)
+	if err != nil {
+		return HistoryResult{}, err
+	}
+
+	total, err := database.CountLedger(req.CallerID, filter)
+	if err != nil {
+		return HistoryResult{}, errors.New("Error fetching history")
+	}
+	pages := (total + HISTORY_PAGE_SIZE - 1) / HISTORY_PAGE_SIZE
+	if pages == 0 {
+		pages = 1
+	}
+	if page > pages {
+		return HistoryResult{}, fmt.Errorf("Page %d doesn't exist, there are only %d", page, pages)
+	}
+
+	entries, err := database.ListLedger(req.CallerID, filter, HISTORY_PAGE_SIZE, (page-1)*HISTORY_PAGE_SIZE)
+	if err != nil {
+		return HistoryResult{}, errors.New("Error fetching history")
+	}
+
+	return HistoryResult{
+		Entries: entries,
+		Page:    page,
+		Pages:   pages,
+		Total:   total,
+	}, nil
+}
+
+// Parse history arguments: [page] [kind=tip,rain] [from=YYYY-MM-DD] [to=YYYY-MM-DD]
+func parseHistoryArgs(args []string) (int, db.LedgerFilter, error) {
+	page := 1
+	var filter db.LedgerFilter
+	for _, arg := range args {
+		key, value, hasValue := strings.Cut(arg, "=")
+		if !hasValue {
+			p, err := strconv.Atoi(arg)
+			if err != nil || p < 1 {
+				return 0, filter, fmt.Errorf("invalid page number: %s", arg)
+			}
+			page = p
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "kind":
+			for _, k := range strings.Split(value, ",") {
+				kind, ok := db.ParseLedgerKind(k)
+				if !ok {
+					return 0, filter, fmt.Errorf("unknown kind %q, expected one of: %s", k, ledgerKindList())
+				}
+				filter.Kinds = append(filter.Kinds, kind)
+			}
+		case "from":
+			t, err := time.Parse(HISTORY_DATE_FORMAT, value)
+			if err != nil {
+				return 0, filter, errors.New("from= must be a date like 2025-01-31")
+			}
+			filter.Since = t.Unix()
+		case "to":
+			t, err := time.Parse(HISTORY_DATE_FORMAT, value)
+			if err != nil {
+				return 0, filter, errors.New("to= must be a date like 2025-01-31")
+			}
+			// Include the whole day
+			filter.Until = t.AddDate(0, 0, 1).Unix()
+		default:
+			return 0, filter, fmt.Errorf("unknown filter: %s", key)
+		}
+	}
+	if filter.Since > 0 && filter.Until > 0 && filter.Since >= filter.Until {
+		return 0, filter, errors.New("from= must be before to=")
+	}
+	return page, filter, nil
+}
+
+func ledgerKindList() string {
+	kinds := make([]string, len(db.LEDGER_KINDS))
+	for i, k := range db.LEDGER_KINDS {
+		kinds[i] = string(k)
+	}
+	return strings.Join(kinds, ", ")
+}
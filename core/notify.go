@@ -0,0 +1,41 @@
+package core
+
+type NotificationKind int
+
+const (
+	NOTIFY_SUCCESS NotificationKind = iota
+	NOTIFY_CLOCK
+	NOTIFY_ERROR
+)
+
+// Notification is a direct message sent to a user outside of any command,
+// e.g. by a background worker
+type Notification struct {
+	// Database ID of the recipient
+	UserID string
+	Kind   NotificationKind
+	Title  string
+	// Plain text, the platform takes care of escaping
+	Message string
+	// If set, the notification is posted publicly here instead of sent to
+	// UserID: a Discord channel ID, or a Telegram group's database ID.
+	// UserID still picks the platform.
+	ChannelID string
+	// Database ID of a user to mention in a public notification, "" for none
+	Mention string
+}
+
+// Notifier routes notifications to the platform the recipient belongs to
+type Notifier struct {
+	Discord  chan<- Notification
+	Telegram chan<- Notification
+}
+
+// Notify queues a notification for delivery
+func (n Notifier) Notify(notification Notification) {
+	if IsTelegramID(notification.UserID) {
+		n.Telegram <- notification
+	} else {
+		n.Discord <- notification
+	}
+}
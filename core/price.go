@@ -0,0 +1,80 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/price"
+)
+
+// Windows the price can be charted over
+var PRICE_WINDOWS = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+const PRICE_DEFAULT_WINDOW = "24h"
+
+// Size of price charts, in pixels
+const (
+	PRICE_CHART_WIDTH  = 600
+	PRICE_CHART_HEIGHT = 200
+)
+
+type PriceResult struct {
+	// One of PRICE_WINDOWS
+	Window string
+	Latest price.Sample
+	// Set if Latest is too old to convert with
+	Stale bool
+	// Change over the window in percent, if there's history from before now
+	ChangePercent float64
+	HasChange     bool
+	// PNG of the price over the window, nil without enough history
+	Chart []byte
+}
+
+// Price returns the latest price and how it moved: Args = [] or [window]
+func Price(req Request) (PriceResult, error) {
+	if len(req.Args) > 1 {
+		return PriceResult{}, ErrUsage
+	}
+	window := PRICE_DEFAULT_WINDOW
+	if len(req.Args) == 1 {
+		window = req.Args[0]
+	}
+	duration, ok := PRICE_WINDOWS[window]
+	if !ok {
+		return PriceResult{}, errors.New("Please pick a window of 1h, 24h or 7d")
+	}
+
+	latest, err := constants.PRICE.Latest()
+	if err != nil {
+		return PriceResult{}, err
+	}
+	_, err = constants.PRICE.Get()
+	result := PriceResult{
+		Window: window,
+		Latest: latest,
+		Stale:  err != nil,
+	}
+
+	samples, err := constants.PRICE.History(time.Now().Add(-duration).Unix())
+	if err != nil {
+		return PriceResult{}, fmt.Errorf("Error loading price history: %v", err)
+	}
+	if len(samples) >= 2 {
+		if first := samples[0]; first.PriceUSD > 0 && first.Timestamp < latest.Timestamp {
+			result.ChangePercent = (latest.PriceUSD - first.PriceUSD) / first.PriceUSD * 100
+			result.HasChange = true
+		}
+		result.Chart, err = price.Sparkline(samples, PRICE_CHART_WIDTH, PRICE_CHART_HEIGHT)
+		if err != nil {
+			return PriceResult{}, fmt.Errorf("Error drawing price chart: %v", err)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,239 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// Shortest and longest a raffle can run
+const (
+	RAFFLE_MIN_DURATION = time.Minute
+	RAFFLE_MAX_DURATION = 7 * 24 * time.Hour
+)
+
+// ErrNoRaffle means the chat has no open raffle
+var ErrNoRaffle = errors.New("There's no raffle running here. Start one with raffle start")
+
+// ErrRaffleEnded means a raffle no longer takes entries
+var ErrRaffleEnded = errors.New("This raffle has ended")
+
+// RaffleDraw is the outcome of a raffle, with everything needed to check it
+type RaffleDraw struct {
+	Raffle db.Raffle
+	// Entrants sorted by database ID, the order the winner is picked from
+	Entrants []string
+}
+
+// RaffleSeedHash is the commitment to a seed published when a raffle
+// starts: the hex SHA-256 of the seed as written
+func RaffleSeedHash(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// RaffleWinnerIndex picks the winner of a raffle from its sorted entrants.
+// The SHA-256 of seed + ":" + the entrants joined by "," is read as a
+// big-endian integer and taken modulo the number of entrants. The seed is
+// secret until the draw, so nobody can steer the result by joining.
+func RaffleWinnerIndex(seed string, entrants []string) int {
+	sum := sha256.Sum256([]byte(seed + ":" + strings.Join(entrants, ",")))
+	n := new(big.Int).SetBytes(sum[:])
+	return int(n.Mod(n, big.NewInt(int64(len(entrants)))).Int64())
+}
+
+// hideSeed blanks the seed of a raffle that hasn't been drawn yet, so it
+// can't leak before the draw
+func hideSeed(raffle db.Raffle) db.Raffle {
+	if raffle.Status == db.RAFFLE_OPEN {
+		raffle.Seed = ""
+	}
+	return raffle
+}
+
+// StartRaffle escrows a prize and opens a raffle in req.ChatID that ends
+// after a duration: Args = [prize, duration]. channelID is where the raffle
+// is announced.
+func StartRaffle(database db.Database, req Request, channelID string) (db.Raffle, error) {
+	if req.Private || req.ChatID == "" {
+		return db.Raffle{}, ErrGroupOnly
+	}
+	if len(req.Args) != 2 {
+		return db.Raffle{}, ErrUsage
+	}
+
+	prizeRaw, err := req.parseAmount()
+	if err != nil {
+		return db.Raffle{}, err
+	}
+	duration, err := time.ParseDuration(req.Args[1])
+	if err != nil {
+		return db.Raffle{}, errors.New("Please enter a valid duration, like 30m or 24h")
+	}
+	if duration < RAFFLE_MIN_DURATION || duration > RAFFLE_MAX_DURATION {
+		return db.Raffle{}, fmt.Errorf("Raffles must run between %s and %s", RAFFLE_MIN_DURATION, RAFFLE_MAX_DURATION)
+	}
+
+	database.EnsureUserExists(req.CallerID)
+	balanceRaw, err := database.GetUserBalanceRaw(req.CallerID)
+	if err != nil {
+		return db.Raffle{}, errors.New("Error checking balance")
+	}
+	if balanceRaw < prizeRaw {
+		return db.Raffle{}, &InsufficientBalanceError{BalanceRaw: balanceRaw}
+	}
+	if _, err := database.GetOpenRaffle(req.ChatID); err == nil {
+		return db.Raffle{}, errors.New("There's already a raffle running here")
+	} else if err != sql.ErrNoRows {
+		return db.Raffle{}, errors.New("Error checking for raffles")
+	}
+	if err := requireConfirmation(req, ACTION_RAFFLE, prizeRaw); err != nil {
+		return db.Raffle{}, err
+	}
+
+	var seed [32]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return db.Raffle{}, errors.New("Error generating raffle seed")
+	}
+	raffle := db.Raffle{
+		OwnerID:   req.CallerID,
+		ChatID:    req.ChatID,
+		ChannelID: channelID,
+		PrizeRaw:  prizeRaw,
+		Seed:      hex.EncodeToString(seed[:]),
+		EndsAt:    time.Now().Add(duration).Unix(),
+		Status:    db.RAFFLE_OPEN,
+	}
+	raffle.SeedHash = RaffleSeedHash(raffle.Seed)
+	raffle.RaffleID, err = database.CreateRaffle(raffle)
+	if err != nil {
+		return db.Raffle{}, errors.New("Error starting raffle: your balance changed or a raffle was just started here")
+	}
+	return hideSeed(raffle), nil
+}
+
+// JoinRaffle enters the caller into the open raffle of req.ChatID
+func JoinRaffle(database db.Database, req Request) (db.Raffle, error) {
+	if req.Private || req.ChatID == "" {
+		return db.Raffle{}, ErrGroupOnly
+	}
+	raffle, err := database.GetOpenRaffle(req.ChatID)
+	if err == sql.ErrNoRows {
+		return db.Raffle{}, ErrNoRaffle
+	}
+	if err != nil {
+		return db.Raffle{}, errors.New("Error loading raffle")
+	}
+	return joinRaffle(database, req.CallerID, raffle)
+}
+
+// JoinRaffleByMessage enters a user into the raffle announced in a
+// message, e.g. when they react to it. Returns sql.ErrNoRows if the
+// message isn't a raffle announcement.
+func JoinRaffleByMessage(database db.Database, userID, messageID string) (db.Raffle, error) {
+	raffle, err := database.GetRaffleByMessage(messageID)
+	if err != nil {
+		return db.Raffle{}, err
+	}
+	return joinRaffle(database, userID, raffle)
+}
+
+// JoinRaffleByID enters a user into a raffle, e.g. from a button on its
+// announcement
+func JoinRaffleByID(database db.Database, userID string, raffleID int64) (db.Raffle, error) {
+	raffle, err := database.GetRaffle(raffleID)
+	if err == sql.ErrNoRows {
+		return db.Raffle{}, ErrRaffleEnded
+	}
+	if err != nil {
+		return db.Raffle{}, errors.New("Error loading raffle")
+	}
+	return joinRaffle(database, userID, raffle)
+}
+
+func joinRaffle(database db.Database, userID string, raffle db.Raffle) (db.Raffle, error) {
+	if raffle.OwnerID == userID {
+		return db.Raffle{}, errors.New("You can't join your own raffle")
+	}
+	now := time.Now().Unix()
+	if raffle.Status != db.RAFFLE_OPEN || raffle.EndsAt <= now {
+		return db.Raffle{}, ErrRaffleEnded
+	}
+	ok, err := database.JoinRaffle(raffle.RaffleID, userID, now)
+	if err != nil {
+		return db.Raffle{}, errors.New("Error joining raffle")
+	}
+	if !ok {
+		return db.Raffle{}, fmt.Errorf("You're already in raffle #%d", raffle.RaffleID)
+	}
+	return hideSeed(raffle), nil
+}
+
+// DrawRaffle picks the winner of an ended raffle and pays them the prize,
+// or refunds the owner if nobody entered
+func DrawRaffle(database db.Database, raffle db.Raffle) (RaffleDraw, error) {
+	entrants, err := database.ListRaffleEntries(raffle.RaffleID)
+	if err != nil {
+		return RaffleDraw{}, fmt.Errorf("can't list entrants: %w", err)
+	}
+	// The database might collate differently, so sort the same way verifiers will
+	sort.Strings(entrants)
+
+	winnerID := ""
+	if len(entrants) > 0 {
+		winnerID = entrants[RaffleWinnerIndex(raffle.Seed, entrants)]
+	}
+	ok, err := database.FinishRaffle(raffle.RaffleID, winnerID)
+	if err != nil {
+		return RaffleDraw{}, fmt.Errorf("can't finish raffle: %w", err)
+	}
+	if !ok {
+		return RaffleDraw{}, ErrRaffleEnded
+	}
+
+	raffle.WinnerID = winnerID
+	raffle.Status = db.RAFFLE_DRAWN
+	if winnerID == "" {
+		raffle.Status = db.RAFFLE_REFUNDED
+	}
+	return RaffleDraw{Raffle: raffle, Entrants: entrants}, nil
+}
+
+// VerifyRaffle returns a raffle with its entrants: Args = [id]. The seed is
+// only included once the raffle is drawn.
+func VerifyRaffle(database db.Database, req Request) (RaffleDraw, error) {
+	if len(req.Args) != 1 {
+		return RaffleDraw{}, ErrUsage
+	}
+	raffleID, err := strconv.ParseInt(strings.TrimPrefix(req.Args[0], "#"), 10, 64)
+	if err != nil {
+		return RaffleDraw{}, errors.New("Please enter a valid raffle ID")
+	}
+	raffle, err := database.GetRaffle(raffleID)
+	if err == sql.ErrNoRows {
+		return RaffleDraw{}, fmt.Errorf("There's no raffle #%d", raffleID)
+	}
+	if err != nil {
+		return RaffleDraw{}, errors.New("Error loading raffle")
+	}
+	// Raffles are only visible from their own platform
+	if !samePlatform(raffle.ChatID, req.CallerID) {
+		return RaffleDraw{}, fmt.Errorf("There's no raffle #%d", raffleID)
+	}
+	entrants, err := database.ListRaffleEntries(raffleID)
+	if err != nil {
+		return RaffleDraw{}, errors.New("Error loading raffle entrants")
+	}
+	sort.Strings(entrants)
+	return RaffleDraw{Raffle: hideSeed(raffle), Entrants: entrants}, nil
+}
@@ -0,0 +1,32 @@
+package core
+
+import "testing"
+
+func TestRaffleSeedHash(t *testing.T) {
+	// Same as `printf abc | sha256sum`
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := RaffleSeedHash("abc"); got != want {
+		t.Fatalf("RaffleSeedHash(abc) = %s, want %s", got, want)
+	}
+}
+
+func TestRaffleWinnerIndex(t *testing.T) {
+	// Expected values computed independently as
+	// int(sha256(seed + ":" + ",".join(entrants)).hexdigest(), 16) % len(entrants)
+	entrants := []string{"a", "b", "c", "d", "e"}
+	tests := []struct {
+		seed string
+		want int
+	}{
+		{"x", 4},
+		{"y", 2},
+	}
+	for _, tt := range tests {
+		if got := RaffleWinnerIndex(tt.seed, entrants); got != tt.want {
+			t.Errorf("RaffleWinnerIndex(%q) = %d, want %d", tt.seed, got, tt.want)
+		}
+	}
+	if got := RaffleWinnerIndex("x", entrants[:1]); got != 0 {
+		t.Errorf("RaffleWinnerIndex with one entrant = %d", got)
+	}
+}
@@ -0,0 +1,266 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+	"github.com/ivypowered/ivy-sprite-bot/util"
+)
+
+type RainResult struct {
+	SenderID  string
+	AmountRaw uint64
+	// Smallest amount any recipient got; with an even split the rest got 1 RAW more
+	AmountPerUserRaw uint64
+	// Whether amounts were weighted by activity score
+	Weighted         bool
+	SenderBalanceRaw uint64
+	Recipients       []string
+	// Amount each recipient got, in the same order as Recipients
+	RecipientAmountsRaw []uint64
+	// New balance of each recipient, in the same order as Recipients
+	RecipientBalancesRaw []uint64
+}
+
+// Option of Rain that limits targeted rain to active users
+const RAIN_ACTIVE = "active"
+
+// Values of Rain's mode= option
+const (
+	// Everyone gets the same amount
+	RAIN_MODE_EVEN = "even"
+	// Amounts are proportional to activity score
+	RAIN_MODE_WEIGHTED = "weighted"
+)
+
+// rainOptions are the parsed options of Rain
+type rainOptions struct {
+	maxUsers   int
+	activeOnly bool
+	weighted   bool
+}
+
+// Rain splits an amount between the active users of req.ChatID:
+// Args = [amount, (max=N), (mode=even|weighted)]. If Mentions is set, the
+// rain targets those users instead, and Args may also contain RAIN_ACTIVE to
+// only keep the active ones.
+func Rain(database db.Database, req Request) (RainResult, error) {
+	if req.Private || req.ChatID == "" {
+		return RainResult{}, ErrGroupOnly
+	}
+	if len(req.Args) < 1 {
+		return RainResult{}, ErrUsage
+	}
+
+	// Parse options
+	targeted := req.Mentions != nil
+	opts := rainOptions{maxUsers: math.MaxInt, activeOnly: !targeted}
+	for _, option := range req.Args[1:] {
+		switch {
+		case strings.HasPrefix(option, "max="):
+			var err error
+			opts.maxUsers, err = parseMaxUsers(option)
+			if err != nil {
+				return RainResult{}, err
+			}
+		case option == RAIN_ACTIVE && targeted:
+			opts.activeOnly = true
+		case option == "mode="+RAIN_MODE_EVEN:
+			opts.weighted = false
+		case option == "mode="+RAIN_MODE_WEIGHTED:
+			opts.weighted = true
+		default:
+			return RainResult{}, ErrUsage
+		}
+	}
+
+	// Parse amount
+	amountRaw, err := req.parseAmount()
+	if err != nil {
+		return RainResult{}, err
+	}
+
+	return runRain(database, req, amountRaw, opts)
+}
+
+// parseMaxUsers parses a max=N option
+func parseMaxUsers(option string) (int, error) {
+	maxUsers, err := strconv.Atoi(strings.TrimPrefix(option, "max="))
+	if err != nil || maxUsers <= 0 {
+		return 0, errors.New("Invalid max users parameter")
+	}
+	return maxUsers, nil
+}
+
+// runRain splits amountRaw between up to opts.maxUsers users of req.ChatID:
+// the active ones, or those in req.Mentions if set
+func runRain(database db.Database, req Request, amountRaw uint64, opts rainOptions) (RainResult, error) {
+	targeted := req.Mentions != nil
+
+	settings, err := database.GetRainSettings(req.ChatID)
+	if err != nil {
+		return RainResult{}, errors.New("Error loading rain settings")
+	}
+
+	// Enforce minimum
+	price, err := constants.PRICE.Get()
+	if err != nil {
+		return RainResult{}, err
+	}
+	rainMinRaw, err := util.USDToRaw(math.Max(0, settings.MinAmountUSD-0.01), price) // $0.01 threshold
+	if err != nil {
+		return RainResult{}, err
+	}
+	if amountRaw < rainMinRaw {
+		return RainResult{}, fmt.Errorf(
+			"Rain amount must be at least $%.2f (%.9f IVY)", settings.MinAmountUSD, float64(rainMinRaw)/constants.IVY_FACTOR,
+		)
+	}
+
+	// Ensure sender exists in database
+	database.EnsureUserExists(req.CallerID)
+
+	// Check sender's balance
+	senderBalanceRaw, err := database.GetUserBalanceRaw(req.CallerID)
+	if err != nil {
+		return RainResult{}, errors.New("Error checking balance")
+	}
+	if senderBalanceRaw < amountRaw {
+		return RainResult{}, &InsufficientBalanceError{BalanceRaw: senderBalanceRaw}
+	}
+
+	// Get active users, minus the sender
+	var eligibleUsers []string
+	if opts.activeOnly {
+		eligibleUsers, err = eligibleRainUsers(database, req.ChatID, req.CallerID)
+		if err != nil {
+			return RainResult{}, errors.New("Error finding active users")
+		}
+		if len(eligibleUsers) == 0 {
+			return RainResult{}, fmt.Errorf("No active users found. Users need an activity score of %d+ to receive rain.", settings.ActivityRequirement)
+		}
+	}
+	if targeted {
+		eligibleUsers = targetRainUsers(req.Mentions, req.CallerID, eligibleUsers, opts.activeOnly)
+		if len(eligibleUsers) == 0 {
+			return RainResult{}, errors.New("None of the targeted users can receive this rain")
+		}
+	} else if len(eligibleUsers) < settings.MinActiveCount {
+		return RainResult{}, fmt.Errorf("Rain needs at least %d active users, only %d are active right now.", settings.MinActiveCount, len(eligibleUsers))
+	}
+
+	// Bound by maximum users
+	if len(eligibleUsers) > opts.maxUsers {
+		eligibleUsers = eligibleUsers[:opts.maxUsers]
+	}
+
+	// Every RAW unit goes to someone, whichever way it's split
+	var amountsRaw []uint64
+	if opts.weighted {
+		scores, err := database.GetActivityScores(req.ChatID)
+		if err != nil {
+			return RainResult{}, errors.New("Error loading activity scores")
+		}
+		// Targeted users who haven't been active still get a share
+		weights := make([]uint64, len(eligibleUsers))
+		for i, userID := range eligibleUsers {
+			weights[i] = uint64(max(scores[userID], 1))
+		}
+		amountsRaw = splitWeighted(amountRaw, weights)
+	} else {
+		amountsRaw = splitEven(amountRaw, len(eligibleUsers))
+	}
+	if slices.Contains(amountsRaw, 0) {
+		return RainResult{}, errors.New("Rain amount is too small to give every user something")
+	}
+
+	if err := requireConfirmation(req, ACTION_RAIN, amountRaw); err != nil {
+		return RainResult{}, err
+	}
+
+	// Process the rain transaction
+	err = database.ProcessRain(req.CallerID, eligibleUsers, amountsRaw, senderBalanceRaw)
+	if err != nil {
+		return RainResult{}, fmt.Errorf("Error processing rain: %v", err)
+	}
+
+	// Get new balances for notifications
+	newBalanceRaw, _ := database.GetUserBalanceRaw(req.CallerID)
+	recipientBalancesRaw := make([]uint64, len(eligibleUsers))
+	for i, recipientID := range eligibleUsers {
+		recipientBalancesRaw[i], _ = database.GetUserBalanceRaw(recipientID)
+	}
+
+	return RainResult{
+		SenderID:             req.CallerID,
+		AmountRaw:            amountRaw,
+		AmountPerUserRaw:     slices.Min(amountsRaw),
+		Weighted:             opts.weighted,
+		SenderBalanceRaw:     newBalanceRaw,
+		Recipients:           eligibleUsers,
+		RecipientAmountsRaw:  amountsRaw,
+		RecipientBalancesRaw: recipientBalancesRaw,
+	}, nil
+}
+
+type RainCheckResult struct {
+	// Users who would currently receive the caller's rain
+	EligibleCount int
+	Settings      db.RainSettings
+}
+
+// RainCheck counts how many users in serverID would currently receive the caller's rain
+func RainCheck(database db.Database, serverID string, callerID string) (RainCheckResult, error) {
+	settings, err := database.GetRainSettings(serverID)
+	if err != nil {
+		return RainCheckResult{}, errors.New("Error loading rain settings")
+	}
+	eligibleUsers, err := eligibleRainUsers(database, serverID, callerID)
+	if err != nil {
+		return RainCheckResult{}, errors.New("Error checking active users")
+	}
+	return RainCheckResult{EligibleCount: len(eligibleUsers), Settings: settings}, nil
+}
+
+// eligibleRainUsers returns the active users of a server, minus the sender
+func eligibleRainUsers(database db.Database, serverID string, senderID string) ([]string, error) {
+	activeUsers, err := database.GetActiveUsersForRain(serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Can't rain on yourself
+	var eligibleUsers []string
+	for _, userID := range activeUsers {
+		if userID != senderID {
+			eligibleUsers = append(eligibleUsers, userID)
+		}
+	}
+	return eligibleUsers, nil
+}
+
+// targetRainUsers returns the targeted users minus the sender and repeats,
+// keeping only the active ones if activeOnly is set
+func targetRainUsers(targets []string, senderID string, activeUsers []string, activeOnly bool) []string {
+	active := make(map[string]bool, len(activeUsers))
+	for _, userID := range activeUsers {
+		active[userID] = true
+	}
+
+	var users []string
+	seen := make(map[string]bool, len(targets))
+	for _, userID := range targets {
+		if userID == senderID || seen[userID] || (activeOnly && !active[userID]) {
+			continue
+		}
+		seen[userID] = true
+		users = append(users, userID)
+	}
+	return users
+}
@@ -0,0 +1,202 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+	"github.com/ivypowered/ivy-sprite-bot/util"
+)
+
+// Shortest interval a rain can repeat at
+const RAIN_SCHEDULE_MIN_INTERVAL = time.Hour
+
+// Most scheduled rains one user can have
+const RAIN_SCHEDULE_MAX_PER_USER = 5
+
+// ScheduleRain sets up a rain on req.ChatID that repeats until cancelled:
+// Args = [amount, "every", interval, (max=N)]. The first run is one
+// interval from now.
+func ScheduleRain(database db.Database, req Request) (db.ScheduledRain, error) {
+	if req.Private || req.ChatID == "" {
+		return db.ScheduledRain{}, ErrGroupOnly
+	}
+	if len(req.Args) < 3 || len(req.Args) > 4 || req.Args[1] != "every" {
+		return db.ScheduledRain{}, ErrUsage
+	}
+
+	amountRaw, err := req.parseAmount()
+	if err != nil {
+		return db.ScheduledRain{}, err
+	}
+	interval, err := time.ParseDuration(req.Args[2])
+	if err != nil {
+		return db.ScheduledRain{}, errors.New("Please enter a valid interval, like 24h or 90m")
+	}
+	if interval < RAIN_SCHEDULE_MIN_INTERVAL {
+		return db.ScheduledRain{}, fmt.Errorf("Rains can repeat at most every %s", RAIN_SCHEDULE_MIN_INTERVAL)
+	}
+	maxUsers := 0
+	if len(req.Args) == 4 {
+		if !strings.HasPrefix(req.Args[3], "max=") {
+			return db.ScheduledRain{}, ErrUsage
+		}
+		maxUsers, err = parseMaxUsers(req.Args[3])
+		if err != nil {
+			return db.ScheduledRain{}, err
+		}
+	}
+
+	// Catch rains that could never run now, rather than at their first run
+	settings, err := database.GetRainSettings(req.ChatID)
+	if err != nil {
+		return db.ScheduledRain{}, errors.New("Error loading rain settings")
+	}
+	price, err := constants.PRICE.Get()
+	if err != nil {
+		return db.ScheduledRain{}, err
+	}
+	rainMinRaw, err := util.USDToRaw(math.Max(0, settings.MinAmountUSD-0.01), price)
+	if err != nil {
+		return db.ScheduledRain{}, err
+	}
+	if amountRaw < rainMinRaw {
+		return db.ScheduledRain{}, fmt.Errorf(
+			"Rain amount must be at least $%.2f (%.9f IVY)", settings.MinAmountUSD, float64(rainMinRaw)/constants.IVY_FACTOR,
+		)
+	}
+
+	database.EnsureUserExists(req.CallerID)
+	balanceRaw, err := database.GetUserBalanceRaw(req.CallerID)
+	if err != nil {
+		return db.ScheduledRain{}, errors.New("Error checking balance")
+	}
+	if balanceRaw < amountRaw {
+		return db.ScheduledRain{}, &InsufficientBalanceError{BalanceRaw: balanceRaw}
+	}
+
+	existing, err := database.ListScheduledRains(req.CallerID)
+	if err != nil {
+		return db.ScheduledRain{}, errors.New("Error loading scheduled rains")
+	}
+	if len(existing) >= RAIN_SCHEDULE_MAX_PER_USER {
+		return db.ScheduledRain{}, fmt.Errorf("You can have at most %d scheduled rains, cancel one first", RAIN_SCHEDULE_MAX_PER_USER)
+	}
+
+	if err := requireConfirmation(req, ACTION_RAIN_SCHEDULE, amountRaw); err != nil {
+		return db.ScheduledRain{}, err
+	}
+	// Runs only skip confirmation up to what the owner agreed to here
+	var confirmedRaw uint64
+	if req.Confirmed {
+		confirmedRaw = amountRaw
+	}
+
+	rain := db.ScheduledRain{
+		OwnerID:         req.CallerID,
+		ServerID:        req.ChatID,
+		AmountRaw:       amountRaw,
+		IntervalSeconds: int64(interval / time.Second),
+		MaxUsers:        maxUsers,
+		ConfirmedRaw:    confirmedRaw,
+		NextRun:         time.Now().Add(interval).Unix(),
+	}
+	rain.ScheduleID, err = database.CreateScheduledRain(rain)
+	if err != nil {
+		return db.ScheduledRain{}, errors.New("Error saving scheduled rain")
+	}
+	return rain, nil
+}
+
+// ListScheduledRains returns the caller's scheduled rains
+func ListScheduledRains(database db.Database, req Request) ([]db.ScheduledRain, error) {
+	rains, err := database.ListScheduledRains(req.CallerID)
+	if err != nil {
+		return nil, errors.New("Error loading scheduled rains")
+	}
+	return rains, nil
+}
+
+// CancelScheduledRain deletes one of the caller's scheduled rains: Args = [id]
+func CancelScheduledRain(database db.Database, req Request) error {
+	scheduleID, err := parseScheduleID(req.Args)
+	if err != nil {
+		return err
+	}
+	ok, err := database.DeleteScheduledRain(scheduleID, req.CallerID)
+	if err != nil {
+		return errors.New("Error cancelling scheduled rain")
+	}
+	if !ok {
+		return fmt.Errorf("You have no scheduled rain #%d", scheduleID)
+	}
+	return nil
+}
+
+// ResumeScheduledRain restarts one of the caller's paused rains one interval
+// from now, returning when it will next run: Args = [id]
+func ResumeScheduledRain(database db.Database, req Request) (int64, error) {
+	scheduleID, err := parseScheduleID(req.Args)
+	if err != nil {
+		return 0, err
+	}
+	rains, err := database.ListScheduledRains(req.CallerID)
+	if err != nil {
+		return 0, errors.New("Error loading scheduled rains")
+	}
+	for _, rain := range rains {
+		if rain.ScheduleID != scheduleID {
+			continue
+		}
+		nextRun := time.Now().Unix() + rain.IntervalSeconds
+		ok, err := database.ResumeScheduledRain(scheduleID, req.CallerID, nextRun)
+		if err != nil {
+			return 0, errors.New("Error resuming scheduled rain")
+		}
+		if !ok {
+			return 0, fmt.Errorf("Scheduled rain #%d isn't paused", scheduleID)
+		}
+		return nextRun, nil
+	}
+	return 0, fmt.Errorf("You have no scheduled rain #%d", scheduleID)
+}
+
+func parseScheduleID(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, ErrUsage
+	}
+	scheduleID, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		return 0, errors.New("Please enter a valid scheduled rain ID")
+	}
+	return scheduleID, nil
+}
+
+// NextScheduledRun returns a schedule's first run time after now. Runs
+// missed while the bot was down are skipped, not caught up on.
+func NextScheduledRun(rain db.ScheduledRain, now int64) int64 {
+	missed := (now-rain.NextRun)/rain.IntervalSeconds + 1
+	return rain.NextRun + missed*rain.IntervalSeconds
+}
+
+// RunScheduledRain rains on the current active users of a schedule's server
+// on behalf of its owner. Runs the owner didn't confirm are held back like
+// any other rain, with a ConfirmationRequiredError.
+func RunScheduledRain(database db.Database, rain db.ScheduledRain) (RainResult, error) {
+	maxUsers := math.MaxInt
+	if rain.MaxUsers > 0 {
+		maxUsers = rain.MaxUsers
+	}
+	req := Request{
+		CallerID: rain.OwnerID,
+		ChatID:   rain.ServerID,
+		// The owner agreed to runs of up to the amount they confirmed
+		Confirmed: rain.ConfirmedRaw != 0 && rain.AmountRaw <= rain.ConfirmedRaw,
+	}
+	return runRain(database, req, rain.AmountRaw, rainOptions{maxUsers: maxUsers, activeOnly: true})
+}
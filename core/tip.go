@@ -0,0 +1,282 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+var TELEGRAM_ID_REGEX = regexp.MustCompile(`^tg:\d+$`)
+
+type TransferResult struct {
+	SenderID            string
+	RecipientID         string
+	AmountRaw           uint64
+	SenderBalanceRaw    uint64
+	RecipientBalanceRaw uint64
+}
+
+// Tip sends funds to the single mentioned user: Mentions = [recipient], Args = [amount]
+func Tip(database db.Database, req Request) (TransferResult, error) {
+	if len(req.Mentions) != 1 || len(req.Args) != 1 {
+		return TransferResult{}, ErrUsage
+	}
+	amountRaw, err := req.parseAmount()
+	if err != nil {
+		return TransferResult{}, err
+	}
+	if err := checkTransfer(database, req.CallerID, req.Mentions[0], amountRaw); err != nil {
+		return TransferResult{}, err
+	}
+	if err := requireConfirmation(req, ACTION_TIP, amountRaw); err != nil {
+		return TransferResult{}, err
+	}
+	return transfer(database, req.CallerID, req.Mentions[0], amountRaw, db.LEDGER_TIP)
+}
+
+// Move sends funds to the caller's account on the other platform: Args = [amount, id]
+func Move(database db.Database, req Request) (TransferResult, error) {
+	if !req.Private {
+		return TransferResult{}, ErrPrivateOnly
+	}
+	if len(req.Args) != 2 {
+		return TransferResult{}, ErrUsage
+	}
+
+	var recipientID string
+	if IsTelegramID(req.CallerID) {
+		// Moving to Discord, expect a numeric Discord ID
+		discordID, err := strconv.ParseUint(req.Args[1], 10, 64)
+		if err != nil {
+			return TransferResult{}, errors.New("Please enter a valid Discord ID (numbers only)")
+		}
+		recipientID = strconv.FormatUint(discordID, 10)
+	} else {
+		// Moving to Telegram, expect a tg: ID
+		if !TELEGRAM_ID_REGEX.MatchString(req.Args[1]) {
+			return TransferResult{}, errors.New("Please enter a valid Telegram ID, like tg:123456789")
+		}
+		recipientID = req.Args[1]
+	}
+
+	amountRaw, err := parseAmountRaw(req.Args[0])
+	if err != nil {
+		return TransferResult{}, err
+	}
+	if err := checkTransfer(database, req.CallerID, recipientID, amountRaw); err != nil {
+		return TransferResult{}, err
+	}
+	return transfer(database, req.CallerID, recipientID, amountRaw, db.LEDGER_MOVE)
+}
+
+// checkTransfer makes sure a transfer can go ahead before anything is asked
+// of the caller
+func checkTransfer(database db.Database, senderID, recipientID string, amountRaw uint64) error {
+	// Don't allow tipping yourself
+	if senderID == recipientID {
+		return errors.New("You cannot send funds to yourself!")
+	}
+
+	if err := ensureRecipient(database, senderID, recipientID); err != nil {
+		return err
+	}
+
+	// Ensure sender exists in database
+	database.EnsureUserExists(senderID)
+
+	// Check sender's balance
+	senderBalanceRaw, err := database.GetUserBalanceRaw(senderID)
+	if err != nil {
+		return errors.New("Error checking balance")
+	}
+	if senderBalanceRaw < amountRaw {
+		return &InsufficientBalanceError{BalanceRaw: senderBalanceRaw}
+	}
+	return nil
+}
+
+// transfer moves funds checked by checkTransfer
+func transfer(database db.Database, senderID, recipientID string, amountRaw uint64, kind db.LedgerKind) (TransferResult, error) {
+	err := database.TransferFundsRaw(senderID, recipientID, amountRaw, kind)
+	if errors.Is(err, db.ErrInsufficientBalance) {
+		// The balance changed since it was checked
+		senderBalanceRaw, _ := database.GetUserBalanceRaw(senderID)
+		return TransferResult{}, &InsufficientBalanceError{BalanceRaw: senderBalanceRaw}
+	}
+	if err != nil {
+		return TransferResult{}, fmt.Errorf("Error processing transfer: %v", err)
+	}
+
+	// Get new balances
+	newSenderBalanceRaw, _ := database.GetUserBalanceRaw(senderID)
+	newRecipientBalanceRaw, _ := database.GetUserBalanceRaw(recipientID)
+
+	return TransferResult{
+		SenderID:            senderID,
+		RecipientID:         recipientID,
+		AmountRaw:           amountRaw,
+		SenderBalanceRaw:    newSenderBalanceRaw,
+		RecipientBalanceRaw: newRecipientBalanceRaw,
+	}, nil
+}
+
+// ensureRecipient makes sure a recipient has an account to be credited
+func ensureRecipient(database db.Database, senderID, recipientID string) error {
+	if samePlatform(senderID, recipientID) {
+		// The platform just showed us this user, so they're real
+		database.EnsureUserExists(recipientID)
+		return nil
+	}
+	// Don't create users on the other platform, the ID might be wrong
+	extant, err := database.IsUserExtant(recipientID)
+	if err != nil {
+		return fmt.Errorf("Error querying db: %v", err)
+	}
+	if !extant {
+		if IsTelegramID(recipientID) {
+			return fmt.Errorf("Telegram user %s not found, make sure they have run /balance at least once", recipientID)
+		}
+		return fmt.Errorf("Discord user %s not found in Ivy Sprite. Make sure they have used the bot in Discord first.", recipientID)
+	}
+	return nil
+}
+
+// Most users a single tip can go to
+const TIP_MAX_RECIPIENTS = 25
+
+// How TipMany interprets its amount
+const (
+	// Every recipient gets the amount
+	TIP_EACH = "each"
+	// The amount is divided between the recipients
+	TIP_SPLIT = "split"
+)
+
+type MultiTipResult struct {
+	SenderID string
+	// Total debited from the sender
+	AmountRaw uint64
+	// Smallest amount a recipient got
+	AmountPerUserRaw uint64
+	SenderBalanceRaw uint64
+	Recipients       []string
+	// What each recipient got, in the same order as Recipients
+	RecipientAmountsRaw []uint64
+	// New balance of each recipient, in the same order as Recipients
+	RecipientBalancesRaw []uint64
+}
+
+// tipAmounts works out what each of n recipients gets for a TipMany amount.
+// A split hands out every RAW, so the first recipients may get one more.
+func tipAmounts(amountRaw uint64, n int, mode string) ([]uint64, error) {
+	if mode == TIP_SPLIT {
+		amountsRaw := splitEven(amountRaw, n)
+		if slices.Contains(amountsRaw, 0) {
+			return nil, errors.New("Amount too small to split")
+		}
+		return amountsRaw, nil
+	}
+	if amountRaw*uint64(n)/uint64(n) != amountRaw {
+		return nil, errors.New("Amount too large")
+	}
+	amountsRaw := make([]uint64, n)
+	for i := range amountsRaw {
+		amountsRaw[i] = amountRaw
+	}
+	return amountsRaw, nil
+}
+
+// TipMany sends funds to every mentioned user in one transaction:
+// Mentions = recipients, Args = [amount, each|split]
+func TipMany(database db.Database, req Request) (MultiTipResult, error) {
+	if len(req.Mentions) == 0 || len(req.Args) != 2 {
+		return MultiTipResult{}, ErrUsage
+	}
+	mode := req.Args[1]
+	if mode != TIP_EACH && mode != TIP_SPLIT {
+		return MultiTipResult{}, ErrUsage
+	}
+
+	// Mentioning someone twice doesn't tip them twice
+	var recipients []string
+	seen := make(map[string]bool)
+	for _, recipientID := range req.Mentions {
+		if seen[recipientID] {
+			continue
+		}
+		seen[recipientID] = true
+		if recipientID == req.CallerID {
+			return MultiTipResult{}, errors.New("You cannot send funds to yourself!")
+		}
+		recipients = append(recipients, recipientID)
+	}
+	if len(recipients) > TIP_MAX_RECIPIENTS {
+		return MultiTipResult{}, fmt.Errorf("You can tip at most %d users at once", TIP_MAX_RECIPIENTS)
+	}
+
+	amountRaw, err := req.parseAmount()
+	if err != nil {
+		return MultiTipResult{}, err
+	}
+	amountsRaw, err := tipAmounts(amountRaw, len(recipients), mode)
+	if err != nil {
+		return MultiTipResult{}, err
+	}
+	totalRaw := amountRaw * uint64(len(recipients))
+	if mode == TIP_SPLIT {
+		totalRaw = amountRaw
+	}
+
+	for _, recipientID := range recipients {
+		if err := ensureRecipient(database, req.CallerID, recipientID); err != nil {
+			return MultiTipResult{}, err
+		}
+	}
+
+	// Ensure sender exists in database
+	database.EnsureUserExists(req.CallerID)
+
+	// Check sender's balance
+	senderBalanceRaw, err := database.GetUserBalanceRaw(req.CallerID)
+	if err != nil {
+		return MultiTipResult{}, errors.New("Error checking balance")
+	}
+	if senderBalanceRaw < totalRaw {
+		return MultiTipResult{}, &InsufficientBalanceError{BalanceRaw: senderBalanceRaw}
+	}
+
+	if err := requireConfirmation(req, ACTION_TIP, totalRaw); err != nil {
+		return MultiTipResult{}, err
+	}
+
+	err = database.TransferFundsManyRaw(req.CallerID, recipients, amountsRaw, db.LEDGER_TIP)
+	if errors.Is(err, db.ErrInsufficientBalance) {
+		// The balance changed since the check above
+		senderBalanceRaw, _ = database.GetUserBalanceRaw(req.CallerID)
+		return MultiTipResult{}, &InsufficientBalanceError{BalanceRaw: senderBalanceRaw}
+	}
+	if err != nil {
+		return MultiTipResult{}, fmt.Errorf("Error processing transfer: %v", err)
+	}
+
+	// Get new balances for notifications
+	newBalanceRaw, _ := database.GetUserBalanceRaw(req.CallerID)
+	recipientBalancesRaw := make([]uint64, len(recipients))
+	for i, recipientID := range recipients {
+		recipientBalancesRaw[i], _ = database.GetUserBalanceRaw(recipientID)
+	}
+
+	return MultiTipResult{
+		SenderID:             req.CallerID,
+		AmountRaw:            totalRaw,
+		AmountPerUserRaw:     slices.Min(amountsRaw),
+		SenderBalanceRaw:     newBalanceRaw,
+		Recipients:           recipients,
+		RecipientAmountsRaw:  amountsRaw,
+		RecipientBalancesRaw: recipientBalancesRaw,
+	}, nil
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestTipAmounts(t *testing.T) {
+	tests := []struct {
+		amountRaw uint64
+		n         int
+		mode      string
+		want      []uint64
+		wantErr   bool
+	}{
+		{10, 3, TIP_EACH, []uint64{10, 10, 10}, false},
+		{9, 3, TIP_SPLIT, []uint64{3, 3, 3}, false},
+		// The remainder goes to the first recipients, not back to the sender
+		{10, 3, TIP_SPLIT, []uint64{4, 3, 3}, false},
+		{11, 3, TIP_SPLIT, []uint64{4, 4, 3}, false},
+		{2, 3, TIP_SPLIT, nil, true},
+		{math.MaxUint64, 2, TIP_EACH, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := tipAmounts(tt.amountRaw, tt.n, tt.mode)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("tipAmounts(%d, %d, %s) = %v, want an error", tt.amountRaw, tt.n, tt.mode, got)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("tipAmounts(%d, %d, %s) = %v, %v, want %v", tt.amountRaw, tt.n, tt.mode, got, err, tt.want)
+		}
+		if tt.mode == TIP_SPLIT && sum(got) != tt.amountRaw {
+			t.Errorf("tipAmounts(%d, %d, split) lost %d RAW", tt.amountRaw, tt.n, tt.amountRaw-sum(got))
+		}
+	}
+}
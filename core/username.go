@@ -0,0 +1,30 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// ResolveTelegramUsername finds the database ID of the user behind an
+// @username, refusing when the bot can't be sure who currently holds it
+func ResolveTelegramUsername(database db.Database, username string) (string, error) {
+	username = strings.TrimPrefix(username, "@")
+	users, err := database.LookupTelegramUsername(username)
+	if err != nil {
+		return "", fmt.Errorf("Error looking up @%s", username)
+	}
+	if len(users) == 0 {
+		return "", fmt.Errorf("I haven't seen @%s yet. They need to send a message where I can see it first, or you can reply to one of their messages with /tip", username)
+	}
+	if len(users) > 1 {
+		return "", fmt.Errorf("@%s has been used by more than one account recently. Reply to one of their messages with /tip instead", username)
+	}
+	if time.Since(time.Unix(users[0].SeenAt, 0)) > constants.TELEGRAM_USERNAME_MAX_AGE {
+		return "", fmt.Errorf("I haven't seen @%s in a while, so it may belong to someone else now. Reply to one of their messages with /tip instead", username)
+	}
+	return users[0].UserID, nil
+}
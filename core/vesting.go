@@ -0,0 +1,156 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// Shortest and longest a vesting schedule can run
+const (
+	VESTING_MIN_DURATION = time.Hour
+	VESTING_MAX_DURATION = 4 * 365 * 24 * time.Hour
+)
+
+// parseLongDuration parses a duration like time.ParseDuration, but also
+// accepts whole days, like 30d
+func parseLongDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseUint(days, 10, 16)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// FormatLongDuration writes whole days as 30d, and anything else like
+// time.Duration.String
+func FormatLongDuration(d time.Duration) string {
+	if d > 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+// Vest locks an amount from the caller and releases it to Mentions[0] over
+// time: Args = [amount, "over", duration] or
+// [amount, "over", duration, "cliff", cliff]
+func Vest(database db.Database, req Request) (db.Vesting, error) {
+	if len(req.Mentions) != 1 || (len(req.Args) != 3 && len(req.Args) != 5) {
+		return db.Vesting{}, ErrUsage
+	}
+	if !strings.EqualFold(req.Args[1], "over") || (len(req.Args) == 5 && !strings.EqualFold(req.Args[3], "cliff")) {
+		return db.Vesting{}, ErrUsage
+	}
+	recipientID := req.Mentions[0]
+	if recipientID == req.CallerID {
+		return db.Vesting{}, errors.New("You cannot send funds to yourself!")
+	}
+
+	amountRaw, err := req.parseAmount()
+	if err != nil {
+		return db.Vesting{}, err
+	}
+	duration, err := parseLongDuration(req.Args[2])
+	if err != nil {
+		return db.Vesting{}, errors.New("Please enter a valid duration, like 30d or 12h")
+	}
+	if duration < VESTING_MIN_DURATION || duration > VESTING_MAX_DURATION {
+		return db.Vesting{}, fmt.Errorf("Vesting must run between %s and %s", FormatLongDuration(VESTING_MIN_DURATION), FormatLongDuration(VESTING_MAX_DURATION))
+	}
+	var cliff time.Duration
+	if len(req.Args) == 5 {
+		cliff, err = parseLongDuration(req.Args[4])
+		if err != nil {
+			return db.Vesting{}, errors.New("Please enter a valid cliff, like 7d or 12h")
+		}
+		if cliff > duration {
+			return db.Vesting{}, errors.New("The cliff can't be longer than the vesting itself")
+		}
+	}
+
+	if err := ensureRecipient(database, req.CallerID, recipientID); err != nil {
+		return db.Vesting{}, err
+	}
+	database.EnsureUserExists(req.CallerID)
+	balanceRaw, err := database.GetUserBalanceRaw(req.CallerID)
+	if err != nil {
+		return db.Vesting{}, errors.New("Error checking balance")
+	}
+	if balanceRaw < amountRaw {
+		return db.Vesting{}, &InsufficientBalanceError{BalanceRaw: balanceRaw}
+	}
+	if err := requireConfirmation(req, ACTION_VEST, amountRaw); err != nil {
+		return db.Vesting{}, err
+	}
+
+	now := time.Now().Unix()
+	vesting := db.Vesting{
+		SenderID:    req.CallerID,
+		RecipientID: recipientID,
+		AmountRaw:   amountRaw,
+		StartAt:     now,
+		CliffAt:     now + int64(cliff/time.Second),
+		EndAt:       now + int64(duration/time.Second),
+		CreatedAt:   now,
+	}
+	vesting.VestingID, err = database.CreateVesting(vesting)
+	if err != nil {
+		return db.Vesting{}, errors.New("Error creating vesting: your balance may have changed, please try again")
+	}
+	return vesting, nil
+}
+
+// VestedRaw returns how much of a vesting has unlocked at now: nothing
+// before the cliff, then a share of the amount growing linearly from
+// StartAt to EndAt
+func VestedRaw(vesting db.Vesting, now int64) uint64 {
+	if now < vesting.CliffAt {
+		return 0
+	}
+	if now >= vesting.EndAt || vesting.EndAt <= vesting.StartAt {
+		return vesting.AmountRaw
+	}
+	// amount * elapsed / total, in 128 bits so it can't overflow
+	elapsed := uint64(now - vesting.StartAt)
+	total := uint64(vesting.EndAt - vesting.StartAt)
+	hi, lo := bits.Mul64(vesting.AmountRaw, elapsed)
+	quo, _ := bits.Div64(hi, lo, total)
+	return quo
+}
+
+// ReleaseVesting credits the recipient whatever of a vesting has unlocked
+// at now since the last release. Returns the vesting as released, and
+// whether anything was credited.
+func ReleaseVesting(database db.Database, vesting db.Vesting, now int64) (db.Vesting, bool, error) {
+	vestedRaw := VestedRaw(vesting, now)
+	if vestedRaw <= vesting.ReleasedRaw {
+		return vesting, false, nil
+	}
+	ok, err := database.ReleaseVesting(vesting.VestingID, vesting.ReleasedRaw, vestedRaw)
+	if err != nil || !ok {
+		return vesting, false, err
+	}
+	vesting.ReleasedRaw = vestedRaw
+	return vesting, true, nil
+}
+
+// LockedRaw returns how much of a user's incoming vestings is still locked
+func LockedRaw(database db.Database, userID string) (uint64, error) {
+	vestings, err := database.ListLockedVestings(userID)
+	if err != nil {
+		return 0, err
+	}
+	var lockedRaw uint64
+	for _, vesting := range vestings {
+		lockedRaw += vesting.AmountRaw - vesting.ReleasedRaw
+	}
+	return lockedRaw, nil
+}
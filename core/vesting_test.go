@@ -0,0 +1,61 @@
+package core
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+func TestVestedRaw(t *testing.T) {
+	vesting := db.Vesting{AmountRaw: 1000, StartAt: 100, CliffAt: 150, EndAt: 300}
+	tests := []struct {
+		now  int64
+		want uint64
+	}{
+		{0, 0},
+		{149, 0},
+		{150, 250},
+		{200, 500},
+		{299, 995},
+		{300, 1000},
+		{1000, 1000},
+	}
+	for _, tt := range tests {
+		if got := VestedRaw(vesting, tt.now); got != tt.want {
+			t.Errorf("VestedRaw at %d = %d, want %d", tt.now, got, tt.want)
+		}
+	}
+
+	// Large amounts over long schedules don't overflow
+	vesting = db.Vesting{AmountRaw: math.MaxUint64, StartAt: 0, EndAt: 4}
+	if got := VestedRaw(vesting, 2); got != math.MaxUint64/2 {
+		t.Errorf("VestedRaw of a huge amount = %d", got)
+	}
+}
+
+func TestParseLongDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"30d", 30 * 24 * time.Hour},
+		{"12h", 12 * time.Hour},
+		{"90m", 90 * time.Minute},
+	}
+	for _, tt := range tests {
+		got, err := parseLongDuration(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseLongDuration(%q) = %v, %v", tt.in, got, err)
+		}
+		if tt.want%(24*time.Hour) == 0 && FormatLongDuration(got) != tt.in {
+			t.Errorf("FormatLongDuration(%v) = %s", got, FormatLongDuration(got))
+		}
+	}
+	for _, in := range []string{"d", "-1d", "1.5d", "x"} {
+		if _, err := parseLongDuration(in); err == nil {
+			t.Errorf("parseLongDuration(%q) succeeded", in)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+	"github.com/ivypowered/ivy-sprite-bot/util"
+)
+
+type WithdrawResult struct {
+	WithdrawID string
+	AmountRaw  uint64
+	// Hex-encoded withdrawal authority signature
+	Signature  string
+	BalanceRaw uint64
+}
+
+type CancelWithdrawalResult struct {
+	WithdrawID string
+	AmountRaw  uint64
+	BalanceRaw uint64
+}
+
+// WithdrawURL returns the page where a user claims their withdrawal
+func WithdrawURL(withdrawID, userID, name, signature string) string {
+	return fmt.Sprintf(
+		"https://sprite.ivypowered.com/withdraw?withdraw_id=%s&user_id=%s&name=%s&authority=%s&signature=%s",
+		withdrawID,
+		userID,
+		url.QueryEscape(name),
+		constants.WithdrawAuthorityB58(),
+		signature,
+	)
+}
+
+// CreateWithdrawal debits the caller and signs a withdrawal voucher: Args = [amount, address]
+func CreateWithdrawal(database db.Database, req Request) (WithdrawResult, error) {
+	if !req.Private {
+		return WithdrawResult{}, ErrPrivateOnly
+	}
+	if len(req.Args) != 2 {
+		return WithdrawResult{}, ErrUsage
+	}
+
+	amountRaw, err := req.parseAmount()
+	if err != nil {
+		return WithdrawResult{}, err
+	}
+
+	userKey, err := solana.PublicKeyFromBase58(req.Args[1])
+	if err != nil {
+		return WithdrawResult{}, errors.New("Please enter a valid base58-encoded Solana address")
+	}
+
+	database.EnsureUserExists(req.CallerID)
+
+	balanceRaw, err := database.GetUserBalanceRaw(req.CallerID)
+	if err != nil {
+		return WithdrawResult{}, errors.New("Error checking balance")
+	}
+	if balanceRaw < amountRaw {
+		return WithdrawResult{}, &InsufficientBalanceError{BalanceRaw: balanceRaw}
+	}
+	if err := requireConfirmation(req, ACTION_WITHDRAW, amountRaw); err != nil {
+		return WithdrawResult{}, err
+	}
+
+	// Generate withdrawal ID
+	withdrawIDBytes := util.GenerateID(amountRaw)
+	withdrawID := hex.EncodeToString(withdrawIDBytes[:])
+
+	// Sign withdrawal
+	signature := util.SignWithdrawal(constants.SPRITE_VAULT, userKey, withdrawIDBytes, constants.WithdrawAuthorityKey())
+	signatureHex := hex.EncodeToString(signature[:])
+
+	// Create withdrawal and debit user atomically
+	err = database.CreateWithdrawal(withdrawID, req.CallerID, balanceRaw, amountRaw, signatureHex)
+	if err != nil {
+		return WithdrawResult{}, fmt.Errorf("Error processing withdrawal: %v", err)
+	}
+
+	newBalanceRaw, _ := database.GetUserBalanceRaw(req.CallerID)
+	return WithdrawResult{
+		WithdrawID: withdrawID,
+		AmountRaw:  amountRaw,
+		Signature:  signatureHex,
+		BalanceRaw: newBalanceRaw,
+	}, nil
+}
+
+// ListWithdrawals returns the caller's recent withdrawals
+func ListWithdrawals(database db.Database, req Request) ([]db.Withdrawal, error) {
+	if !req.Private {
+		return nil, ErrPrivateOnly
+	}
+	withdrawals, err := database.ListWithdrawals(req.CallerID, 10)
+	if err != nil {
+		return nil, errors.New("Error fetching withdrawals")
+	}
+	return withdrawals, nil
+}
+
+// CancelWithdrawal refunds a withdrawal whose voucher hasn't been claimed
+// on-chain, and revokes the voucher: Args = [id prefix]
+func CancelWithdrawal(database db.Database, req Request) (CancelWithdrawalResult, error) {
+	if !req.Private {
+		return CancelWithdrawalResult{}, ErrPrivateOnly
+	}
+	if len(req.Args) != 1 {
+		return CancelWithdrawalResult{}, ErrUsage
+	}
+
+	withdrawal, err := database.FindWithdrawalByPrefix(req.CallerID, req.Args[0])
+	if err == sql.ErrNoRows {
+		return CancelWithdrawalResult{}, errors.New("No withdrawal found with that ID")
+	} else if err != nil {
+		return CancelWithdrawalResult{}, fmt.Errorf("Error finding withdrawal: %v", err)
+	}
+	id := withdrawal.WithdrawID
+
+	switch withdrawal.Status {
+	case db.WITHDRAWAL_CLAIMED:
+		return CancelWithdrawalResult{}, errors.New("This withdrawal has already been claimed")
+	case db.WITHDRAWAL_REVOKED:
+		return CancelWithdrawalResult{}, errors.New("This withdrawal has already been cancelled")
+	case db.WITHDRAWAL_PENDING:
+		// Claim the cancellation, so only one refund can ever be in flight
+		ok, err := database.SwapWithdrawalStatus(id, db.WITHDRAWAL_PENDING, db.WITHDRAWAL_REVOKING)
+		if err != nil {
+			return CancelWithdrawalResult{}, fmt.Errorf("Error cancelling withdrawal: %v", err)
+		}
+		if !ok {
+			return CancelWithdrawalResult{}, errors.New("This withdrawal is no longer pending")
+		}
+	case db.WITHDRAWAL_REVOKING:
+		// A previous cancellation was interrupted, pick up where it left off
+	}
+
+	id32, err := util.DecodeID(id)
+	if err != nil {
+		return CancelWithdrawalResult{}, errors.New("Invalid withdrawal ID format")
+	}
+
+	// Only refund if the voucher hasn't been used on-chain
+	claimed, err := util.AreWithdrawalsClaimed(context.Background(), constants.RPC_CLIENT, constants.SPRITE_VAULT, [][32]byte{id32})
+	if err != nil {
+		database.SwapWithdrawalStatus(id, db.WITHDRAWAL_REVOKING, db.WITHDRAWAL_PENDING)
+		return CancelWithdrawalResult{}, fmt.Errorf("Error checking withdrawal status: %v", err)
+	}
+	if claimed[0] {
+		database.SwapWithdrawalStatus(id, db.WITHDRAWAL_REVOKING, db.WITHDRAWAL_CLAIMED)
+		return CancelWithdrawalResult{}, errors.New("This withdrawal has already been claimed")
+	}
+
+	err = database.RefundWithdrawal(id)
+	if err == sql.ErrNoRows {
+		// The reconciler saw it claimed in the meantime
+		return CancelWithdrawalResult{}, errors.New("This withdrawal is no longer pending")
+	} else if err != nil {
+		return CancelWithdrawalResult{}, fmt.Errorf("Error refunding withdrawal: %v", err)
+	}
+
+	balanceRaw, _ := database.GetUserBalanceRaw(req.CallerID)
+	return CancelWithdrawalResult{
+		WithdrawID: id,
+		AmountRaw:  withdrawal.AmountRaw,
+		BalanceRaw: balanceRaw,
+	}, nil
+}
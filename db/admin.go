@@ -0,0 +1,110 @@
+package db
+
+import "database/sql"
+
+// Scope of roles that apply everywhere
+const SCOPE_GLOBAL = "global"
+
+// Role is a set of privileges a user holds within a scope
+type Role string
+
+const (
+	// May change settings in the scope, e.g. rain channels
+	ROLE_MODERATOR Role = "moderator"
+	// Everything a moderator can do, plus granting and revoking roles
+	ROLE_ADMIN Role = "admin"
+)
+
+// ROLES lists every role, least privileged first
+var ROLES = []Role{ROLE_MODERATOR, ROLE_ADMIN}
+
+func (r Role) rank() int {
+	for i, role := range ROLES {
+		if role == r {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Includes reports whether r grants at least the privileges of other
+func (r Role) Includes(other Role) bool {
+	return r.rank() > 0 && r.rank() >= other.rank()
+}
+
+// ParseRole validates a user-supplied role
+func ParseRole(role string) (Role, bool) {
+	for _, r := range ROLES {
+		if string(r) == role {
+			return r, true
+		}
+	}
+	return "", false
+}
+
+// RoleGrant is a role held by a user within a scope
+type RoleGrant struct {
+	Scope     string
+	UserID    string
+	Role      Role
+	GrantedBy string
+	Timestamp int64
+}
+
+// GetRole returns the role a user holds within a scope, "" if none
+func (db sqlDatabase) GetRole(scope, userID string) (Role, error) {
+	var role Role
+	err := db.queryRow(
+		"SELECT role FROM admins WHERE scope = ? AND user_id = ?",
+		scope, userID,
+	).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// GrantRole gives a user a role within a scope, replacing any role they had
+func (db sqlDatabase) GrantRole(scope, userID string, role Role, grantedBy string) error {
+	_, err := db.exec(
+		`INSERT INTO admins (scope, user_id, role, granted_by) VALUES (?, ?, ?, ?)
+		ON CONFLICT (scope, user_id) DO UPDATE SET role = excluded.role, granted_by = excluded.granted_by, timestamp = excluded.timestamp`,
+		scope, userID, role, grantedBy,
+	)
+	return err
+}
+
+// RevokeRole removes a user's role within a scope, reporting whether they had one
+func (db sqlDatabase) RevokeRole(scope, userID string) (bool, error) {
+	result, err := db.exec(
+		"DELETE FROM admins WHERE scope = ? AND user_id = ?",
+		scope, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	aff, err := result.RowsAffected()
+	return aff > 0, err
+}
+
+// ListRoles returns every role granted within a scope
+func (db sqlDatabase) ListRoles(scope string) ([]RoleGrant, error) {
+	rows, err := db.query(
+		"SELECT scope, user_id, role, granted_by, timestamp FROM admins WHERE scope = ? ORDER BY timestamp",
+		scope,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []RoleGrant
+	for rows.Next() {
+		var g RoleGrant
+		if err := rows.Scan(&g.Scope, &g.UserID, &g.Role, &g.GrantedBy, &g.Timestamp); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// AlertDirection is which way the price has to cross an alert's target
+type AlertDirection string
+
+const (
+	ALERT_ABOVE AlertDirection = "above"
+	ALERT_BELOW AlertDirection = "below"
+)
+
+// PriceAlert tells a user when the IVY price crosses a target
+type PriceAlert struct {
+	AlertID   int64
+	UserID    string
+	Direction AlertDirection
+	TargetUSD float64
+	// Re-armed alerts fire again after the price moves back past the target
+	Rearm bool
+	// Whether the alert will fire when the price crosses the target
+	Armed     bool
+	CreatedAt int64
+	// Unix time the alert last fired, 0 if never
+	FiredAt int64
+}
+
+const priceAlertColumns = "alert_id, user_id, direction, target_usd, rearm, armed, created_at, fired_at"
+
+func scanPriceAlerts(rows *sql.Rows) ([]PriceAlert, error) {
+	defer rows.Close()
+
+	var alerts []PriceAlert
+	for rows.Next() {
+		var a PriceAlert
+		var direction string
+		var rearm, armed int
+		err := rows.Scan(&a.AlertID, &a.UserID, &direction, &a.TargetUSD, &rearm, &armed, &a.CreatedAt, &a.FiredAt)
+		if err != nil {
+			return nil, err
+		}
+		a.Direction = AlertDirection(direction)
+		a.Rearm = rearm == 1
+		a.Armed = armed == 1
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// CreatePriceAlert stores a new, armed alert and returns its ID
+func (db sqlDatabase) CreatePriceAlert(alert PriceAlert) (int64, error) {
+	rearm := 0
+	if alert.Rearm {
+		rearm = 1
+	}
+	var alertID int64
+	err := db.queryRow(
+		`INSERT INTO price_alerts (user_id, direction, target_usd, rearm)
+		VALUES (?, ?, ?, ?) RETURNING alert_id`,
+		alert.UserID, string(alert.Direction), alert.TargetUSD, rearm,
+	).Scan(&alertID)
+	return alertID, err
+}
+
+// ListPriceAlerts returns a user's alerts, oldest first
+func (db sqlDatabase) ListPriceAlerts(userID string) ([]PriceAlert, error) {
+	rows, err := db.query(
+		"SELECT "+priceAlertColumns+" FROM price_alerts WHERE user_id = ? ORDER BY alert_id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanPriceAlerts(rows)
+}
+
+// DeletePriceAlert removes one of a user's alerts, reporting whether it existed
+func (db sqlDatabase) DeletePriceAlert(alertID int64, userID string) (bool, error) {
+	res, err := db.exec("DELETE FROM price_alerts WHERE alert_id = ? AND user_id = ?", alertID, userID)
+	if err != nil {
+		return false, err
+	}
+	aff, err := res.RowsAffected()
+	return aff > 0, err
+}
+
+// ListTriggeredPriceAlerts returns the armed alerts whose target priceUSD
+// has reached
+func (db sqlDatabase) ListTriggeredPriceAlerts(priceUSD float64) ([]PriceAlert, error) {
+	rows, err := db.query(
+		`SELECT `+priceAlertColumns+` FROM price_alerts WHERE armed = 1 AND (
+			(direction = ? AND target_usd <= ?) OR (direction = ? AND target_usd >= ?)
+		) ORDER BY alert_id`,
+		string(ALERT_ABOVE), priceUSD, string(ALERT_BELOW), priceUSD,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanPriceAlerts(rows)
+}
+
+// FirePriceAlert disarms an alert as it fires, reporting whether it was
+// still armed so it only fires once
+func (db sqlDatabase) FirePriceAlert(alertID int64) (bool, error) {
+	res, err := db.exec(
+		"UPDATE price_alerts SET armed = 0, fired_at = ? WHERE alert_id = ? AND armed = 1",
+		time.Now().Unix(), alertID,
+	)
+	if err != nil {
+		return false, err
+	}
+	aff, err := res.RowsAffected()
+	return aff > 0, err
+}
+
+// RearmPriceAlerts arms the fired re-arming alerts that priceUSD is back on
+// the other side of, returning how many were re-armed
+func (db sqlDatabase) RearmPriceAlerts(priceUSD float64) (int64, error) {
+	res, err := db.exec(
+		`UPDATE price_alerts SET armed = 1 WHERE armed = 0 AND rearm = 1 AND (
+			(direction = ? AND target_usd > ?) OR (direction = ? AND target_usd < ?)
+		)`,
+		string(ALERT_ABOVE), priceUSD, string(ALERT_BELOW), priceUSD,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,68 @@
+package db_test
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ivypowered/ivy-sprite-bot/db"
+	"github.com/ivypowered/ivy-sprite-bot/db/dbtest"
+)
+
+func TestSQLite(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.Database {
+		database, err := db.Open(db.BACKEND_SQLITE, filepath.Join(t.TempDir(), "bot.db"), dbtest.Price)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { database.Close() })
+		return database
+	})
+}
+
+// TestPostgres needs a server to run against, e.g.
+// TEST_POSTGRES_DSN="postgres://localhost/ivy_test?sslmode=disable".
+// Every test gets its own schema, dropped afterwards.
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	dbtest.Run(t, func(t *testing.T) db.Database {
+		var suffix [6]byte
+		rand.Read(suffix[:])
+		schema := "test_" + hex.EncodeToString(suffix[:])
+		if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+		database, err := db.Open(db.BACKEND_POSTGRES, withSearchPath(dsn, schema), dbtest.Price)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { database.Close() })
+		return database
+	})
+}
+
+// withSearchPath points a connection string at schema, in either DSN format
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if strings.Contains(dsn, "?") {
+			return dsn + "&search_path=" + schema
+		}
+		return dsn + "?search_path=" + schema
+	}
+	return dsn + " search_path=" + schema
+}
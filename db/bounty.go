@@ -0,0 +1,184 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// BountyStatus is where a bounty is in its lifecycle
+type BountyStatus string
+
+const (
+	// Reward held in escrow, waiting to be awarded
+	BOUNTY_OPEN BountyStatus = "open"
+	// Reward paid to the winner
+	BOUNTY_AWARDED BountyStatus = "awarded"
+	// Reward returned to the creator
+	BOUNTY_CANCELLED BountyStatus = "cancelled"
+)
+
+// Bounty is a reward escrowed from its creator for a task, until they award
+// it to someone or cancel it
+type Bounty struct {
+	BountyID  int64
+	CreatorID string
+	// Database ID of the server or group the bounty was posted in
+	ChatID      string
+	Description string
+	// Reward, held in escrow while the bounty is open
+	AmountRaw uint64
+	Status    BountyStatus
+	// "" unless awarded
+	WinnerID  string
+	CreatedAt int64
+	// Unix time the bounty was awarded or cancelled, 0 while open
+	ClosedAt int64
+}
+
+const bountyColumns = "bounty_id, creator_id, chat_id, description, amount_raw, status, winner_id, created_at, closed_at"
+
+func scanBounties(rows *sql.Rows) ([]Bounty, error) {
+	defer rows.Close()
+
+	var bounties []Bounty
+	for rows.Next() {
+		var b Bounty
+		var status string
+		err := rows.Scan(&b.BountyID, &b.CreatorID, &b.ChatID, &b.Description, &b.AmountRaw, &status, &b.WinnerID, &b.CreatedAt, &b.ClosedAt)
+		if err != nil {
+			return nil, err
+		}
+		b.Status = BountyStatus(status)
+		bounties = append(bounties, b)
+	}
+	return bounties, rows.Err()
+}
+
+// CreateBounty posts a bounty and moves its reward from the creator into
+// escrow, failing if they can't afford it. Returns the new bounty's ID.
+func (db sqlDatabase) CreateBounty(bounty Bounty) (int64, error) {
+	tx, err := db.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var bountyID int64
+	err = tx.QueryRow(
+		`INSERT INTO bounties (creator_id, chat_id, description, amount_raw, status)
+		VALUES (?, ?, ?, ?, ?) RETURNING bounty_id`,
+		bounty.CreatorID, bounty.ChatID, bounty.Description, bounty.AmountRaw, string(BOUNTY_OPEN),
+	).Scan(&bountyID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Escrow the reward, if the creator has enough
+	res, err := tx.Exec("UPDATE users SET balance_raw = balance_raw - ? WHERE user_id = ? AND balance_raw >= ?", bounty.AmountRaw, bounty.CreatorID, bounty.AmountRaw)
+	if err != nil {
+		return 0, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if aff < 1 {
+		return 0, errors.New("creator not found or balance too low")
+	}
+	if err = appendLedger(tx, newTxID(), bounty.CreatorID, LEDGER_BOUNTY, strconv.FormatInt(bountyID, 10), -int64(bounty.AmountRaw)); err != nil {
+		return 0, err
+	}
+
+	return bountyID, tx.Commit()
+}
+
+// GetBounty returns a bounty by ID, or sql.ErrNoRows
+func (db sqlDatabase) GetBounty(bountyID int64) (Bounty, error) {
+	rows, err := db.query("SELECT "+bountyColumns+" FROM bounties WHERE bounty_id = ?", bountyID)
+	if err != nil {
+		return Bounty{}, err
+	}
+	bounties, err := scanBounties(rows)
+	if err != nil {
+		return Bounty{}, err
+	}
+	if len(bounties) == 0 {
+		return Bounty{}, sql.ErrNoRows
+	}
+	return bounties[0], nil
+}
+
+// ListOpenBounties returns the open bounties of a chat, oldest first
+func (db sqlDatabase) ListOpenBounties(chatID string, limit int) ([]Bounty, error) {
+	rows, err := db.query(
+		"SELECT "+bountyColumns+" FROM bounties WHERE chat_id = ? AND status = ? ORDER BY bounty_id LIMIT ?",
+		chatID, string(BOUNTY_OPEN), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanBounties(rows)
+}
+
+// ListBountiesByCreator returns a user's bounties, newest first
+func (db sqlDatabase) ListBountiesByCreator(creatorID string, limit int) ([]Bounty, error) {
+	rows, err := db.query(
+		"SELECT "+bountyColumns+" FROM bounties WHERE creator_id = ? ORDER BY bounty_id DESC LIMIT ?",
+		creatorID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanBounties(rows)
+}
+
+// closeBounty moves one of a creator's open bounties to status and pays
+// its escrowed reward to payeeID. Reports whether the bounty was open.
+func (db sqlDatabase) closeBounty(bountyID int64, creatorID string, status BountyStatus, winnerID, payeeID string) (bool, error) {
+	tx, err := db.begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var amountRaw uint64
+	err = tx.QueryRow(
+		`UPDATE bounties SET status = ?, winner_id = ?, closed_at = ?
+		WHERE bounty_id = ? AND creator_id = ? AND status = ? RETURNING amount_raw`,
+		string(status), winnerID, time.Now().Unix(), bountyID, creatorID, string(BOUNTY_OPEN),
+	).Scan(&amountRaw)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec("INSERT INTO users (user_id) VALUES (?) ON CONFLICT DO NOTHING", payeeID)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec("UPDATE users SET balance_raw = balance_raw + ? WHERE user_id = ?", amountRaw, payeeID)
+	if err != nil {
+		return false, err
+	}
+	if err = appendLedger(tx, newTxID(), payeeID, LEDGER_BOUNTY, strconv.FormatInt(bountyID, 10), int64(amountRaw)); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// AwardBounty pays one of a creator's open bounties to winnerID, reporting
+// whether it was open
+func (db sqlDatabase) AwardBounty(bountyID int64, creatorID, winnerID string) (bool, error) {
+	return db.closeBounty(bountyID, creatorID, BOUNTY_AWARDED, winnerID, winnerID)
+}
+
+// CancelBounty returns one of a creator's open bounties to them, reporting
+// whether it was open
+func (db sqlDatabase) CancelBounty(bountyID int64, creatorID string) (bool, error) {
+	return db.closeBounty(bountyID, creatorID, BOUNTY_CANCELLED, "", creatorID)
+}
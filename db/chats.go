@@ -0,0 +1,90 @@
+package db
+
+// Server ID Telegram activity and settings were kept under before groups
+// registered individually
+const LEGACY_TELEGRAM_SERVER_ID = "telegram"
+
+// TelegramChat is a Telegram group that has opted in to rain
+type TelegramChat struct {
+	// Database ID of the chat, "tg:<chat id>"
+	ChatID       string
+	Title        string
+	RegisteredBy string
+	Timestamp    int64
+}
+
+// RegisterTelegramChat opts a group in to activity tracking and rain
+func (db sqlDatabase) RegisterTelegramChat(chatID, title, registeredBy string) error {
+	_, err := db.exec(
+		`INSERT INTO telegram_chats (chat_id, title, registered_by) VALUES (?, ?, ?)
+		ON CONFLICT (chat_id) DO UPDATE SET title = excluded.title`,
+		chatID, title, registeredBy,
+	)
+	return err
+}
+
+// UnregisterTelegramChat opts a group out, reporting whether it was registered
+func (db sqlDatabase) UnregisterTelegramChat(chatID string) (bool, error) {
+	result, err := db.exec("DELETE FROM telegram_chats WHERE chat_id = ?", chatID)
+	if err != nil {
+		return false, err
+	}
+	aff, err := result.RowsAffected()
+	return aff > 0, err
+}
+
+// IsTelegramChatRegistered reports whether a group has opted in
+func (db sqlDatabase) IsTelegramChatRegistered(chatID string) (bool, error) {
+	var count int
+	err := db.queryRow(
+		"SELECT COUNT(*) FROM telegram_chats WHERE chat_id = ?",
+		chatID,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// MigrateLegacyTelegramChat moves everything kept under the shared
+// "telegram" server ID over to chatID and registers it. It does nothing once
+// the legacy rows are gone.
+func (db sqlDatabase) MigrateLegacyTelegramChat(chatID, title string) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var legacy int
+	err = tx.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM activity WHERE server_id = ?) +
+		(SELECT COUNT(*) FROM guild_settings WHERE server_id = ?) +
+		(SELECT COUNT(*) FROM admins WHERE scope = ?)`,
+		LEGACY_TELEGRAM_SERVER_ID, LEGACY_TELEGRAM_SERVER_ID, LEGACY_TELEGRAM_SERVER_ID,
+	).Scan(&legacy)
+	if err != nil || legacy == 0 {
+		return err
+	}
+
+	// Legacy rows win over any the group already has
+	queries := []string{
+		"DELETE FROM activity WHERE server_id = ? AND user_id IN (SELECT user_id FROM activity WHERE server_id = ?)",
+		"UPDATE activity SET server_id = ? WHERE server_id = ?",
+		"DELETE FROM guild_settings WHERE server_id = ? AND EXISTS (SELECT 1 FROM guild_settings WHERE server_id = ?)",
+		"UPDATE guild_settings SET server_id = ? WHERE server_id = ?",
+		"DELETE FROM admins WHERE scope = ? AND user_id IN (SELECT user_id FROM admins WHERE scope = ?)",
+		"UPDATE admins SET scope = ? WHERE scope = ?",
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, chatID, LEGACY_TELEGRAM_SERVER_ID); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(
+		"INSERT INTO telegram_chats (chat_id, title, registered_by) VALUES (?, ?, '') ON CONFLICT DO NOTHING",
+		chatID, title,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
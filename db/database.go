@@ -8,16 +8,21 @@ import (
 	"strings"
 	"time"
 
-	"github.com/ivypowered/ivy-sprite-bot/constants"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/ivypowered/ivy-sprite-bot/price"
 )
 
+// ErrInsufficientBalance means a debit was refused because the user is
+// missing or can't afford it
+var ErrInsufficientBalance = errors.New("sender not found or balance too low")
+
 type Deposit struct {
 	DepositID string
 	UserID    string
 	Timestamp int64
 	AmountRaw uint64
 	Completed bool
+	// Stopped being watched without funds arriving
+	Expired bool
 }
 
 type Withdrawal struct {
@@ -26,104 +31,160 @@ type Withdrawal struct {
 	Timestamp  int64
 	AmountRaw  uint64
 	Signature  string
+	Status     WithdrawalStatus
 }
 
-type Database struct {
-	inner *sql.DB
-}
-
-// New creates and initializes a new database connection
-func New(path string) (Database, error) {
-	sqlDB, err := sql.Open("sqlite3", path)
-	if err != nil {
-		return Database{}, err
-	}
-
-	db := Database{inner: sqlDB}
-
-	// Initialize tables
-	if err := db.initTables(); err != nil {
-		sqlDB.Close()
-		return Database{}, err
-	}
-
-	return db, nil
-}
-
-// Close closes the database connection
-func (db Database) Close() error {
-	return db.inner.Close()
-}
-
-// initTables creates all necessary tables and indexes
-func (db Database) initTables() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			user_id TEXT PRIMARY KEY,
-			balance_raw INTEGER DEFAULT 0
-		);`,
-		`CREATE TABLE IF NOT EXISTS deposits (
-			deposit_id TEXT PRIMARY KEY,
-			user_id TEXT NOT NULL,
-			timestamp INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
-			amount_raw INTEGER NOT NULL,
-			completed INTEGER NOT NULL DEFAULT 0
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_deposit_user ON deposits(user_id);`,
-		`CREATE TABLE IF NOT EXISTS withdrawals (
-			withdraw_id TEXT PRIMARY KEY,
-			user_id TEXT NOT NULL,
-			timestamp INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
-			amount_raw INTEGER NOT NULL,
-			signature TEXT NOT NULL
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_withdrawal_user ON withdrawals(user_id);`,
-		`CREATE TABLE IF NOT EXISTS activity (
-			server_id TEXT NOT NULL,
-			user_id TEXT NOT NULL,
-			score INTEGER DEFAULT 1,
-			last_message_timestamp INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
-			PRIMARY KEY (server_id, user_id)
-		);`,
-		`CREATE INDEX IF NOT EXISTS idx_activity_server ON activity(server_id);`,
-		`CREATE TABLE IF NOT EXISTS rain_channels (
-            server_id TEXT NOT NULL,
-            channel_id TEXT NOT NULL,
-            PRIMARY KEY (server_id, channel_id)
-        );`,
-		`CREATE TABLE IF NOT EXISTS wallets (
-            wallet TEXT PRIMARY KEY,
-            user_id TEXT NOT NULL,
-            linked_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
-        );`,
-		`CREATE INDEX IF NOT EXISTS idx_wallet_user ON wallets(user_id);`,
-		`CREATE TABLE IF NOT EXISTS contest (
-            key TEXT PRIMARY KEY,
-            value TEXT NOT NULL
-        );`,
-	}
-
-	for _, query := range queries {
-		if _, err := db.inner.Exec(query); err != nil {
-			return err
-		}
-	}
+type WithdrawalStatus string
+
+const (
+	// Voucher signed, not yet redeemed on-chain
+	WITHDRAWAL_PENDING WithdrawalStatus = "pending"
+	// Voucher redeemed on-chain
+	WITHDRAWAL_CLAIMED WithdrawalStatus = "claimed"
+	// Cancellation in progress, the voucher is being checked on-chain
+	WITHDRAWAL_REVOKING WithdrawalStatus = "revoking"
+	// Cancelled and refunded, the voucher must no longer be honored
+	WITHDRAWAL_REVOKED WithdrawalStatus = "revoked"
+)
 
-	return nil
+// Database is the bot's persistent storage. Every balance change is
+// journaled to the ledger in the same transaction as the change itself.
+type Database interface {
+	Close() error
+
+	// Users and balances
+	EnsureUserExists(userID string) error
+	GetUserBalanceRaw(userID string) (uint64, error)
+	IsUserExtant(userID string) (bool, error)
+	UpdateBalanceRaw(userID string, amountRaw int64) error
+	TransferFundsRaw(senderID, recipientID string, amountRaw uint64, kind LedgerKind) error
+	TransferFundsManyRaw(senderID string, recipientIDs []string, amountsRaw []uint64, kind LedgerKind) error
+
+	// Deposits
+	CreateDeposit(depositID, userID string, amountRaw uint64) error
+	CompleteDeposit(depositID string) error
+	FindDepositByPrefix(userID, depositIDPrefix string) (string, uint64, int, error)
+	ListDeposits(userID string, limit int) ([]Deposit, error)
+	ListPendingDeposits() ([]Deposit, error)
+	ExpireDeposit(depositID string) error
+
+	// Withdrawals
+	CreateWithdrawal(withdrawID, userID string, oldBalanceRaw, amountRaw uint64, signature string) error
+	ListWithdrawals(userID string, limit int) ([]Withdrawal, error)
+	ListWithdrawalsByStatus(status WithdrawalStatus) ([]Withdrawal, error)
+	FindWithdrawalByPrefix(userID, withdrawIDPrefix string) (Withdrawal, error)
+	SwapWithdrawalStatus(withdrawID string, from, to WithdrawalStatus) (bool, error)
+	RefundWithdrawal(withdrawID string) error
+	ClawbackWithdrawal(withdrawID string) (uint64, error)
+
+	// Rain and activity
+	ProcessRain(senderID string, recipientIDs []string, amountsRaw []uint64, senderBalanceRaw uint64) error
+	GetActiveUsersForRain(serverID string) ([]string, error)
+	GetActivityScores(serverID string) (map[string]int, error)
+	UpdateActivityScore(serverID, userID string) error
+	AddRainChannel(serverID, channelID string) error
+	RemoveRainChannel(serverID, channelID string) error
+	ClearRainChannels(serverID string) error
+	GetRainChannels(serverID string) ([]string, error)
+	IsRainChannel(serverID, channelID string) (bool, error)
+	GetRainSettings(serverID string) (RainSettings, error)
+	SetRainSettings(serverID string, rs RainSettings) error
+	ResetRainSettings(serverID string) error
+	CreateScheduledRain(rain ScheduledRain) (int64, error)
+	ListScheduledRains(ownerID string) ([]ScheduledRain, error)
+	ListDueScheduledRains(now int64) ([]ScheduledRain, error)
+	ClaimScheduledRain(scheduleID, fromNextRun, toNextRun int64) (bool, error)
+	PauseScheduledRain(scheduleID int64) error
+	ResumeScheduledRain(scheduleID int64, ownerID string, nextRun int64) (bool, error)
+	DeleteScheduledRain(scheduleID int64, ownerID string) (bool, error)
+
+	// Raffles
+	CreateRaffle(raffle Raffle) (int64, error)
+	GetRaffle(raffleID int64) (Raffle, error)
+	GetOpenRaffle(chatID string) (Raffle, error)
+	GetRaffleByMessage(messageID string) (Raffle, error)
+	SetRaffleMessage(raffleID int64, messageID string) error
+	ListDueRaffles(now int64) ([]Raffle, error)
+	JoinRaffle(raffleID int64, userID string, now int64) (bool, error)
+	ListRaffleEntries(raffleID int64) ([]string, error)
+	FinishRaffle(raffleID int64, winnerID string) (bool, error)
+
+	// Bounties
+	CreateBounty(bounty Bounty) (int64, error)
+	GetBounty(bountyID int64) (Bounty, error)
+	ListOpenBounties(chatID string, limit int) ([]Bounty, error)
+	ListBountiesByCreator(creatorID string, limit int) ([]Bounty, error)
+	AwardBounty(bountyID int64, creatorID, winnerID string) (bool, error)
+	CancelBounty(bountyID int64, creatorID string) (bool, error)
+
+	// Vesting transfers
+	CreateVesting(vesting Vesting) (int64, error)
+	ListReleasableVestings(now int64) ([]Vesting, error)
+	ListLockedVestings(recipientID string) ([]Vesting, error)
+	ReleaseVesting(vestingID int64, fromReleasedRaw, toReleasedRaw uint64) (bool, error)
+
+	// Price history
+	AddPriceSample(sample price.Sample) error
+	LatestPriceSample() (price.Sample, error)
+	ListPriceSamples(since int64) ([]price.Sample, error)
+	PrunePriceSamples(before int64) (int64, error)
+
+	// Price alerts
+	CreatePriceAlert(alert PriceAlert) (int64, error)
+	ListPriceAlerts(userID string) ([]PriceAlert, error)
+	DeletePriceAlert(alertID int64, userID string) (bool, error)
+	ListTriggeredPriceAlerts(priceUSD float64) ([]PriceAlert, error)
+	FirePriceAlert(alertID int64) (bool, error)
+	RearmPriceAlerts(priceUSD float64) (int64, error)
+
+	// Wallets and contest
+	LinkWallet(wallet string, userID string) error
+	GetUserWallets(userID string) ([]string, error)
+	UnlinkWallet(wallet string, userID string) error
+	GetWalletToUserMap(wallets []string) (map[string]string, error)
+	SetContestAddress(address string) error
+	GetContestAddress() (string, error)
+
+	// Ledger
+	ListLedger(userID string, filter LedgerFilter, limit, offset int) ([]LedgerEntry, error)
+	CountLedger(userID string, filter LedgerFilter) (int, error)
+	VerifyLedger() ([]LedgerMismatch, error)
+
+	// Permissions
+	GetRole(scope, userID string) (Role, error)
+	GrantRole(scope, userID string, role Role, grantedBy string) error
+	RevokeRole(scope, userID string) (bool, error)
+	ListRoles(scope string) ([]RoleGrant, error)
+
+	// Telegram groups
+	RegisterTelegramChat(chatID, title, registeredBy string) error
+	UnregisterTelegramChat(chatID string) (bool, error)
+	IsTelegramChatRegistered(chatID string) (bool, error)
+	MigrateLegacyTelegramChat(chatID, title string) error
+
+	// Telegram usernames
+	SeeTelegramUsername(userID, username string) error
+	LookupTelegramUsername(username string) ([]TelegramUsername, error)
+
+	// Confirmations
+	CreatePendingAction(action PendingAction) error
+	TakePendingAction(actionID, userID string) (PendingAction, error)
 }
 
-func (db Database) EnsureUserExists(userID string) error {
-	_, err := db.inner.Exec("INSERT OR IGNORE INTO users (user_id) VALUES (?)", userID)
+func (db sqlDatabase) EnsureUserExists(userID string) error {
+	_, err := db.exec("INSERT INTO users (user_id) VALUES (?) ON CONFLICT DO NOTHING", userID)
 	return err
 }
 
-func (db Database) GetUserBalanceRaw(userID string) (uint64, error) {
-	var balance uint64
-	err := db.inner.QueryRow("SELECT balance_raw FROM users WHERE user_id = ?", userID).Scan(&balance)
-	return balance, err
+// GetUserBalanceRaw returns what a user can spend. A user in debt from a
+// clawback has nothing to spend until the debt is paid off.
+func (db sqlDatabase) GetUserBalanceRaw(userID string) (uint64, error) {
+	var balance int64
+	err := db.queryRow("SELECT balance_raw FROM users WHERE user_id = ?", userID).Scan(&balance)
+	return uint64(max(balance, 0)), err
 }
 
-func (db Database) IsUserExtant(userID string) (bool, error) {
+func (db sqlDatabase) IsUserExtant(userID string) (bool, error) {
 	_, err := db.GetUserBalanceRaw(userID)
 	if err == sql.ErrNoRows {
 		return false, nil
@@ -134,8 +195,15 @@ func (db Database) IsUserExtant(userID string) (bool, error) {
 	return true, nil
 }
 
-func (db Database) UpdateBalanceRaw(userID string, amountRaw int64) error {
-	res, err := db.inner.Exec("UPDATE users SET balance_raw = balance_raw + ? WHERE user_id = ?", amountRaw, userID)
+// UpdateBalanceRaw adjusts a user's balance by hand, recording it as an admin entry
+func (db sqlDatabase) UpdateBalanceRaw(userID string, amountRaw int64) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE users SET balance_raw = balance_raw + ? WHERE user_id = ?", amountRaw, userID)
 	if err != nil {
 		return err
 	}
@@ -146,18 +214,24 @@ func (db Database) UpdateBalanceRaw(userID string, amountRaw int64) error {
 	if aff < 1 {
 		return errors.New("no rows affected")
 	}
-	return nil
+
+	if err = appendLedger(tx, newTxID(), userID, LEDGER_ADMIN, "", amountRaw); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (db Database) TransferFundsRaw(senderID, recipientID string, amountRaw uint64) error {
-	tx, err := db.inner.Begin()
+// TransferFundsRaw moves funds between two users, recording both sides under kind
+func (db sqlDatabase) TransferFundsRaw(senderID, recipientID string, amountRaw uint64, kind LedgerKind) error {
+	tx, err := db.begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Deduct from sender
-	res, err := tx.Exec("UPDATE users SET balance_raw = balance_raw - ? WHERE user_id = ?", amountRaw, senderID)
+	// Deduct from sender, if they have enough
+	res, err := tx.Exec("UPDATE users SET balance_raw = balance_raw - ? WHERE user_id = ? AND balance_raw >= ?", amountRaw, senderID, amountRaw)
 	if err != nil {
 		return err
 	}
@@ -166,7 +240,7 @@ func (db Database) TransferFundsRaw(senderID, recipientID string, amountRaw uint
 		return err
 	}
 	if aff < 1 {
-		return errors.New("no rows affected")
+		return ErrInsufficientBalance
 	}
 
 	// Add to recipient
@@ -182,11 +256,80 @@ func (db Database) TransferFundsRaw(senderID, recipientID string, amountRaw uint
 		return errors.New("no rows affected")
 	}
 
+	// Journal both sides
+	txID := newTxID()
+	if err = appendLedger(tx, txID, senderID, kind, recipientID, -int64(amountRaw)); err != nil {
+		return err
+	}
+	if err = appendLedger(tx, txID, recipientID, kind, senderID, int64(amountRaw)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// TransferFundsManyRaw moves amountsRaw[i] from the sender to recipientIDs[i]
+// in a single transaction, failing as a whole if the sender can't afford it
+// or any recipient is missing
+func (db sqlDatabase) TransferFundsManyRaw(senderID string, recipientIDs []string, amountsRaw []uint64, kind LedgerKind) error {
+	if len(recipientIDs) == 0 {
+		return errors.New("no recipients")
+	}
+	if len(amountsRaw) != len(recipientIDs) {
+		return errors.New("recipients and amounts differ in length")
+	}
+	var totalRaw uint64
+	for _, amountRaw := range amountsRaw {
+		totalRaw += amountRaw
+	}
+
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Deduct the total from sender, if they have enough
+	res, err := tx.Exec("UPDATE users SET balance_raw = balance_raw - ? WHERE user_id = ? AND balance_raw >= ?", totalRaw, senderID, totalRaw)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff < 1 {
+		return ErrInsufficientBalance
+	}
+
+	txID := newTxID()
+	if err = appendLedger(tx, txID, senderID, kind, "", -int64(totalRaw)); err != nil {
+		return err
+	}
+
+	// Credit each recipient
+	for i, recipientID := range recipientIDs {
+		res, err = tx.Exec("UPDATE users SET balance_raw = balance_raw + ? WHERE user_id = ?", amountsRaw[i], recipientID)
+		if err != nil {
+			return err
+		}
+		aff, err = res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if aff < 1 {
+			return fmt.Errorf("recipient %s not found", recipientID)
+		}
+		if err = appendLedger(tx, txID, recipientID, kind, senderID, int64(amountsRaw[i])); err != nil {
+			return err
+		}
+	}
+
 	return tx.Commit()
 }
 
-func (db Database) CreateDeposit(depositID, userID string, amountRaw uint64) error {
-	_, err := db.inner.Exec(
+func (db sqlDatabase) CreateDeposit(depositID, userID string, amountRaw uint64) error {
+	_, err := db.exec(
 		"INSERT INTO deposits (deposit_id, user_id, amount_raw, completed) VALUES (?, ?, ?, 0)",
 		depositID,
 		userID,
@@ -195,8 +338,8 @@ func (db Database) CreateDeposit(depositID, userID string, amountRaw uint64) err
 	return err
 }
 
-func (db Database) CompleteDeposit(depositID string) error {
-	tx, err := db.inner.Begin()
+func (db sqlDatabase) CompleteDeposit(depositID string) error {
+	tx, err := db.begin()
 	if err != nil {
 		return err
 	}
@@ -213,7 +356,7 @@ func (db Database) CompleteDeposit(depositID string) error {
 	}
 
 	// Mark deposit as completed
-	result, err := tx.Exec("UPDATE deposits SET completed = 1 WHERE deposit_id = ? AND completed = 0", depositID)
+	result, err := tx.Exec("UPDATE deposits SET completed = 1, expired = 0 WHERE deposit_id = ? AND completed = 0", depositID)
 	if err != nil {
 		return err
 	}
@@ -238,11 +381,15 @@ func (db Database) CompleteDeposit(depositID string) error {
 		return errors.New("no rows affected")
 	}
 
+	if err = appendLedger(tx, newTxID(), userID, LEDGER_DEPOSIT, depositID, int64(amountRaw)); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
-func (db Database) CreateWithdrawal(withdrawID, userID string, oldBalanceRaw, amountRaw uint64, signature string) error {
-	tx, err := db.inner.Begin()
+func (db sqlDatabase) CreateWithdrawal(withdrawID, userID string, oldBalanceRaw, amountRaw uint64, signature string) error {
+	tx, err := db.begin()
 	if err != nil {
 		return err
 	}
@@ -276,22 +423,31 @@ func (db Database) CreateWithdrawal(withdrawID, userID string, oldBalanceRaw, am
 		return err
 	}
 
+	if err = appendLedger(tx, newTxID(), userID, LEDGER_WITHDRAWAL, withdrawID, -int64(amountRaw)); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
-func (db Database) ProcessRain(senderID string, recipientIDs []string, totalAmountRaw uint64, senderBalanceRaw uint64) (uint64, error) {
+// ProcessRain debits the sender the sum of amountsRaw and credits
+// amountsRaw[i] to recipientIDs[i], provided the sender's balance is still
+// senderBalanceRaw
+func (db sqlDatabase) ProcessRain(senderID string, recipientIDs []string, amountsRaw []uint64, senderBalanceRaw uint64) error {
 	if len(recipientIDs) == 0 {
-		return 0, errors.New("no recipients")
+		return errors.New("no recipients")
 	}
-
-	amountPerUserRaw := totalAmountRaw / uint64(len(recipientIDs))
-	if amountPerUserRaw == 0 {
-		return 0, errors.New("amount too small to distribute")
+	if len(amountsRaw) != len(recipientIDs) {
+		return errors.New("one amount is needed per recipient")
+	}
+	var totalAmountRaw uint64
+	for _, amountRaw := range amountsRaw {
+		totalAmountRaw += amountRaw
 	}
 
-	tx, err := db.inner.Begin()
+	tx, err := db.begin()
 	if err != nil {
-		return 0, err
+		return err
 	}
 	defer tx.Rollback()
 
@@ -299,55 +455,64 @@ func (db Database) ProcessRain(senderID string, recipientIDs []string, totalAmou
 	result, err := tx.Exec("UPDATE users SET balance_raw = balance_raw - ? WHERE user_id = ? AND balance_raw = ?",
 		totalAmountRaw, senderID, senderBalanceRaw)
 	if err != nil {
-		return 0, err
+		return err
 	}
 	aff, err := result.RowsAffected()
 	if err != nil {
-		return 0, err
+		return err
 	}
 	if aff < 1 {
-		return 0, errors.New("sender not found")
+		return errors.New("sender not found")
+	}
+
+	txID := newTxID()
+	if err = appendLedger(tx, txID, senderID, LEDGER_RAIN, "", -int64(totalAmountRaw)); err != nil {
+		return err
 	}
 
 	// Ensure all recipients exist and credit them
-	for _, recipientID := range recipientIDs {
+	for i, recipientID := range recipientIDs {
 		// Ensure user exists
-		_, err := tx.Exec("INSERT OR IGNORE INTO users (user_id) VALUES (?)", recipientID)
+		_, err := tx.Exec("INSERT INTO users (user_id) VALUES (?) ON CONFLICT DO NOTHING", recipientID)
 		if err != nil {
-			return 0, err
+			return err
 		}
 
 		// Credit recipient
-		_, err = tx.Exec("UPDATE users SET balance_raw = balance_raw + ? WHERE user_id = ?", amountPerUserRaw, recipientID)
+		_, err = tx.Exec("UPDATE users SET balance_raw = balance_raw + ? WHERE user_id = ?", amountsRaw[i], recipientID)
 		if err != nil {
-			return 0, err
+			return err
+		}
+		if err = appendLedger(tx, txID, recipientID, LEDGER_RAIN, senderID, int64(amountsRaw[i])); err != nil {
+			return err
 		}
 	}
 
-	if err = tx.Commit(); err != nil {
-		return 0, err
-	}
-
-	return amountPerUserRaw, nil
+	return tx.Commit()
 }
 
-func (db Database) GetActiveUsersForRain(serverID string, minScore int) ([]string, error) {
+func (db sqlDatabase) GetActiveUsersForRain(serverID string) ([]string, error) {
+	settings, err := db.GetRainSettings(serverID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Prune activity entries that are too old
-	threshold := time.Now().Unix() - constants.ACTIVITY_DELTA_RESET
-	_, err := db.inner.Exec(
-		"DELETE FROM activity WHERE last_message_timestamp < ?",
-		threshold,
+	threshold := time.Now().Unix() - settings.ActivityDeltaReset
+	_, err = db.exec(
+		"DELETE FROM activity WHERE server_id = ? AND last_message_timestamp < ?",
+		serverID, threshold,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := db.inner.Query(`
+	rows, err := db.query(`
 		SELECT user_id
 		FROM activity
 		WHERE server_id = ? AND score >= ?
-		ORDER BY score DESC
-	`, serverID, minScore)
+		ORDER BY score DESC, user_id
+	`, serverID, settings.ActivityRequirement)
 	if err != nil {
 		return nil, err
 	}
@@ -365,14 +530,39 @@ func (db Database) GetActiveUsersForRain(serverID string, minScore int) ([]strin
 	return activeUsers, rows.Err()
 }
 
+// GetActivityScores returns the activity score of everyone who has one in a server
+func (db sqlDatabase) GetActivityScores(serverID string) (map[string]int, error) {
+	rows, err := db.query("SELECT user_id, score FROM activity WHERE server_id = ?", serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scores := make(map[string]int)
+	for rows.Next() {
+		var userID string
+		var score int
+		if err := rows.Scan(&userID, &score); err != nil {
+			return nil, err
+		}
+		scores[userID] = score
+	}
+	return scores, rows.Err()
+}
+
 // Activity-related methods
-func (db Database) UpdateActivityScore(serverID, userID string) error {
+func (db sqlDatabase) UpdateActivityScore(serverID, userID string) error {
 	currentTime := time.Now().Unix()
 
+	settings, err := db.GetRainSettings(serverID)
+	if err != nil {
+		return err
+	}
+
 	// First, try to get existing score and timestamp
 	var score int
 	var lastTimestamp int64
-	err := db.inner.QueryRow(`
+	err = db.queryRow(`
 		SELECT score, last_message_timestamp
 		FROM activity
 		WHERE server_id = ? AND user_id = ?
@@ -380,9 +570,10 @@ func (db Database) UpdateActivityScore(serverID, userID string) error {
 
 	if err == sql.ErrNoRows {
 		// New user, insert with score 1
-		_, err = db.inner.Exec(`
-			INSERT OR IGNORE INTO activity (server_id, user_id, score, last_message_timestamp)
+		_, err = db.exec(`
+			INSERT INTO activity (server_id, user_id, score, last_message_timestamp)
 			VALUES (?, ?, 1, ?)
+			ON CONFLICT DO NOTHING
 		`, serverID, userID, currentTime)
 		return err
 	} else if err != nil {
@@ -393,28 +584,28 @@ func (db Database) UpdateActivityScore(serverID, userID string) error {
 	delta := currentTime - lastTimestamp
 
 	var newScore int
-	if delta < constants.ACTIVITY_DELTA_MIN {
-		// Less than 90 seconds - don't change score
+	if delta < settings.ActivityDeltaMin {
+		// Too soon after the last message - don't change score
 		newScore = score
-	} else if delta >= constants.ACTIVITY_DELTA_MIN && delta <= constants.ACTIVITY_DELTA_MAX {
-		// Between 90 seconds and 20 minutes - increase score (max 10)
+	} else if delta <= settings.ActivityDeltaMax {
+		// Increase score, up to the maximum
 		newScore = score + 1
-		if newScore > constants.ACTIVITY_MAX {
-			newScore = constants.ACTIVITY_MAX
+		if newScore > settings.ActivityMax {
+			newScore = settings.ActivityMax
 		}
-	} else if delta > constants.ACTIVITY_DELTA_MAX && delta <= constants.ACTIVITY_DELTA_RESET {
-		// Between 20 and 30 minutes - decrease score (min 1)
+	} else if delta <= settings.ActivityDeltaReset {
+		// Decrease score (min 1)
 		newScore = score - 1
 		if newScore < 1 {
 			newScore = 1
 		}
 	} else {
-		// More than 30 minutes - reset to 1
+		// Quiet for too long - reset to 1
 		newScore = 1
 	}
 
 	// Update the record
-	_, err = db.inner.Exec(`
+	_, err = db.exec(`
 		UPDATE activity
 		SET score = ?, last_message_timestamp = ?
 		WHERE server_id = ? AND user_id = ?
@@ -424,12 +615,12 @@ func (db Database) UpdateActivityScore(serverID, userID string) error {
 }
 
 // FindDepositByPrefix finds a deposit by ID prefix for a user
-func (db *Database) FindDepositByPrefix(userID, depositIDPrefix string) (string, uint64, int, error) {
+func (db sqlDatabase) FindDepositByPrefix(userID, depositIDPrefix string) (string, uint64, int, error) {
 	var fullDepositID string
 	var amountRaw uint64
 	var completed int
 
-	err := db.inner.QueryRow(
+	err := db.queryRow(
 		"SELECT deposit_id, amount_raw, completed FROM deposits WHERE user_id = ? AND deposit_id LIKE ? ORDER BY timestamp DESC LIMIT 1",
 		userID,
 		depositIDPrefix+"%",
@@ -439,25 +630,51 @@ func (db *Database) FindDepositByPrefix(userID, depositIDPrefix string) (string,
 }
 
 // ListDeposits returns recent deposits for a user
-func (db *Database) ListDeposits(userID string, limit int) ([]Deposit, error) {
-	rows, err := db.inner.Query(
-		"SELECT deposit_id, user_id, timestamp, amount_raw, completed FROM deposits WHERE user_id = ? ORDER BY timestamp DESC LIMIT ?",
+func (db sqlDatabase) ListDeposits(userID string, limit int) ([]Deposit, error) {
+	rows, err := db.query(
+		"SELECT deposit_id, user_id, timestamp, amount_raw, completed, expired FROM deposits WHERE user_id = ? ORDER BY timestamp DESC LIMIT ?",
 		userID, limit,
 	)
 	if err != nil {
 		return nil, err
 	}
+	return scanDeposits(rows)
+}
+
+// ListPendingDeposits returns every deposit that is neither completed nor expired
+func (db sqlDatabase) ListPendingDeposits() ([]Deposit, error) {
+	rows, err := db.query(
+		"SELECT deposit_id, user_id, timestamp, amount_raw, completed, expired FROM deposits WHERE completed = 0 AND expired = 0 ORDER BY timestamp",
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeposits(rows)
+}
+
+// ExpireDeposit stops a pending deposit from being watched. It can still be
+// completed afterwards if the funds turn up.
+func (db sqlDatabase) ExpireDeposit(depositID string) error {
+	_, err := db.exec(
+		"UPDATE deposits SET expired = 1 WHERE deposit_id = ? AND completed = 0",
+		depositID,
+	)
+	return err
+}
+
+func scanDeposits(rows *sql.Rows) ([]Deposit, error) {
 	defer rows.Close()
 
 	var deposits []Deposit
 	for rows.Next() {
 		var d Deposit
-		var completed int
-		err := rows.Scan(&d.DepositID, &d.UserID, &d.Timestamp, &d.AmountRaw, &completed)
+		var completed, expired int
+		err := rows.Scan(&d.DepositID, &d.UserID, &d.Timestamp, &d.AmountRaw, &completed, &expired)
 		if err != nil {
 			return nil, err
 		}
 		d.Completed = completed == 1
+		d.Expired = expired == 1
 		deposits = append(deposits, d)
 	}
 
@@ -465,20 +682,172 @@ func (db *Database) ListDeposits(userID string, limit int) ([]Deposit, error) {
 }
 
 // ListWithdrawals returns recent withdrawals for a user
-func (db *Database) ListWithdrawals(userID string, limit int) ([]Withdrawal, error) {
-	rows, err := db.inner.Query(
-		"SELECT withdraw_id, user_id, timestamp, amount_raw, signature FROM withdrawals WHERE user_id = ? ORDER BY timestamp DESC LIMIT ?",
+func (db sqlDatabase) ListWithdrawals(userID string, limit int) ([]Withdrawal, error) {
+	rows, err := db.query(
+		"SELECT withdraw_id, user_id, timestamp, amount_raw, signature, status FROM withdrawals WHERE user_id = ? ORDER BY timestamp DESC LIMIT ?",
 		userID, limit,
 	)
 	if err != nil {
 		return nil, err
 	}
+	return scanWithdrawals(rows)
+}
+
+// ListWithdrawalsByStatus returns every withdrawal with the given status, oldest first
+func (db sqlDatabase) ListWithdrawalsByStatus(status WithdrawalStatus) ([]Withdrawal, error) {
+	rows, err := db.query(
+		"SELECT withdraw_id, user_id, timestamp, amount_raw, signature, status FROM withdrawals WHERE status = ? ORDER BY timestamp",
+		status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanWithdrawals(rows)
+}
+
+// FindWithdrawalByPrefix finds a user's latest withdrawal by ID prefix
+func (db sqlDatabase) FindWithdrawalByPrefix(userID, withdrawIDPrefix string) (Withdrawal, error) {
+	var w Withdrawal
+	err := db.queryRow(
+		"SELECT withdraw_id, user_id, timestamp, amount_raw, signature, status FROM withdrawals WHERE user_id = ? AND withdraw_id LIKE ? ORDER BY timestamp DESC LIMIT 1",
+		userID,
+		withdrawIDPrefix+"%",
+	).Scan(&w.WithdrawID, &w.UserID, &w.Timestamp, &w.AmountRaw, &w.Signature, &w.Status)
+	return w, err
+}
+
+// SwapWithdrawalStatus moves a withdrawal from one status to another.
+// It reports whether the withdrawal was still in the old status.
+func (db sqlDatabase) SwapWithdrawalStatus(withdrawID string, from, to WithdrawalStatus) (bool, error) {
+	result, err := db.exec(
+		"UPDATE withdrawals SET status = ? WHERE withdraw_id = ? AND status = ?",
+		to, withdrawID, from,
+	)
+	if err != nil {
+		return false, err
+	}
+	aff, err := result.RowsAffected()
+	return aff > 0, err
+}
+
+// RefundWithdrawal finishes a cancellation: it marks a revoking withdrawal as
+// revoked and credits the amount back to the user
+func (db sqlDatabase) RefundWithdrawal(withdrawID string) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	var amountRaw uint64
+	err = tx.QueryRow(
+		"SELECT user_id, amount_raw FROM withdrawals WHERE withdraw_id = ? AND status = ?",
+		withdrawID, WITHDRAWAL_REVOKING,
+	).Scan(&userID, &amountRaw)
+	if err != nil {
+		return err
+	}
+
+	// Mark revoked with compare-and-swap, so a refund happens at most once
+	result, err := tx.Exec(
+		"UPDATE withdrawals SET status = ? WHERE withdraw_id = ? AND status = ?",
+		WITHDRAWAL_REVOKED, withdrawID, WITHDRAWAL_REVOKING,
+	)
+	if err != nil {
+		return err
+	}
+	aff, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff < 1 {
+		return errors.New("no rows affected")
+	}
+
+	// Credit user
+	result, err = tx.Exec("UPDATE users SET balance_raw = balance_raw + ? WHERE user_id = ?", amountRaw, userID)
+	if err != nil {
+		return err
+	}
+	aff, err = result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff < 1 {
+		return errors.New("no rows affected")
+	}
+
+	if err = appendLedger(tx, newTxID(), userID, LEDGER_REFUND, withdrawID, int64(amountRaw)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ClawbackWithdrawal handles a revoked voucher that was claimed on-chain anyway:
+// it marks the withdrawal claimed and takes the whole refund back. If the
+// refund was already spent the balance goes negative, and the user can't
+// send anything until later credits pay the debt off. It returns the debt.
+func (db sqlDatabase) ClawbackWithdrawal(withdrawID string) (uint64, error) {
+	tx, err := db.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	var amountRaw uint64
+	var balanceRaw int64
+	err = tx.QueryRow(
+		`SELECT w.user_id, w.amount_raw, u.balance_raw FROM withdrawals w
+		JOIN users u ON u.user_id = w.user_id
+		WHERE w.withdraw_id = ? AND w.status = ?`,
+		withdrawID, WITHDRAWAL_REVOKED,
+	).Scan(&userID, &amountRaw, &balanceRaw)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(
+		"UPDATE withdrawals SET status = ? WHERE withdraw_id = ? AND status = ?",
+		WITHDRAWAL_CLAIMED, withdrawID, WITHDRAWAL_REVOKED,
+	)
+	if err != nil {
+		return 0, err
+	}
+	aff, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if aff < 1 {
+		return 0, errors.New("no rows affected")
+	}
+
+	// Unguarded on purpose: every other debit requires a covering balance,
+	// so a negative balance blocks spending until it's paid off
+	_, err = tx.Exec(
+		"UPDATE users SET balance_raw = balance_raw - ? WHERE user_id = ?",
+		amountRaw, userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if err = appendLedger(tx, newTxID(), userID, LEDGER_CLAWBACK, withdrawID, -int64(amountRaw)); err != nil {
+		return 0, err
+	}
+
+	debtRaw := uint64(max(int64(amountRaw)-balanceRaw, 0))
+	return debtRaw, tx.Commit()
+}
+
+func scanWithdrawals(rows *sql.Rows) ([]Withdrawal, error) {
 	defer rows.Close()
 
 	var withdrawals []Withdrawal
 	for rows.Next() {
 		var w Withdrawal
-		err := rows.Scan(&w.WithdrawID, &w.UserID, &w.Timestamp, &w.AmountRaw, &w.Signature)
+		err := rows.Scan(&w.WithdrawID, &w.UserID, &w.Timestamp, &w.AmountRaw, &w.Signature, &w.Status)
 		if err != nil {
 			return nil, err
 		}
@@ -489,17 +858,17 @@ func (db *Database) ListWithdrawals(userID string, limit int) ([]Withdrawal, err
 }
 
 // AddRainChannel adds a channel to the rain whitelist for a server
-func (db Database) AddRainChannel(serverID, channelID string) error {
-	_, err := db.inner.Exec(
-		"INSERT OR IGNORE INTO rain_channels (server_id, channel_id) VALUES (?, ?)",
+func (db sqlDatabase) AddRainChannel(serverID, channelID string) error {
+	_, err := db.exec(
+		"INSERT INTO rain_channels (server_id, channel_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
 		serverID, channelID,
 	)
 	return err
 }
 
 // RemoveRainChannel removes a channel from the rain whitelist
-func (db Database) RemoveRainChannel(serverID, channelID string) error {
-	_, err := db.inner.Exec(
+func (db sqlDatabase) RemoveRainChannel(serverID, channelID string) error {
+	_, err := db.exec(
 		"DELETE FROM rain_channels WHERE server_id = ? AND channel_id = ?",
 		serverID, channelID,
 	)
@@ -507,8 +876,8 @@ func (db Database) RemoveRainChannel(serverID, channelID string) error {
 }
 
 // ClearRainChannels removes all rain channels for a server
-func (db Database) ClearRainChannels(serverID string) error {
-	_, err := db.inner.Exec(
+func (db sqlDatabase) ClearRainChannels(serverID string) error {
+	_, err := db.exec(
 		"DELETE FROM rain_channels WHERE server_id = ?",
 		serverID,
 	)
@@ -516,8 +885,8 @@ func (db Database) ClearRainChannels(serverID string) error {
 }
 
 // GetRainChannels returns all whitelisted channels for a server
-func (db Database) GetRainChannels(serverID string) ([]string, error) {
-	rows, err := db.inner.Query(
+func (db sqlDatabase) GetRainChannels(serverID string) ([]string, error) {
+	rows, err := db.query(
 		"SELECT channel_id FROM rain_channels WHERE server_id = ?",
 		serverID,
 	)
@@ -538,9 +907,9 @@ func (db Database) GetRainChannels(serverID string) ([]string, error) {
 }
 
 // IsRainChannel checks if a channel is whitelisted for rain
-func (db Database) IsRainChannel(serverID, channelID string) (bool, error) {
+func (db sqlDatabase) IsRainChannel(serverID, channelID string) (bool, error) {
 	var count int
-	err := db.inner.QueryRow(
+	err := db.queryRow(
 		"SELECT COUNT(*) FROM rain_channels WHERE server_id = ? AND channel_id = ?",
 		serverID, channelID,
 	).Scan(&count)
@@ -548,18 +917,19 @@ func (db Database) IsRainChannel(serverID, channelID string) (bool, error) {
 }
 
 // LinkWallet links a wallet address to a user
-func (db Database) LinkWallet(wallet string, userID string) error {
+func (db sqlDatabase) LinkWallet(wallet string, userID string) error {
 	// Link the wallet
-	_, err := db.inner.Exec(
-		"INSERT OR REPLACE INTO wallets (wallet, user_id) VALUES (?, ?)",
+	_, err := db.exec(
+		`INSERT INTO wallets (wallet, user_id) VALUES (?, ?)
+		ON CONFLICT (wallet) DO UPDATE SET user_id = excluded.user_id, linked_at = excluded.linked_at`,
 		wallet, userID,
 	)
 	return err
 }
 
 // GetUserWallets returns all wallets linked to a user
-func (db Database) GetUserWallets(userID string) ([]string, error) {
-	rows, err := db.inner.Query(
+func (db sqlDatabase) GetUserWallets(userID string) ([]string, error) {
+	rows, err := db.query(
 		"SELECT wallet FROM wallets WHERE user_id = ? ORDER BY linked_at DESC",
 		userID,
 	)
@@ -581,8 +951,8 @@ func (db Database) GetUserWallets(userID string) ([]string, error) {
 }
 
 // UnlinkWallet removes a wallet link
-func (db Database) UnlinkWallet(wallet string, userID string) error {
-	result, err := db.inner.Exec(
+func (db sqlDatabase) UnlinkWallet(wallet string, userID string) error {
+	result, err := db.exec(
 		"DELETE FROM wallets WHERE wallet = ? AND user_id = ?",
 		wallet, userID,
 	)
@@ -602,7 +972,7 @@ func (db Database) UnlinkWallet(wallet string, userID string) error {
 }
 
 // GetWalletToUserMap efficiently maps multiple wallet addresses to their user IDs
-func (db Database) GetWalletToUserMap(wallets []string) (map[string]string, error) {
+func (db sqlDatabase) GetWalletToUserMap(wallets []string) (map[string]string, error) {
 	if len(wallets) == 0 {
 		return make(map[string]string), nil
 	}
@@ -620,7 +990,7 @@ func (db Database) GetWalletToUserMap(wallets []string) (map[string]string, erro
 		strings.Join(placeholders, ","),
 	)
 
-	rows, err := db.inner.Query(query, args...)
+	rows, err := db.query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -639,18 +1009,19 @@ func (db Database) GetWalletToUserMap(wallets []string) (map[string]string, erro
 }
 
 // SetContestAddress sets the contest address
-func (db Database) SetContestAddress(address string) error {
-	_, err := db.inner.Exec(
-		"INSERT OR REPLACE INTO contest (key, value) VALUES ('address', ?)",
+func (db sqlDatabase) SetContestAddress(address string) error {
+	_, err := db.exec(
+		`INSERT INTO contest (key, value) VALUES ('address', ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`,
 		address,
 	)
 	return err
 }
 
 // GetContestAddress retrieves the contest address
-func (db Database) GetContestAddress() (string, error) {
+func (db sqlDatabase) GetContestAddress() (string, error) {
 	var address string
-	err := db.inner.QueryRow(
+	err := db.queryRow(
 		"SELECT value FROM contest WHERE key = 'address'",
 	).Scan(&address)
 	if err == sql.ErrNoRows {
@@ -0,0 +1,1013 @@
+// Package dbtest is the conformance suite every db.Database backend must pass
+package dbtest
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/db"
+	"github.com/ivypowered/ivy-sprite-bot/price"
+)
+
+// IVY price the suite expects ledger entries to be recorded at
+const PRICE_USD = 0.25
+
+// Price is the db.PriceFunc backends under test must be opened with
+func Price() (float64, error) {
+	return PRICE_USD, nil
+}
+
+// Run runs the whole suite. open must return a fresh, empty, fully migrated
+// database opened with Price each time it is called.
+func Run(t *testing.T, open func(t *testing.T) db.Database) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, database db.Database)
+	}{
+		{"Users", testUsers},
+		{"Transfer", testTransfer},
+		{"TransferMany", testTransferMany},
+		{"ConcurrentTransfers", testConcurrentTransfers},
+		{"Deposits", testDeposits},
+		{"Withdrawals", testWithdrawals},
+		{"Clawback", testClawback},
+		{"Rain", testRain},
+		{"Activity", testActivity},
+		{"RainChannels", testRainChannels},
+		{"RainSettings", testRainSettings},
+		{"ScheduledRains", testScheduledRains},
+		{"Raffles", testRaffles},
+		{"Bounties", testBounties},
+		{"Vestings", testVestings},
+		{"PriceSamples", testPriceSamples},
+		{"PriceAlerts", testPriceAlerts},
+		{"Wallets", testWallets},
+		{"Contest", testContest},
+		{"Ledger", testLedger},
+		{"Roles", testRoles},
+		{"TelegramChats", testTelegramChats},
+		{"LegacyTelegramChat", testLegacyTelegramChat},
+		{"PendingActions", testPendingActions},
+		{"TelegramUsernames", testTelegramUsernames},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func balance(t *testing.T, database db.Database, userID string) uint64 {
+	t.Helper()
+	balanceRaw, err := database.GetUserBalanceRaw(userID)
+	check(t, err)
+	return balanceRaw
+}
+
+// verify fails the test if any balance disagrees with the ledger
+func verify(t *testing.T, database db.Database) {
+	t.Helper()
+	mismatches, err := database.VerifyLedger()
+	check(t, err)
+	if len(mismatches) != 0 {
+		t.Fatalf("ledger mismatches: %+v", mismatches)
+	}
+}
+
+// fund creates a user with the given balance
+func fund(t *testing.T, database db.Database, userID string, amountRaw int64) {
+	t.Helper()
+	check(t, database.EnsureUserExists(userID))
+	check(t, database.UpdateBalanceRaw(userID, amountRaw))
+}
+
+func testUsers(t *testing.T, database db.Database) {
+	if ok, err := database.IsUserExtant("a"); err != nil || ok {
+		t.Fatalf("IsUserExtant before creation = %v, %v", ok, err)
+	}
+	if _, err := database.GetUserBalanceRaw("a"); err != sql.ErrNoRows {
+		t.Fatalf("GetUserBalanceRaw of missing user = %v, want sql.ErrNoRows", err)
+	}
+
+	check(t, database.EnsureUserExists("a"))
+	check(t, database.EnsureUserExists("a"))
+	if ok, err := database.IsUserExtant("a"); err != nil || !ok {
+		t.Fatalf("IsUserExtant after creation = %v, %v", ok, err)
+	}
+	if b := balance(t, database, "a"); b != 0 {
+		t.Fatalf("new balance = %d", b)
+	}
+
+	check(t, database.UpdateBalanceRaw("a", 5_000_000_000))
+	check(t, database.UpdateBalanceRaw("a", -1_000_000_000))
+	if b := balance(t, database, "a"); b != 4_000_000_000 {
+		t.Fatalf("balance = %d, want 4000000000", b)
+	}
+	if err := database.UpdateBalanceRaw("missing", 1); err == nil {
+		t.Fatal("UpdateBalanceRaw succeeded for a missing user")
+	}
+	verify(t, database)
+}
+
+func testTransfer(t *testing.T, database db.Database) {
+	fund(t, database, "a", 100)
+	check(t, database.EnsureUserExists("b"))
+
+	check(t, database.TransferFundsRaw("a", "b", 30, db.LEDGER_TIP))
+	if a, b := balance(t, database, "a"), balance(t, database, "b"); a != 70 || b != 30 {
+		t.Fatalf("balances = %d, %d", a, b)
+	}
+
+	// A missing recipient rolls back the sender's debit
+	if err := database.TransferFundsRaw("a", "missing", 10, db.LEDGER_TIP); err == nil {
+		t.Fatal("transfer to a missing user succeeded")
+	}
+	// So does an amount the sender can't afford, even if they passed a
+	// balance check before
+	if err := database.TransferFundsRaw("a", "b", 71, db.LEDGER_TIP); err != db.ErrInsufficientBalance {
+		t.Fatalf("transfer beyond the sender's balance = %v", err)
+	}
+	if a, b := balance(t, database, "a"), balance(t, database, "b"); a != 70 || b != 30 {
+		t.Fatalf("balances after failed transfers = %d, %d", a, b)
+	}
+	verify(t, database)
+}
+
+func testTransferMany(t *testing.T, database db.Database) {
+	fund(t, database, "a", 100)
+	check(t, database.EnsureUserExists("b"))
+	check(t, database.EnsureUserExists("c"))
+
+	check(t, database.TransferFundsManyRaw("a", []string{"b", "c"}, []uint64{31, 29}, db.LEDGER_TIP))
+	if a, b, c := balance(t, database, "a"), balance(t, database, "b"), balance(t, database, "c"); a != 40 || b != 31 || c != 29 {
+		t.Fatalf("balances = %d, %d, %d", a, b, c)
+	}
+
+	// A missing recipient rolls back everyone
+	if err := database.TransferFundsManyRaw("a", []string{"b", "missing"}, []uint64{10, 10}, db.LEDGER_TIP); err == nil {
+		t.Fatal("transfer to a missing user succeeded")
+	}
+	// So does a total the sender can't afford
+	if err := database.TransferFundsManyRaw("a", []string{"b", "c"}, []uint64{20, 21}, db.LEDGER_TIP); err != db.ErrInsufficientBalance {
+		t.Fatal("transfer beyond the sender's balance succeeded")
+	}
+	if a, b := balance(t, database, "a"), balance(t, database, "b"); a != 40 || b != 31 {
+		t.Fatalf("balances after failed transfers = %d, %d", a, b)
+	}
+	verify(t, database)
+}
+
+func testConcurrentTransfers(t *testing.T, database db.Database) {
+	const senders, transfers = 8, 25
+	check(t, database.EnsureUserExists("pot"))
+	for i := 0; i < senders; i++ {
+		senderID := fmt.Sprint("s", i)
+		check(t, database.EnsureUserExists(senderID))
+		for j := 0; j < transfers; j++ {
+			check(t, database.CreateDeposit(fmt.Sprint(senderID, "d", j), senderID, 1))
+		}
+	}
+
+	// Every sender pays in a deposit and passes it on, over and over. None of
+	// it may fail because another transaction holds a lock.
+	errs := make(chan error, 2*senders*transfers)
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(senderID string) {
+			defer wg.Done()
+			for j := 0; j < transfers; j++ {
+				errs <- database.CompleteDeposit(fmt.Sprint(senderID, "d", j))
+				errs <- database.TransferFundsRaw(senderID, "pot", 1, db.LEDGER_TIP)
+			}
+		}(fmt.Sprint("s", i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		check(t, err)
+	}
+
+	if pot := balance(t, database, "pot"); pot != senders*transfers {
+		t.Fatalf("pot balance = %d", pot)
+	}
+	verify(t, database)
+}
+
+func testDeposits(t *testing.T, database db.Database) {
+	check(t, database.EnsureUserExists("a"))
+	check(t, database.CreateDeposit("aa11", "a", 500))
+	check(t, database.CreateDeposit("bb22", "a", 700))
+
+	id, amountRaw, completed, err := database.FindDepositByPrefix("a", "aa")
+	if err != nil || id != "aa11" || amountRaw != 500 || completed != 0 {
+		t.Fatalf("FindDepositByPrefix = %s, %d, %d, %v", id, amountRaw, completed, err)
+	}
+	if _, _, _, err := database.FindDepositByPrefix("someone", "aa"); err != sql.ErrNoRows {
+		t.Fatalf("FindDepositByPrefix of another user = %v, want sql.ErrNoRows", err)
+	}
+
+	check(t, database.ExpireDeposit("bb22"))
+	pending, err := database.ListPendingDeposits()
+	if err != nil || len(pending) != 1 || pending[0].DepositID != "aa11" {
+		t.Fatalf("pending = %+v, %v", pending, err)
+	}
+
+	// Completing twice credits once
+	check(t, database.CompleteDeposit("aa11"))
+	if err := database.CompleteDeposit("aa11"); err != sql.ErrNoRows {
+		t.Fatalf("second CompleteDeposit = %v, want sql.ErrNoRows", err)
+	}
+	// Expired deposits can still be completed
+	check(t, database.CompleteDeposit("bb22"))
+	if b := balance(t, database, "a"); b != 1200 {
+		t.Fatalf("balance = %d, want 1200", b)
+	}
+
+	deposits, err := database.ListDeposits("a", 10)
+	check(t, err)
+	if len(deposits) != 2 {
+		t.Fatalf("deposits = %+v", deposits)
+	}
+	for _, d := range deposits {
+		if !d.Completed || d.Expired {
+			t.Fatalf("deposit = %+v, want completed and not expired", d)
+		}
+	}
+	pending, err = database.ListPendingDeposits()
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("pending = %+v, %v", pending, err)
+	}
+	verify(t, database)
+}
+
+func testWithdrawals(t *testing.T, database db.Database) {
+	fund(t, database, "a", 1000)
+
+	// The compare-and-swap rejects a stale balance
+	if err := database.CreateWithdrawal("ww11", "a", 999, 400, "sig"); err == nil {
+		t.Fatal("CreateWithdrawal succeeded with a stale balance")
+	}
+	check(t, database.CreateWithdrawal("ww11", "a", 1000, 400, "sig"))
+	if b := balance(t, database, "a"); b != 600 {
+		t.Fatalf("balance = %d, want 600", b)
+	}
+
+	w, err := database.FindWithdrawalByPrefix("a", "ww")
+	if err != nil || w.WithdrawID != "ww11" || w.AmountRaw != 400 || w.Signature != "sig" || w.Status != db.WITHDRAWAL_PENDING {
+		t.Fatalf("FindWithdrawalByPrefix = %+v, %v", w, err)
+	}
+
+	swapped, err := database.SwapWithdrawalStatus("ww11", db.WITHDRAWAL_PENDING, db.WITHDRAWAL_REVOKING)
+	if err != nil || !swapped {
+		t.Fatalf("SwapWithdrawalStatus = %v, %v", swapped, err)
+	}
+	swapped, err = database.SwapWithdrawalStatus("ww11", db.WITHDRAWAL_PENDING, db.WITHDRAWAL_CLAIMED)
+	if err != nil || swapped {
+		t.Fatalf("stale SwapWithdrawalStatus = %v, %v", swapped, err)
+	}
+	revoking, err := database.ListWithdrawalsByStatus(db.WITHDRAWAL_REVOKING)
+	if err != nil || len(revoking) != 1 {
+		t.Fatalf("revoking = %+v, %v", revoking, err)
+	}
+
+	// Refunding twice credits once
+	check(t, database.RefundWithdrawal("ww11"))
+	if err := database.RefundWithdrawal("ww11"); err != sql.ErrNoRows {
+		t.Fatalf("second RefundWithdrawal = %v, want sql.ErrNoRows", err)
+	}
+	if b := balance(t, database, "a"); b != 1000 {
+		t.Fatalf("balance = %d, want 1000", b)
+	}
+
+	withdrawals, err := database.ListWithdrawals("a", 10)
+	if err != nil || len(withdrawals) != 1 || withdrawals[0].Status != db.WITHDRAWAL_REVOKED {
+		t.Fatalf("withdrawals = %+v, %v", withdrawals, err)
+	}
+	verify(t, database)
+}
+
+func testClawback(t *testing.T, database db.Database) {
+	fund(t, database, "a", 1000)
+	check(t, database.EnsureUserExists("b"))
+	check(t, database.CreateWithdrawal("ww11", "a", 1000, 800, "sig"))
+	_, err := database.SwapWithdrawalStatus("ww11", db.WITHDRAWAL_PENDING, db.WITHDRAWAL_REVOKING)
+	check(t, err)
+	check(t, database.RefundWithdrawal("ww11"))
+
+	// Most of the refund is spent before the voucher turns out to be claimed
+	check(t, database.TransferFundsRaw("a", "b", 700, db.LEDGER_TIP))
+	debt, err := database.ClawbackWithdrawal("ww11")
+	if err != nil || debt != 500 {
+		t.Fatalf("ClawbackWithdrawal = %d, %v, want a debt of 500", debt, err)
+	}
+	if b := balance(t, database, "a"); b != 0 {
+		t.Fatalf("balance = %d, want 0", b)
+	}
+
+	// The debt blocks spending, and credits pay it off first
+	if err := database.TransferFundsRaw("a", "b", 1, db.LEDGER_TIP); err != db.ErrInsufficientBalance {
+		t.Fatalf("transfer while in debt = %v", err)
+	}
+	check(t, database.TransferFundsRaw("b", "a", 600, db.LEDGER_TIP))
+	if b := balance(t, database, "a"); b != 100 {
+		t.Fatalf("balance after paying off the debt = %d, want 100", b)
+	}
+	if _, err := database.ClawbackWithdrawal("ww11"); err != sql.ErrNoRows {
+		t.Fatalf("second ClawbackWithdrawal = %v, want sql.ErrNoRows", err)
+	}
+	w, err := database.FindWithdrawalByPrefix("a", "ww11")
+	if err != nil || w.Status != db.WITHDRAWAL_CLAIMED {
+		t.Fatalf("withdrawal = %+v, %v", w, err)
+	}
+	verify(t, database)
+}
+
+func testRain(t *testing.T, database db.Database) {
+	fund(t, database, "a", 100)
+	check(t, database.EnsureUserExists("b"))
+
+	if err := database.ProcessRain("a", []string{"b", "c", "d"}, []uint64{30, 30, 30}, 99); err == nil {
+		t.Fatal("ProcessRain succeeded with a stale balance")
+	}
+	if err := database.ProcessRain("a", []string{"b", "c"}, []uint64{30}, 100); err == nil {
+		t.Fatal("ProcessRain succeeded with too few amounts")
+	}
+	check(t, database.ProcessRain("a", []string{"b", "c", "d"}, []uint64{31, 30, 30}, 100))
+	for userID, want := range map[string]uint64{"a": 9, "b": 31, "c": 30, "d": 30} {
+		if b := balance(t, database, userID); b != want {
+			t.Fatalf("balance of %s = %d, want %d", userID, b, want)
+		}
+	}
+	verify(t, database)
+}
+
+func testActivity(t *testing.T, database db.Database) {
+	settings := db.DefaultRainSettings()
+	settings.ActivityRequirement = 1
+	check(t, database.SetRainSettings("s1", settings))
+
+	check(t, database.UpdateActivityScore("s1", "a"))
+	check(t, database.UpdateActivityScore("s1", "a"))
+	check(t, database.UpdateActivityScore("s2", "b"))
+
+	active, err := database.GetActiveUsersForRain("s1")
+	if err != nil || len(active) != 1 || active[0] != "a" {
+		t.Fatalf("active = %v, %v", active, err)
+	}
+
+	scores, err := database.GetActivityScores("s1")
+	if err != nil || len(scores) != 1 || scores["a"] < 1 {
+		t.Fatalf("scores = %v, %v", scores, err)
+	}
+}
+
+func testRainChannels(t *testing.T, database db.Database) {
+	check(t, database.AddRainChannel("s1", "c1"))
+	check(t, database.AddRainChannel("s1", "c1"))
+	check(t, database.AddRainChannel("s1", "c2"))
+	check(t, database.AddRainChannel("s2", "c3"))
+
+	channels, err := database.GetRainChannels("s1")
+	if err != nil || len(channels) != 2 {
+		t.Fatalf("channels = %v, %v", channels, err)
+	}
+	if ok, err := database.IsRainChannel("s1", "c3"); err != nil || ok {
+		t.Fatalf("IsRainChannel of another server = %v, %v", ok, err)
+	}
+
+	check(t, database.RemoveRainChannel("s1", "c1"))
+	if ok, err := database.IsRainChannel("s1", "c1"); err != nil || ok {
+		t.Fatalf("IsRainChannel after removal = %v, %v", ok, err)
+	}
+	check(t, database.ClearRainChannels("s1"))
+	channels, err = database.GetRainChannels("s1")
+	if err != nil || len(channels) != 0 {
+		t.Fatalf("channels after clear = %v, %v", channels, err)
+	}
+	if ok, err := database.IsRainChannel("s2", "c3"); err != nil || !ok {
+		t.Fatalf("IsRainChannel of untouched server = %v, %v", ok, err)
+	}
+}
+
+func testRainSettings(t *testing.T, database db.Database) {
+	settings, err := database.GetRainSettings("s1")
+	if err != nil || settings != db.DefaultRainSettings() {
+		t.Fatalf("unset settings = %+v, %v", settings, err)
+	}
+
+	settings.MinAmountUSD = 2.5
+	settings.ActivityDeltaReset = 7200
+	check(t, database.SetRainSettings("s1", settings))
+	settings.MinActiveCount = 9
+	check(t, database.SetRainSettings("s1", settings))
+	stored, err := database.GetRainSettings("s1")
+	if err != nil || stored != settings {
+		t.Fatalf("stored settings = %+v, %v, want %+v", stored, err, settings)
+	}
+
+	check(t, database.ResetRainSettings("s1"))
+	settings, err = database.GetRainSettings("s1")
+	if err != nil || settings != db.DefaultRainSettings() {
+		t.Fatalf("reset settings = %+v, %v", settings, err)
+	}
+}
+
+func testScheduledRains(t *testing.T, database db.Database) {
+	id, err := database.CreateScheduledRain(db.ScheduledRain{
+		OwnerID: "a", ServerID: "s1", AmountRaw: 100, IntervalSeconds: 60, MaxUsers: 5, ConfirmedRaw: 100, NextRun: 1000,
+	})
+	check(t, err)
+	_, err = database.CreateScheduledRain(db.ScheduledRain{
+		OwnerID: "a", ServerID: "s1", AmountRaw: 50, IntervalSeconds: 60, NextRun: 5000,
+	})
+	check(t, err)
+
+	due, err := database.ListDueScheduledRains(1000)
+	if err != nil || len(due) != 1 || due[0].ScheduleID != id || due[0].MaxUsers != 5 || due[0].ConfirmedRaw != 100 {
+		t.Fatalf("due = %+v, %v", due, err)
+	}
+
+	// Only one claim of a run succeeds
+	if ok, err := database.ClaimScheduledRain(id, 1000, 1060); err != nil || !ok {
+		t.Fatalf("ClaimScheduledRain = %v, %v", ok, err)
+	}
+	if ok, err := database.ClaimScheduledRain(id, 1000, 1060); err != nil || ok {
+		t.Fatalf("second ClaimScheduledRain = %v, %v", ok, err)
+	}
+
+	// Paused schedules aren't due until resumed
+	check(t, database.PauseScheduledRain(id))
+	if due, err := database.ListDueScheduledRains(2000); err != nil || len(due) != 0 {
+		t.Fatalf("due while paused = %+v, %v", due, err)
+	}
+	if ok, err := database.ResumeScheduledRain(id, "b", 1500); err != nil || ok {
+		t.Fatalf("ResumeScheduledRain by someone else = %v, %v", ok, err)
+	}
+	if ok, err := database.ResumeScheduledRain(id, "a", 1500); err != nil || !ok {
+		t.Fatalf("ResumeScheduledRain = %v, %v", ok, err)
+	}
+	rains, err := database.ListScheduledRains("a")
+	if err != nil || len(rains) != 2 || rains[0].Paused || rains[0].NextRun != 1500 {
+		t.Fatalf("ListScheduledRains = %+v, %v", rains, err)
+	}
+
+	if ok, err := database.DeleteScheduledRain(id, "b"); err != nil || ok {
+		t.Fatalf("DeleteScheduledRain by someone else = %v, %v", ok, err)
+	}
+	if ok, err := database.DeleteScheduledRain(id, "a"); err != nil || !ok {
+		t.Fatalf("DeleteScheduledRain = %v, %v", ok, err)
+	}
+	if rains, err := database.ListScheduledRains("a"); err != nil || len(rains) != 1 {
+		t.Fatalf("ListScheduledRains after delete = %+v, %v", rains, err)
+	}
+}
+
+func testRaffles(t *testing.T, database db.Database) {
+	fund(t, database, "a", 100)
+
+	// The prize is escrowed from the owner
+	if _, err := database.CreateRaffle(db.Raffle{OwnerID: "a", ChatID: "s1", ChannelID: "c1", PrizeRaw: 200, EndsAt: 1000}); err == nil {
+		t.Fatal("created a raffle without enough balance")
+	}
+	id, err := database.CreateRaffle(db.Raffle{
+		OwnerID: "a", ChatID: "s1", ChannelID: "c1", PrizeRaw: 60, SeedHash: "hash", Seed: "seed", EndsAt: 1000,
+	})
+	check(t, err)
+	if b := balance(t, database, "a"); b != 40 {
+		t.Fatalf("owner balance after escrow = %d", b)
+	}
+	verify(t, database)
+
+	// Only one open raffle per chat
+	if _, err := database.CreateRaffle(db.Raffle{OwnerID: "a", ChatID: "s1", ChannelID: "c1", PrizeRaw: 10, EndsAt: 1000}); err == nil {
+		t.Fatal("opened a second raffle in the same chat")
+	}
+	if b := balance(t, database, "a"); b != 40 {
+		t.Fatalf("owner balance after failed raffle = %d", b)
+	}
+
+	check(t, database.SetRaffleMessage(id, "m1"))
+	for _, get := range []func() (db.Raffle, error){
+		func() (db.Raffle, error) { return database.GetRaffle(id) },
+		func() (db.Raffle, error) { return database.GetOpenRaffle("s1") },
+		func() (db.Raffle, error) { return database.GetRaffleByMessage("m1") },
+	} {
+		r, err := get()
+		if err != nil || r.RaffleID != id || r.Status != db.RAFFLE_OPEN || r.MessageID != "m1" || r.Seed != "seed" {
+			t.Fatalf("raffle = %+v, %v", r, err)
+		}
+	}
+
+	// Entries are unique and close when the raffle ends
+	for _, userID := range []string{"c", "b"} {
+		if ok, err := database.JoinRaffle(id, userID, 500); err != nil || !ok {
+			t.Fatalf("JoinRaffle(%s) = %v, %v", userID, ok, err)
+		}
+	}
+	if ok, err := database.JoinRaffle(id, "b", 500); err != nil || ok {
+		t.Fatalf("second JoinRaffle = %v, %v", ok, err)
+	}
+	if ok, err := database.JoinRaffle(id, "d", 1000); err != nil || ok {
+		t.Fatalf("JoinRaffle after the end = %v, %v", ok, err)
+	}
+	entries, err := database.ListRaffleEntries(id)
+	if err != nil || len(entries) != 2 || entries[0] != "b" || entries[1] != "c" {
+		t.Fatalf("entries = %v, %v", entries, err)
+	}
+
+	if due, err := database.ListDueRaffles(999); err != nil || len(due) != 0 {
+		t.Fatalf("due before the end = %+v, %v", due, err)
+	}
+	if due, err := database.ListDueRaffles(1000); err != nil || len(due) != 1 {
+		t.Fatalf("due = %+v, %v", due, err)
+	}
+
+	// The prize is paid once
+	if ok, err := database.FinishRaffle(id, "b"); err != nil || !ok {
+		t.Fatalf("FinishRaffle = %v, %v", ok, err)
+	}
+	if ok, err := database.FinishRaffle(id, "c"); err != nil || ok {
+		t.Fatalf("second FinishRaffle = %v, %v", ok, err)
+	}
+	if b := balance(t, database, "b"); b != 60 {
+		t.Fatalf("winner balance = %d", b)
+	}
+	if r, err := database.GetRaffle(id); err != nil || r.Status != db.RAFFLE_DRAWN || r.WinnerID != "b" {
+		t.Fatalf("drawn raffle = %+v, %v", r, err)
+	}
+	if _, err := database.GetOpenRaffle("s1"); err != sql.ErrNoRows {
+		t.Fatalf("GetOpenRaffle after the draw = %v, want sql.ErrNoRows", err)
+	}
+
+	// A raffle nobody entered goes back to its owner
+	id, err = database.CreateRaffle(db.Raffle{OwnerID: "a", ChatID: "s1", ChannelID: "c1", PrizeRaw: 40, EndsAt: 2000})
+	check(t, err)
+	if ok, err := database.FinishRaffle(id, ""); err != nil || !ok {
+		t.Fatalf("FinishRaffle without winner = %v, %v", ok, err)
+	}
+	if b := balance(t, database, "a"); b != 40 {
+		t.Fatalf("owner balance after refund = %d", b)
+	}
+	if r, err := database.GetRaffle(id); err != nil || r.Status != db.RAFFLE_REFUNDED {
+		t.Fatalf("refunded raffle = %+v, %v", r, err)
+	}
+	verify(t, database)
+}
+
+func testBounties(t *testing.T, database db.Database) {
+	fund(t, database, "a", 100)
+
+	// The reward is escrowed from the creator
+	if _, err := database.CreateBounty(db.Bounty{CreatorID: "a", ChatID: "s1", Description: "too much", AmountRaw: 200}); err == nil {
+		t.Fatal("created a bounty without enough balance")
+	}
+	if b := balance(t, database, "a"); b != 100 {
+		t.Fatalf("creator balance after failed bounty = %d", b)
+	}
+	first, err := database.CreateBounty(db.Bounty{CreatorID: "a", ChatID: "s1", Description: "fix the bug", AmountRaw: 30})
+	check(t, err)
+	second, err := database.CreateBounty(db.Bounty{CreatorID: "a", ChatID: "s1", Description: "draw a logo", AmountRaw: 50})
+	check(t, err)
+	if b := balance(t, database, "a"); b != 20 {
+		t.Fatalf("creator balance after escrow = %d", b)
+	}
+	verify(t, database)
+
+	open, err := database.ListOpenBounties("s1", 10)
+	if err != nil || len(open) != 2 || open[0].BountyID != first || open[0].Description != "fix the bug" || open[0].Status != db.BOUNTY_OPEN {
+		t.Fatalf("open bounties = %+v, %v", open, err)
+	}
+
+	// Only the creator can award, and only once
+	if ok, err := database.AwardBounty(first, "b", "b"); err != nil || ok {
+		t.Fatalf("AwardBounty by someone else = %v, %v", ok, err)
+	}
+	if ok, err := database.AwardBounty(first, "a", "b"); err != nil || !ok {
+		t.Fatalf("AwardBounty = %v, %v", ok, err)
+	}
+	if ok, err := database.AwardBounty(first, "a", "c"); err != nil || ok {
+		t.Fatalf("second AwardBounty = %v, %v", ok, err)
+	}
+	if b := balance(t, database, "b"); b != 30 {
+		t.Fatalf("winner balance = %d", b)
+	}
+	bounty, err := database.GetBounty(first)
+	if err != nil || bounty.Status != db.BOUNTY_AWARDED || bounty.WinnerID != "b" || bounty.ClosedAt == 0 {
+		t.Fatalf("awarded bounty = %+v, %v", bounty, err)
+	}
+
+	// Cancelling refunds the creator
+	if ok, err := database.CancelBounty(second, "b"); err != nil || ok {
+		t.Fatalf("CancelBounty by someone else = %v, %v", ok, err)
+	}
+	if ok, err := database.CancelBounty(second, "a"); err != nil || !ok {
+		t.Fatalf("CancelBounty = %v, %v", ok, err)
+	}
+	if ok, err := database.CancelBounty(first, "a"); err != nil || ok {
+		t.Fatalf("CancelBounty of an awarded bounty = %v, %v", ok, err)
+	}
+	if b := balance(t, database, "a"); b != 70 {
+		t.Fatalf("creator balance after refund = %d", b)
+	}
+
+	if open, err := database.ListOpenBounties("s1", 10); err != nil || len(open) != 0 {
+		t.Fatalf("open bounties after closing = %+v, %v", open, err)
+	}
+	mine, err := database.ListBountiesByCreator("a", 10)
+	if err != nil || len(mine) != 2 || mine[0].BountyID != second || mine[0].Status != db.BOUNTY_CANCELLED {
+		t.Fatalf("bounties by creator = %+v, %v", mine, err)
+	}
+	if _, err := database.GetBounty(12345); err != sql.ErrNoRows {
+		t.Fatalf("GetBounty of missing bounty = %v, want sql.ErrNoRows", err)
+	}
+	verify(t, database)
+}
+
+func testVestings(t *testing.T, database db.Database) {
+	fund(t, database, "a", 100)
+
+	// The whole amount leaves the sender up front
+	if _, err := database.CreateVesting(db.Vesting{SenderID: "a", RecipientID: "b", AmountRaw: 200, StartAt: 0, CliffAt: 10, EndAt: 100}); err == nil {
+		t.Fatal("created a vesting without enough balance")
+	}
+	id, err := database.CreateVesting(db.Vesting{SenderID: "a", RecipientID: "b", AmountRaw: 60, StartAt: 0, CliffAt: 10, EndAt: 100})
+	check(t, err)
+	if b := balance(t, database, "a"); b != 40 {
+		t.Fatalf("sender balance = %d", b)
+	}
+
+	if vestings, err := database.ListReleasableVestings(9); err != nil || len(vestings) != 0 {
+		t.Fatalf("releasable before the cliff = %+v, %v", vestings, err)
+	}
+	vestings, err := database.ListReleasableVestings(10)
+	if err != nil || len(vestings) != 1 || vestings[0].VestingID != id || vestings[0].AmountRaw != 60 {
+		t.Fatalf("releasable = %+v, %v", vestings, err)
+	}
+
+	// Releases only apply from the amount they expect
+	if ok, err := database.ReleaseVesting(id, 0, 20); err != nil || !ok {
+		t.Fatalf("ReleaseVesting = %v, %v", ok, err)
+	}
+	if ok, err := database.ReleaseVesting(id, 0, 30); err != nil || ok {
+		t.Fatalf("stale ReleaseVesting = %v, %v", ok, err)
+	}
+	if ok, err := database.ReleaseVesting(id, 20, 61); err != nil || ok {
+		t.Fatalf("ReleaseVesting past the amount = %v, %v", ok, err)
+	}
+	if b := balance(t, database, "b"); b != 20 {
+		t.Fatalf("recipient balance = %d", b)
+	}
+	locked, err := database.ListLockedVestings("b")
+	if err != nil || len(locked) != 1 || locked[0].ReleasedRaw != 20 {
+		t.Fatalf("locked = %+v, %v", locked, err)
+	}
+
+	// Fully released vestings are done
+	if ok, err := database.ReleaseVesting(id, 20, 60); err != nil || !ok {
+		t.Fatalf("final ReleaseVesting = %v, %v", ok, err)
+	}
+	if locked, err := database.ListLockedVestings("b"); err != nil || len(locked) != 0 {
+		t.Fatalf("locked after full release = %+v, %v", locked, err)
+	}
+	if vestings, err := database.ListReleasableVestings(1000); err != nil || len(vestings) != 0 {
+		t.Fatalf("releasable after full release = %+v, %v", vestings, err)
+	}
+	if b := balance(t, database, "b"); b != 60 {
+		t.Fatalf("recipient balance after full release = %d", b)
+	}
+	verify(t, database)
+}
+
+func testPriceSamples(t *testing.T, database db.Database) {
+	if _, err := database.LatestPriceSample(); err != sql.ErrNoRows {
+		t.Fatalf("LatestPriceSample of an empty table = %v", err)
+	}
+
+	for _, sample := range []price.Sample{
+		{Timestamp: 100, PriceUSD: 0.5, Sources: 1},
+		{Timestamp: 300, PriceUSD: 0.75, Sources: 2},
+		{Timestamp: 200, PriceUSD: 0.25, Sources: 2},
+	} {
+		check(t, database.AddPriceSample(sample))
+	}
+
+	latest, err := database.LatestPriceSample()
+	if err != nil || latest != (price.Sample{Timestamp: 300, PriceUSD: 0.75, Sources: 2}) {
+		t.Fatalf("LatestPriceSample = %+v, %v", latest, err)
+	}
+	samples, err := database.ListPriceSamples(200)
+	if err != nil || len(samples) != 2 || samples[0].Timestamp != 200 || samples[1].Timestamp != 300 {
+		t.Fatalf("ListPriceSamples = %+v, %v", samples, err)
+	}
+
+	pruned, err := database.PrunePriceSamples(300)
+	if err != nil || pruned != 2 {
+		t.Fatalf("PrunePriceSamples = %d, %v", pruned, err)
+	}
+	if samples, err := database.ListPriceSamples(0); err != nil || len(samples) != 1 {
+		t.Fatalf("samples after pruning = %+v, %v", samples, err)
+	}
+}
+
+func testPriceAlerts(t *testing.T, database db.Database) {
+	above, err := database.CreatePriceAlert(db.PriceAlert{UserID: "a", Direction: db.ALERT_ABOVE, TargetUSD: 0.05, Rearm: true})
+	check(t, err)
+	below, err := database.CreatePriceAlert(db.PriceAlert{UserID: "a", Direction: db.ALERT_BELOW, TargetUSD: 0.03})
+	check(t, err)
+	other, err := database.CreatePriceAlert(db.PriceAlert{UserID: "b", Direction: db.ALERT_BELOW, TargetUSD: 0.01})
+	check(t, err)
+
+	alerts, err := database.ListPriceAlerts("a")
+	if err != nil || len(alerts) != 2 || alerts[0].AlertID != above || !alerts[0].Armed || !alerts[0].Rearm || alerts[1].Rearm {
+		t.Fatalf("ListPriceAlerts = %+v, %v", alerts, err)
+	}
+
+	// Only alerts whose target was reached trigger
+	if alerts, err := database.ListTriggeredPriceAlerts(0.04); err != nil || len(alerts) != 0 {
+		t.Fatalf("triggered between targets = %+v, %v", alerts, err)
+	}
+	alerts, err = database.ListTriggeredPriceAlerts(0.05)
+	if err != nil || len(alerts) != 1 || alerts[0].AlertID != above {
+		t.Fatalf("triggered at the target = %+v, %v", alerts, err)
+	}
+
+	// Alerts fire once
+	if ok, err := database.FirePriceAlert(above); err != nil || !ok {
+		t.Fatalf("FirePriceAlert = %v, %v", ok, err)
+	}
+	if ok, err := database.FirePriceAlert(above); err != nil || ok {
+		t.Fatalf("second FirePriceAlert = %v, %v", ok, err)
+	}
+	if alerts, err := database.ListTriggeredPriceAlerts(0.06); err != nil || len(alerts) != 0 {
+		t.Fatalf("triggered after firing = %+v, %v", alerts, err)
+	}
+
+	// Re-arming alerts come back once the price is back below the target
+	if n, err := database.RearmPriceAlerts(0.05); err != nil || n != 0 {
+		t.Fatalf("RearmPriceAlerts at the target = %d, %v", n, err)
+	}
+	if n, err := database.RearmPriceAlerts(0.04); err != nil || n != 1 {
+		t.Fatalf("RearmPriceAlerts = %d, %v", n, err)
+	}
+	if ok, err := database.FirePriceAlert(below); err != nil || !ok {
+		t.Fatalf("FirePriceAlert = %v, %v", ok, err)
+	}
+	if n, err := database.RearmPriceAlerts(0.04); err != nil || n != 0 {
+		t.Fatalf("RearmPriceAlerts re-armed a one-off alert: %d, %v", n, err)
+	}
+
+	// Users can only remove their own alerts
+	if ok, err := database.DeletePriceAlert(other, "a"); err != nil || ok {
+		t.Fatalf("DeletePriceAlert of someone else's alert = %v, %v", ok, err)
+	}
+	if ok, err := database.DeletePriceAlert(below, "a"); err != nil || !ok {
+		t.Fatalf("DeletePriceAlert = %v, %v", ok, err)
+	}
+	if alerts, err := database.ListPriceAlerts("a"); err != nil || len(alerts) != 1 || alerts[0].AlertID != above || !alerts[0].Armed {
+		t.Fatalf("alerts after removal = %+v, %v", alerts, err)
+	}
+}
+
+func testWallets(t *testing.T, database db.Database) {
+	check(t, database.LinkWallet("w1", "a"))
+	check(t, database.LinkWallet("w2", "a"))
+	// Relinking moves the wallet
+	check(t, database.LinkWallet("w2", "b"))
+
+	wallets, err := database.GetUserWallets("a")
+	if err != nil || len(wallets) != 1 || wallets[0] != "w1" {
+		t.Fatalf("wallets of a = %v, %v", wallets, err)
+	}
+	owners, err := database.GetWalletToUserMap([]string{"w1", "w2", "w3"})
+	if err != nil || len(owners) != 2 || owners["w1"] != "a" || owners["w2"] != "b" {
+		t.Fatalf("owners = %v, %v", owners, err)
+	}
+
+	if err := database.UnlinkWallet("w2", "a"); err == nil {
+		t.Fatal("unlinked someone else's wallet")
+	}
+	check(t, database.UnlinkWallet("w2", "b"))
+	wallets, err = database.GetUserWallets("b")
+	if err != nil || len(wallets) != 0 {
+		t.Fatalf("wallets of b = %v, %v", wallets, err)
+	}
+}
+
+func testContest(t *testing.T, database db.Database) {
+	address, err := database.GetContestAddress()
+	if err != nil || address != "" {
+		t.Fatalf("unset address = %q, %v", address, err)
+	}
+	check(t, database.SetContestAddress("first"))
+	check(t, database.SetContestAddress("second"))
+	address, err = database.GetContestAddress()
+	if err != nil || address != "second" {
+		t.Fatalf("address = %q, %v", address, err)
+	}
+}
+
+func testLedger(t *testing.T, database db.Database) {
+	fund(t, database, "a", 1000)
+	check(t, database.EnsureUserExists("b"))
+	check(t, database.TransferFundsRaw("a", "b", 100, db.LEDGER_TIP))
+	check(t, database.TransferFundsRaw("a", "b", 200, db.LEDGER_MOVE))
+
+	entries, err := database.ListLedger("a", db.LedgerFilter{}, 10, 0)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("entries = %+v, %v", entries, err)
+	}
+	// Newest first
+	if entries[0].Kind != db.LEDGER_MOVE || entries[0].AmountRaw != -200 || entries[0].Counterparty != "b" {
+		t.Fatalf("newest entry = %+v", entries[0])
+	}
+	if entries[0].PriceUSD != PRICE_USD {
+		t.Fatalf("entry price = %v", entries[0].PriceUSD)
+	}
+
+	filter := db.LedgerFilter{Kinds: []db.LedgerKind{db.LEDGER_TIP, db.LEDGER_ADMIN}}
+	count, err := database.CountLedger("a", filter)
+	if err != nil || count != 2 {
+		t.Fatalf("CountLedger = %d, %v", count, err)
+	}
+	page, err := database.ListLedger("a", filter, 1, 1)
+	if err != nil || len(page) != 1 || page[0].Kind != db.LEDGER_ADMIN {
+		t.Fatalf("second page = %+v, %v", page, err)
+	}
+
+	// Both sides of a transfer share a transaction ID
+	theirs, err := database.ListLedger("b", db.LedgerFilter{Kinds: []db.LedgerKind{db.LEDGER_TIP}}, 10, 0)
+	check(t, err)
+	ours, err := database.ListLedger("a", db.LedgerFilter{Kinds: []db.LedgerKind{db.LEDGER_TIP}}, 10, 0)
+	check(t, err)
+	if len(theirs) != 1 || len(ours) != 1 || theirs[0].TxID != ours[0].TxID {
+		t.Fatalf("tip entries = %+v / %+v", ours, theirs)
+	}
+
+	future := db.LedgerFilter{Since: entries[0].Timestamp + 3600}
+	if count, err := database.CountLedger("a", future); err != nil || count != 0 {
+		t.Fatalf("CountLedger in the future = %d, %v", count, err)
+	}
+	verify(t, database)
+}
+
+func testRoles(t *testing.T, database db.Database) {
+	role, err := database.GetRole("s1", "a")
+	if err != nil || role != "" {
+		t.Fatalf("unset role = %q, %v", role, err)
+	}
+
+	check(t, database.GrantRole("s1", "a", db.ROLE_MODERATOR, "root"))
+	check(t, database.GrantRole("s1", "a", db.ROLE_ADMIN, "root"))
+	check(t, database.GrantRole("s1", "b", db.ROLE_MODERATOR, "a"))
+	role, err = database.GetRole("s1", "a")
+	if err != nil || role != db.ROLE_ADMIN {
+		t.Fatalf("role = %q, %v", role, err)
+	}
+	if role, err := database.GetRole("s2", "a"); err != nil || role != "" {
+		t.Fatalf("role in another scope = %q, %v", role, err)
+	}
+
+	grants, err := database.ListRoles("s1")
+	if err != nil || len(grants) != 2 {
+		t.Fatalf("grants = %+v, %v", grants, err)
+	}
+
+	held, err := database.RevokeRole("s1", "b")
+	if err != nil || !held {
+		t.Fatalf("RevokeRole = %v, %v", held, err)
+	}
+	held, err = database.RevokeRole("s1", "b")
+	if err != nil || held {
+		t.Fatalf("second RevokeRole = %v, %v", held, err)
+	}
+}
+
+func testTelegramChats(t *testing.T, database db.Database) {
+	if ok, err := database.IsTelegramChatRegistered("tg:-1"); err != nil || ok {
+		t.Fatalf("registered before registering = %v, %v", ok, err)
+	}
+	check(t, database.RegisterTelegramChat("tg:-1", "Group", "tg:1"))
+	check(t, database.RegisterTelegramChat("tg:-1", "Renamed", "tg:2"))
+	if ok, err := database.IsTelegramChatRegistered("tg:-1"); err != nil || !ok {
+		t.Fatalf("registered = %v, %v", ok, err)
+	}
+
+	removed, err := database.UnregisterTelegramChat("tg:-1")
+	if err != nil || !removed {
+		t.Fatalf("UnregisterTelegramChat = %v, %v", removed, err)
+	}
+	removed, err = database.UnregisterTelegramChat("tg:-1")
+	if err != nil || removed {
+		t.Fatalf("second UnregisterTelegramChat = %v, %v", removed, err)
+	}
+}
+
+func testLegacyTelegramChat(t *testing.T, database db.Database) {
+	// Nothing to migrate: the chat is left unregistered
+	check(t, database.MigrateLegacyTelegramChat("tg:-1", "Ivy"))
+	if ok, err := database.IsTelegramChatRegistered("tg:-1"); err != nil || ok {
+		t.Fatalf("registered without legacy rows = %v, %v", ok, err)
+	}
+
+	legacy := db.LEGACY_TELEGRAM_SERVER_ID
+	settings := db.DefaultRainSettings()
+	settings.MinActiveCount = 7
+	check(t, database.SetRainSettings(legacy, settings))
+	check(t, database.UpdateActivityScore(legacy, "tg:1"))
+	check(t, database.GrantRole(legacy, "tg:1", db.ROLE_ADMIN, ""))
+	// Rows the chat already has conflict with the legacy ones
+	check(t, database.UpdateActivityScore("tg:-1", "tg:1"))
+	check(t, database.GrantRole("tg:-1", "tg:1", db.ROLE_MODERATOR, ""))
+
+	check(t, database.MigrateLegacyTelegramChat("tg:-1", "Ivy"))
+	check(t, database.MigrateLegacyTelegramChat("tg:-1", "Ivy"))
+
+	if ok, err := database.IsTelegramChatRegistered("tg:-1"); err != nil || !ok {
+		t.Fatalf("registered = %v, %v", ok, err)
+	}
+	stored, err := database.GetRainSettings("tg:-1")
+	if err != nil || stored.MinActiveCount != 7 {
+		t.Fatalf("migrated settings = %+v, %v", stored, err)
+	}
+	role, err := database.GetRole("tg:-1", "tg:1")
+	if err != nil || role != db.ROLE_ADMIN {
+		t.Fatalf("migrated role = %q, %v", role, err)
+	}
+	grants, err := database.ListRoles(legacy)
+	if err != nil || len(grants) != 0 {
+		t.Fatalf("legacy grants left = %+v, %v", grants, err)
+	}
+}
+
+func testPendingActions(t *testing.T, database db.Database) {
+	now := time.Now().Unix()
+	check(t, database.CreatePendingAction(db.PendingAction{
+		ActionID: "a1", UserID: "1", Kind: "tip", Payload: "{}", ExpiresAt: now + 60,
+	}))
+	check(t, database.CreatePendingAction(db.PendingAction{
+		ActionID: "a2", UserID: "1", Kind: "rain", Payload: "{}", ExpiresAt: now - 1,
+	}))
+
+	// Only the sender can answer
+	if _, err := database.TakePendingAction("a1", "2"); err != sql.ErrNoRows {
+		t.Fatalf("TakePendingAction by someone else = %v", err)
+	}
+	action, err := database.TakePendingAction("a1", "1")
+	if err != nil || action.Kind != "tip" || action.Payload != "{}" {
+		t.Fatalf("TakePendingAction = %+v, %v", action, err)
+	}
+	// Only once
+	if _, err := database.TakePendingAction("a1", "1"); err != sql.ErrNoRows {
+		t.Fatalf("second TakePendingAction = %v", err)
+	}
+	// And not after it expires
+	if _, err := database.TakePendingAction("a2", "1"); err != sql.ErrNoRows {
+		t.Fatalf("TakePendingAction after expiry = %v", err)
+	}
+}
+
+func testTelegramUsernames(t *testing.T, database db.Database) {
+	users, err := database.LookupTelegramUsername("alice")
+	if err != nil || len(users) != 0 {
+		t.Fatalf("unseen username = %+v, %v", users, err)
+	}
+
+	check(t, database.SeeTelegramUsername("tg:1", "Alice"))
+	check(t, database.SeeTelegramUsername("tg:1", "Alice"))
+	users, err = database.LookupTelegramUsername("ALICE")
+	if err != nil || len(users) != 1 || users[0].UserID != "tg:1" {
+		t.Fatalf("LookupTelegramUsername = %+v, %v", users, err)
+	}
+
+	// Renaming forgets the old username
+	check(t, database.SeeTelegramUsername("tg:1", "alice2"))
+	users, err = database.LookupTelegramUsername("alice")
+	if err != nil || len(users) != 0 {
+		t.Fatalf("old username = %+v, %v", users, err)
+	}
+
+	// Someone else takes a username before its old holder is seen again
+	check(t, database.SeeTelegramUsername("tg:2", "alice2"))
+	users, err = database.LookupTelegramUsername("alice2")
+	if err != nil || len(users) != 2 {
+		t.Fatalf("shared username = %+v, %v", users, err)
+	}
+
+	// Dropping a username forgets it too
+	check(t, database.SeeTelegramUsername("tg:1", ""))
+	users, err = database.LookupTelegramUsername("alice2")
+	if err != nil || len(users) != 1 || users[0].UserID != "tg:2" {
+		t.Fatalf("after dropping = %+v, %v", users, err)
+	}
+}
@@ -0,0 +1,239 @@
+// db/ledger.go
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"sort"
+	"strings"
+)
+
+// LedgerKind describes what caused a balance change
+type LedgerKind string
+
+const (
+	LEDGER_TIP        LedgerKind = "tip"
+	LEDGER_RAIN       LedgerKind = "rain"
+	LEDGER_MOVE       LedgerKind = "move"
+	LEDGER_DEPOSIT    LedgerKind = "deposit"
+	LEDGER_WITHDRAWAL LedgerKind = "withdrawal"
+	LEDGER_ADMIN      LedgerKind = "admin"
+	// Prize escrowed for a raffle, paid to its winner or refunded
+	LEDGER_RAFFLE LedgerKind = "raffle"
+	// Reward escrowed for a bounty, paid to its winner or refunded
+	LEDGER_BOUNTY LedgerKind = "bounty"
+	// Amount locked by a sender, then released to its recipient over time
+	LEDGER_VESTING LedgerKind = "vesting"
+	// Cancelled withdrawal credited back
+	LEDGER_REFUND LedgerKind = "refund"
+	// Refund taken back because the cancelled voucher was claimed anyway
+	LEDGER_CLAWBACK LedgerKind = "clawback"
+	// Balance a user already had when the ledger was introduced
+	LEDGER_OPENING LedgerKind = "opening"
+)
+
+// LEDGER_KINDS lists every kind, in display order
+var LEDGER_KINDS = []LedgerKind{
+	LEDGER_TIP,
+	LEDGER_RAIN,
+	LEDGER_MOVE,
+	LEDGER_RAFFLE,
+	LEDGER_BOUNTY,
+	LEDGER_VESTING,
+	LEDGER_DEPOSIT,
+	LEDGER_WITHDRAWAL,
+	LEDGER_REFUND,
+	LEDGER_CLAWBACK,
+	LEDGER_ADMIN,
+	LEDGER_OPENING,
+}
+
+// ParseLedgerKind validates a user-supplied ledger kind
+func ParseLedgerKind(kind string) (LedgerKind, bool) {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	for _, k := range LEDGER_KINDS {
+		if string(k) == kind {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// LedgerEntry is a single signed balance change for one user
+type LedgerEntry struct {
+	EntryID      int64
+	TxID         string
+	UserID       string
+	Kind         LedgerKind
+	Counterparty string
+	AmountRaw    int64
+	Timestamp    int64
+	// IVY price in USD when the entry was written, 0 if unknown
+	PriceUSD float64
+}
+
+// LedgerFilter narrows down ListLedger; zero values match everything
+type LedgerFilter struct {
+	Kinds []LedgerKind
+	Since int64 // inclusive unix timestamp
+	Until int64 // exclusive unix timestamp
+}
+
+// LedgerMismatch is a user whose stored balance disagrees with the ledger
+type LedgerMismatch struct {
+	UserID     string
+	BalanceRaw int64
+	LedgerRaw  int64
+}
+
+// newTxID generates a random ID shared by all entries of one transaction
+func newTxID() string {
+	var id [16]byte
+	_, err := io.ReadFull(rand.Reader, id[:])
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// appendLedger records a balance change; it must run in the same
+// transaction as the balance update it describes
+func appendLedger(tx *txn, txID, userID string, kind LedgerKind, counterparty string, amountRaw int64) error {
+	// Remember the price so history can show the USD value at the time
+	_, err := tx.Exec(
+		"INSERT INTO ledger (tx_id, user_id, kind, counterparty, amount_raw, price_usd) VALUES (?, ?, ?, ?, ?, ?)",
+		txID, userID, string(kind), counterparty, amountRaw, tx.priceUSD,
+	)
+	return err
+}
+
+// where builds the WHERE clause and arguments for a user's filtered ledger
+func (f LedgerFilter) where(userID string) (string, []interface{}) {
+	clauses := []string{"user_id = ?"}
+	args := []interface{}{userID}
+	if len(f.Kinds) > 0 {
+		placeholders := make([]string, len(f.Kinds))
+		for i, k := range f.Kinds {
+			placeholders[i] = "?"
+			args = append(args, string(k))
+		}
+		clauses = append(clauses, "kind IN ("+strings.Join(placeholders, ",")+")")
+	}
+	if f.Since > 0 {
+		clauses = append(clauses, "timestamp >= ?")
+		args = append(args, f.Since)
+	}
+	if f.Until > 0 {
+		clauses = append(clauses, "timestamp < ?")
+		args = append(args, f.Until)
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// ListLedger returns a user's ledger entries, newest first
+func (db sqlDatabase) ListLedger(userID string, filter LedgerFilter, limit, offset int) ([]LedgerEntry, error) {
+	where, args := filter.where(userID)
+	args = append(args, limit, offset)
+	rows, err := db.query(
+		"SELECT entry_id, tx_id, user_id, kind, counterparty, amount_raw, timestamp, price_usd FROM ledger WHERE "+
+			where+" ORDER BY timestamp DESC, entry_id DESC LIMIT ? OFFSET ?",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []LedgerEntry
+	for rows.Next() {
+		var e LedgerEntry
+		var kind string
+		var priceUSD sql.NullFloat64
+		err := rows.Scan(&e.EntryID, &e.TxID, &e.UserID, &kind, &e.Counterparty, &e.AmountRaw, &e.Timestamp, &priceUSD)
+		if err != nil {
+			return nil, err
+		}
+		e.Kind = LedgerKind(kind)
+		e.PriceUSD = priceUSD.Float64
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// CountLedger returns how many of a user's ledger entries match filter
+func (db sqlDatabase) CountLedger(userID string, filter LedgerFilter) (int, error) {
+	where, args := filter.where(userID)
+	var count int
+	err := db.queryRow("SELECT COUNT(*) FROM ledger WHERE "+where, args...).Scan(&count)
+	return count, err
+}
+
+// VerifyLedger replays the ledger and returns every user whose
+// balance_raw does not match the sum of their ledger entries
+func (db sqlDatabase) VerifyLedger() ([]LedgerMismatch, error) {
+	// Replay the journal in insertion order
+	rows, err := db.query("SELECT user_id, amount_raw FROM ledger ORDER BY entry_id")
+	if err != nil {
+		return nil, err
+	}
+	replayed := make(map[string]int64)
+	for rows.Next() {
+		var userID string
+		var amountRaw int64
+		if err := rows.Scan(&userID, &amountRaw); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		replayed[userID] += amountRaw
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	// Compare against stored balances
+	rows, err = db.query("SELECT user_id, balance_raw FROM users")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mismatches []LedgerMismatch
+	for rows.Next() {
+		var userID string
+		var balanceRaw int64
+		if err := rows.Scan(&userID, &balanceRaw); err != nil {
+			return nil, err
+		}
+		if replayed[userID] != balanceRaw {
+			mismatches = append(mismatches, LedgerMismatch{
+				UserID:     userID,
+				BalanceRaw: balanceRaw,
+				LedgerRaw:  replayed[userID],
+			})
+		}
+		delete(replayed, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Entries for users that don't exist at all
+	for userID, ledgerRaw := range replayed {
+		if ledgerRaw != 0 {
+			mismatches = append(mismatches, LedgerMismatch{
+				UserID:    userID,
+				LedgerRaw: ledgerRaw,
+			})
+		}
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].UserID < mismatches[j].UserID
+	})
+	return mismatches, nil
+}
@@ -0,0 +1,425 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+)
+
+// migration upgrades the schema by one version. Databases from before
+// versioning start at version 0 with some of the later tables already
+// present, so every migration must tolerate its changes being half-applied.
+type migration struct {
+	description string
+	apply       func(tx *txn) error
+}
+
+// migrations[i] upgrades the schema from version i to version i+1
+var migrations = []migration{
+	{"baseline tables", migrateBaseline},
+	{"append-only ledger", migrateLedger},
+	{"deposit expiry", migrateDepositExpiry},
+	{"withdrawal status", migrateWithdrawalStatus},
+	{"admin roles", migrateAdmins},
+	{"per-server rain settings", migrateGuildSettings},
+	{"telegram chat registration", migrateTelegramChats},
+	{"pending confirmations", migratePendingActions},
+	{"telegram usernames", migrateTelegramUsernames},
+	{"scheduled rains", migrateScheduledRains},
+	{"raffles", migrateRaffles},
+	{"bounties", migrateBounties},
+	{"vesting transfers", migrateVestings},
+	{"price samples", migratePriceSamples},
+	{"price alerts", migratePriceAlerts},
+	{"scheduled rain confirmations", migrateScheduledRainConfirmations},
+}
+
+// SchemaVersion is the version a fully migrated database is at
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// migrate brings the schema up to date, applying each pending migration in
+// its own transaction
+func (db sqlDatabase) migrate() error {
+	_, err := db.exec(db.dialect.schema.Replace(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at BIGINT NOT NULL DEFAULT {{now}}
+	);`))
+	if err != nil {
+		return err
+	}
+
+	current, err := db.schemaVersion()
+	if err != nil {
+		return err
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("database schema is at version %d, but this build only knows up to %d", current, len(migrations))
+	}
+
+	for version := current; version < len(migrations); version++ {
+		m := migrations[version]
+		if err := db.applyMigration(version+1, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", version+1, m.description, err)
+		}
+	}
+	return nil
+}
+
+func (db sqlDatabase) schemaVersion() (int, error) {
+	var version int
+	err := db.queryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+func (db sqlDatabase) applyMigration(version int, m migration) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.apply(tx); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO schema_version (version, description) VALUES (?, ?)",
+		version, m.description,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// execAll runs schema statements, filling in the dialect's {{now}} and {{serial}}
+func execAll(tx *txn, queries ...string) error {
+	for _, query := range queries {
+		if _, err := tx.Exec(tx.dialect.schema.Replace(query)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumn adds a column, doing nothing if it already exists
+func addColumn(tx *txn, table, column, decl string) error {
+	if tx.dialect.numbered {
+		_, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table, column, decl))
+		return err
+	}
+	_, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, decl))
+	if err != nil && strings.Contains(err.Error(), "duplicate column name") {
+		return nil
+	}
+	return err
+}
+
+func migrateBaseline(tx *txn) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS users (
+			user_id TEXT PRIMARY KEY,
+			balance_raw BIGINT DEFAULT 0
+		);`,
+		`CREATE TABLE IF NOT EXISTS deposits (
+			deposit_id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			timestamp BIGINT NOT NULL DEFAULT {{now}},
+			amount_raw BIGINT NOT NULL,
+			completed BIGINT NOT NULL DEFAULT 0
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_deposit_user ON deposits(user_id);`,
+		`CREATE TABLE IF NOT EXISTS withdrawals (
+			withdraw_id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			timestamp BIGINT NOT NULL DEFAULT {{now}},
+			amount_raw BIGINT NOT NULL,
+			signature TEXT NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_withdrawal_user ON withdrawals(user_id);`,
+		`CREATE TABLE IF NOT EXISTS activity (
+			server_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			score BIGINT DEFAULT 1,
+			last_message_timestamp BIGINT NOT NULL DEFAULT {{now}},
+			PRIMARY KEY (server_id, user_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_activity_server ON activity(server_id);`,
+		`CREATE TABLE IF NOT EXISTS rain_channels (
+			server_id TEXT NOT NULL,
+			channel_id TEXT NOT NULL,
+			PRIMARY KEY (server_id, channel_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS wallets (
+			wallet TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			linked_at BIGINT NOT NULL DEFAULT {{now}}
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_wallet_user ON wallets(user_id);`,
+		`CREATE TABLE IF NOT EXISTS contest (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);`,
+	)
+}
+
+func migrateLedger(tx *txn) error {
+	err := execAll(tx,
+		`CREATE TABLE IF NOT EXISTS ledger (
+			entry_id {{serial}},
+			tx_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			counterparty TEXT NOT NULL DEFAULT '',
+			amount_raw BIGINT NOT NULL,
+			timestamp BIGINT NOT NULL DEFAULT {{now}}
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_ledger_user ON ledger(user_id, timestamp);`,
+		`CREATE INDEX IF NOT EXISTS idx_ledger_tx ON ledger(tx_id);`,
+	)
+	if err != nil {
+		return err
+	}
+	if err := appendOnlyTriggers(tx); err != nil {
+		return err
+	}
+	if err := addColumn(tx, "ledger", "price_usd", "DOUBLE PRECISION"); err != nil {
+		return err
+	}
+
+	// Record opening balances for users who predate the ledger
+	_, err = tx.Exec(`
+		INSERT INTO ledger (tx_id, user_id, kind, counterparty, amount_raw)
+		SELECT 'opening:' || user_id, user_id, CAST(? AS TEXT), '', balance_raw
+		FROM users
+		WHERE balance_raw != 0
+		AND NOT EXISTS (SELECT 1 FROM ledger WHERE ledger.user_id = users.user_id)
+	`, string(LEDGER_OPENING))
+	return err
+}
+
+// appendOnlyTriggers makes the database itself reject changes to ledger rows
+func appendOnlyTriggers(tx *txn) error {
+	if tx.dialect.numbered {
+		return execAll(tx,
+			`CREATE OR REPLACE FUNCTION ledger_append_only() RETURNS trigger AS $$
+			BEGIN
+				RAISE EXCEPTION 'ledger is append-only';
+			END;
+			$$ LANGUAGE plpgsql;`,
+			`DROP TRIGGER IF EXISTS ledger_no_change ON ledger;`,
+			`CREATE TRIGGER ledger_no_change BEFORE UPDATE OR DELETE ON ledger
+			FOR EACH ROW EXECUTE FUNCTION ledger_append_only();`,
+		)
+	}
+	return execAll(tx,
+		`CREATE TRIGGER IF NOT EXISTS ledger_no_update BEFORE UPDATE ON ledger
+		BEGIN
+			SELECT RAISE(ABORT, 'ledger is append-only');
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS ledger_no_delete BEFORE DELETE ON ledger
+		BEGIN
+			SELECT RAISE(ABORT, 'ledger is append-only');
+		END;`,
+	)
+}
+
+func migrateDepositExpiry(tx *txn) error {
+	if err := addColumn(tx, "deposits", "expired", "BIGINT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	return execAll(tx, `CREATE INDEX IF NOT EXISTS idx_deposit_completed ON deposits(completed);`)
+}
+
+func migrateWithdrawalStatus(tx *txn) error {
+	if err := addColumn(tx, "withdrawals", "status", "TEXT NOT NULL DEFAULT 'pending'"); err != nil {
+		return err
+	}
+	return execAll(tx, `CREATE INDEX IF NOT EXISTS idx_withdrawal_status ON withdrawals(status);`)
+}
+
+func migrateAdmins(tx *txn) error {
+	err := execAll(tx, `CREATE TABLE IF NOT EXISTS admins (
+		scope TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		role TEXT NOT NULL,
+		granted_by TEXT NOT NULL DEFAULT '',
+		timestamp BIGINT NOT NULL DEFAULT {{now}},
+		PRIMARY KEY (scope, user_id)
+	);`)
+	if err != nil {
+		return err
+	}
+
+	// Seed the original hard-coded admin, so there's always someone to grant roles
+	_, err = tx.Exec(
+		"INSERT INTO admins (scope, user_id, role) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+		SCOPE_GLOBAL, constants.VIOLET_ID, ROLE_ADMIN,
+	)
+	return err
+}
+
+func migrateGuildSettings(tx *txn) error {
+	return execAll(tx, `CREATE TABLE IF NOT EXISTS guild_settings (
+		server_id TEXT PRIMARY KEY,
+		rain_min_amount_usd DOUBLE PRECISION NOT NULL,
+		rain_min_active_count BIGINT NOT NULL,
+		rain_activity_requirement BIGINT NOT NULL,
+		activity_max BIGINT NOT NULL,
+		activity_delta_min BIGINT NOT NULL,
+		activity_delta_max BIGINT NOT NULL,
+		activity_delta_reset BIGINT NOT NULL
+	);`)
+}
+
+func migrateTelegramChats(tx *txn) error {
+	return execAll(tx, `CREATE TABLE IF NOT EXISTS telegram_chats (
+		chat_id TEXT PRIMARY KEY,
+		title TEXT NOT NULL DEFAULT '',
+		registered_by TEXT NOT NULL,
+		timestamp BIGINT NOT NULL DEFAULT {{now}}
+	);`)
+}
+
+func migratePendingActions(tx *txn) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS pending_actions (
+			action_id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			expires_at BIGINT NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_pending_action_expiry ON pending_actions(expires_at);`,
+	)
+}
+
+func migrateTelegramUsernames(tx *txn) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS telegram_usernames (
+			username TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			seen_at BIGINT NOT NULL,
+			PRIMARY KEY (username, user_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_telegram_username_user ON telegram_usernames(user_id);`,
+	)
+}
+
+func migrateScheduledRains(tx *txn) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS scheduled_rains (
+			schedule_id {{serial}},
+			owner_id TEXT NOT NULL,
+			server_id TEXT NOT NULL,
+			amount_raw BIGINT NOT NULL,
+			interval_seconds BIGINT NOT NULL,
+			max_users BIGINT NOT NULL DEFAULT 0,
+			next_run BIGINT NOT NULL,
+			paused BIGINT NOT NULL DEFAULT 0,
+			created_at BIGINT NOT NULL DEFAULT {{now}}
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_scheduled_rain_owner ON scheduled_rains(owner_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_scheduled_rain_next_run ON scheduled_rains(next_run);`,
+	)
+}
+
+func migrateRaffles(tx *txn) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS raffles (
+			raffle_id {{serial}},
+			owner_id TEXT NOT NULL,
+			chat_id TEXT NOT NULL,
+			channel_id TEXT NOT NULL,
+			message_id TEXT NOT NULL DEFAULT '',
+			prize_raw BIGINT NOT NULL,
+			seed_hash TEXT NOT NULL,
+			seed TEXT NOT NULL,
+			ends_at BIGINT NOT NULL,
+			status TEXT NOT NULL,
+			winner_id TEXT NOT NULL DEFAULT '',
+			created_at BIGINT NOT NULL DEFAULT {{now}}
+		);`,
+		// One open raffle per chat, so joining doesn't need an ID
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_raffle_open_chat ON raffles(chat_id) WHERE status = 'open';`,
+		`CREATE INDEX IF NOT EXISTS idx_raffle_message ON raffles(message_id);`,
+		`CREATE TABLE IF NOT EXISTS raffle_entries (
+			raffle_id BIGINT NOT NULL,
+			user_id TEXT NOT NULL,
+			joined_at BIGINT NOT NULL DEFAULT {{now}},
+			PRIMARY KEY (raffle_id, user_id)
+		);`,
+	)
+}
+
+func migrateBounties(tx *txn) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS bounties (
+			bounty_id {{serial}},
+			creator_id TEXT NOT NULL,
+			chat_id TEXT NOT NULL,
+			description TEXT NOT NULL,
+			amount_raw BIGINT NOT NULL,
+			status TEXT NOT NULL,
+			winner_id TEXT NOT NULL DEFAULT '',
+			created_at BIGINT NOT NULL DEFAULT {{now}},
+			closed_at BIGINT NOT NULL DEFAULT 0
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_bounty_chat_status ON bounties(chat_id, status);`,
+		`CREATE INDEX IF NOT EXISTS idx_bounty_creator ON bounties(creator_id);`,
+	)
+}
+
+func migrateVestings(tx *txn) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS vestings (
+			vesting_id {{serial}},
+			sender_id TEXT NOT NULL,
+			recipient_id TEXT NOT NULL,
+			amount_raw BIGINT NOT NULL,
+			released_raw BIGINT NOT NULL DEFAULT 0,
+			start_at BIGINT NOT NULL,
+			cliff_at BIGINT NOT NULL,
+			end_at BIGINT NOT NULL,
+			created_at BIGINT NOT NULL DEFAULT {{now}}
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_vesting_recipient ON vestings(recipient_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_vesting_cliff ON vestings(cliff_at);`,
+	)
+}
+
+func migratePriceSamples(tx *txn) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS price_samples (
+			timestamp BIGINT NOT NULL,
+			price_usd DOUBLE PRECISION NOT NULL,
+			sources INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_price_samples_timestamp ON price_samples(timestamp);`,
+	)
+}
+
+func migratePriceAlerts(tx *txn) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS price_alerts (
+			alert_id {{serial}},
+			user_id TEXT NOT NULL,
+			direction TEXT NOT NULL,
+			target_usd DOUBLE PRECISION NOT NULL,
+			rearm BIGINT NOT NULL DEFAULT 0,
+			armed BIGINT NOT NULL DEFAULT 1,
+			created_at BIGINT NOT NULL DEFAULT {{now}},
+			fired_at BIGINT NOT NULL DEFAULT 0
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_price_alert_user ON price_alerts(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_price_alert_armed ON price_alerts(armed);`,
+	)
+}
+
+func migrateScheduledRainConfirmations(tx *txn) error {
+	return addColumn(tx, "scheduled_rains", "confirmed_raw", "BIGINT NOT NULL DEFAULT 0")
+}
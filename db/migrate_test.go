@@ -0,0 +1,208 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+)
+
+// newV0Database writes the pre-versioning fixture to a fresh SQLite file
+func newV0Database(t *testing.T) string {
+	t.Helper()
+	fixture, err := os.ReadFile(filepath.Join("testdata", "v0.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bot.db")
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if _, err := raw.Exec(string(fixture)); err != nil {
+		t.Fatalf("loading fixture: %v", err)
+	}
+	return path
+}
+
+func openTest(t *testing.T, path string) sqlDatabase {
+	t.Helper()
+	database, err := open(BACKEND_SQLITE, path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestMigrateFromV0(t *testing.T) {
+	database := openTest(t, newV0Database(t))
+
+	version, err := database.schemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != SchemaVersion() {
+		t.Fatalf("schema version = %d, want %d", version, SchemaVersion())
+	}
+
+	// Existing rows survive and pick up column defaults
+	balance, err := database.GetUserBalanceRaw("111")
+	if err != nil || balance != 1500000000 {
+		t.Fatalf("balance = %d, %v", balance, err)
+	}
+	withdrawals, err := database.ListWithdrawals("111", 10)
+	if err != nil || len(withdrawals) != 1 || withdrawals[0].Status != WITHDRAWAL_PENDING {
+		t.Fatalf("withdrawals = %+v, %v", withdrawals, err)
+	}
+	pending, err := database.ListPendingDeposits()
+	if err != nil || len(pending) != 1 || pending[0].DepositID != "bb" || pending[0].Expired {
+		t.Fatalf("pending deposits = %+v, %v", pending, err)
+	}
+
+	// Opening balances were backfilled into the ledger
+	mismatches, err := database.VerifyLedger()
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("VerifyLedger = %+v, %v", mismatches, err)
+	}
+	entries, err := database.ListLedger("tg:222", LedgerFilter{}, 10, 0)
+	if err != nil || len(entries) != 1 || entries[0].Kind != LEDGER_OPENING || entries[0].AmountRaw != 250000000 {
+		t.Fatalf("ledger = %+v, %v", entries, err)
+	}
+
+	// The original admin was seeded
+	role, err := database.GetRole(SCOPE_GLOBAL, constants.VIOLET_ID)
+	if err != nil || role != ROLE_ADMIN {
+		t.Fatalf("role = %q, %v", role, err)
+	}
+
+	// Tables added later are usable
+	if err := database.SetRainSettings("g1", DefaultRainSettings()); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.RegisterTelegramChat("tg:-1", "group", "tg:222"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateFresh(t *testing.T) {
+	database := openTest(t, filepath.Join(t.TempDir(), "bot.db"))
+
+	version, err := database.schemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != SchemaVersion() {
+		t.Fatalf("schema version = %d, want %d", version, SchemaVersion())
+	}
+}
+
+func TestMigrateTwice(t *testing.T) {
+	path := newV0Database(t)
+	first, err := open(BACKEND_SQLITE, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Close()
+
+	// Reopening applies nothing and duplicates nothing
+	database := openTest(t, path)
+	var versions, openings int
+	if err := database.inner.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&versions); err != nil {
+		t.Fatal(err)
+	}
+	if versions != SchemaVersion() {
+		t.Fatalf("%d schema_version rows, want %d", versions, SchemaVersion())
+	}
+	if err := database.inner.QueryRow("SELECT COUNT(*) FROM ledger WHERE kind = 'opening'").Scan(&openings); err != nil {
+		t.Fatal(err)
+	}
+	if openings != 2 {
+		t.Fatalf("%d opening entries, want 2", openings)
+	}
+}
+
+// Databases from before versioning may already have some later tables and
+// columns, which their migrations must tolerate
+func TestMigratePartiallyApplied(t *testing.T) {
+	path := newV0Database(t)
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = raw.Exec(`ALTER TABLE withdrawals ADD COLUMN status TEXT NOT NULL DEFAULT 'pending';
+		UPDATE withdrawals SET status = 'claimed';`)
+	raw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	database := openTest(t, path)
+	withdrawals, err := database.ListWithdrawals("111", 10)
+	if err != nil || len(withdrawals) != 1 || withdrawals[0].Status != WITHDRAWAL_CLAIMED {
+		t.Fatalf("withdrawals = %+v, %v", withdrawals, err)
+	}
+}
+
+func TestRefuseNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+	database, err := open(BACKEND_SQLITE, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = database.inner.Exec(
+		"INSERT INTO schema_version (version, description) VALUES (?, 'from the future')",
+		SchemaVersion()+1,
+	)
+	database.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = open(BACKEND_SQLITE, path)
+	if err == nil || !strings.Contains(err.Error(), "only knows up to") {
+		t.Fatalf("New = %v, want schema version error", err)
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+	database, err := open(BACKEND_SQLITE, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.Close()
+
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = append(migrations[:len(migrations):len(migrations)], migration{
+		description: "broken",
+		apply: func(tx *txn) error {
+			if _, err := tx.Exec("CREATE TABLE half_done (id INTEGER)"); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		},
+	})
+
+	if _, err := open(BACKEND_SQLITE, path); err == nil {
+		t.Fatal("New succeeded with a broken migration")
+	}
+
+	migrations = saved
+	database = openTest(t, path)
+	version, err := database.schemaVersion()
+	if err != nil || version != SchemaVersion() {
+		t.Fatalf("schema version = %d, %v", version, err)
+	}
+	var tables int
+	err = database.inner.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'").Scan(&tables)
+	if err != nil || tables != 0 {
+		t.Fatalf("half_done tables = %d, %v", tables, err)
+	}
+}
@@ -0,0 +1,41 @@
+package db
+
+import "time"
+
+// PendingAction is a command held back until the user who sent it confirms it
+type PendingAction struct {
+	ActionID string
+	UserID   string
+	// What the command does, e.g. "tip"
+	Kind string
+	// The command itself, encoded by the caller
+	Payload   string
+	ExpiresAt int64
+}
+
+// CreatePendingAction stores an action, pruning the ones nobody answered
+func (db sqlDatabase) CreatePendingAction(action PendingAction) error {
+	_, err := db.exec("DELETE FROM pending_actions WHERE expires_at <= ?", time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	_, err = db.exec(
+		"INSERT INTO pending_actions (action_id, user_id, kind, payload, expires_at) VALUES (?, ?, ?, ?, ?)",
+		action.ActionID, action.UserID, action.Kind, action.Payload, action.ExpiresAt,
+	)
+	return err
+}
+
+// TakePendingAction removes and returns an unexpired action belonging to
+// userID, so it can only be answered once. Returns sql.ErrNoRows otherwise.
+func (db sqlDatabase) TakePendingAction(actionID, userID string) (PendingAction, error) {
+	var action PendingAction
+	err := db.queryRow(`
+		DELETE FROM pending_actions
+		WHERE action_id = ? AND user_id = ? AND expires_at > ?
+		RETURNING action_id, user_id, kind, payload, expires_at
+	`, actionID, userID, time.Now().Unix()).Scan(
+		&action.ActionID, &action.UserID, &action.Kind, &action.Payload, &action.ExpiresAt,
+	)
+	return action, err
+}
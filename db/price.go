@@ -0,0 +1,57 @@
+package db
+
+import "github.com/ivypowered/ivy-sprite-bot/price"
+
+// AddPriceSample records a price sample
+func (db sqlDatabase) AddPriceSample(sample price.Sample) error {
+	_, err := db.exec(
+		"INSERT INTO price_samples (timestamp, price_usd, sources) VALUES (?, ?, ?)",
+		sample.Timestamp, sample.PriceUSD, sample.Sources,
+	)
+	return err
+}
+
+// LatestPriceSample returns the newest price sample, or sql.ErrNoRows
+func (db sqlDatabase) LatestPriceSample() (price.Sample, error) {
+	var sample price.Sample
+	err := db.queryRow(
+		"SELECT timestamp, price_usd, sources FROM price_samples ORDER BY timestamp DESC LIMIT 1",
+	).Scan(&sample.Timestamp, &sample.PriceUSD, &sample.Sources)
+	if err != nil {
+		return price.Sample{}, err
+	}
+	return sample, nil
+}
+
+// ListPriceSamples returns the price samples taken at or after since, oldest
+// first
+func (db sqlDatabase) ListPriceSamples(since int64) ([]price.Sample, error) {
+	rows, err := db.query(
+		"SELECT timestamp, price_usd, sources FROM price_samples WHERE timestamp >= ? ORDER BY timestamp",
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []price.Sample
+	for rows.Next() {
+		var sample price.Sample
+		if err := rows.Scan(&sample.Timestamp, &sample.PriceUSD, &sample.Sources); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	return samples, rows.Err()
+}
+
+// PrunePriceSamples deletes the price samples taken before before, returning
+// how many were deleted
+func (db sqlDatabase) PrunePriceSamples(before int64) (int64, error) {
+	res, err := db.exec("DELETE FROM price_samples WHERE timestamp < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
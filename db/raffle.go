@@ -0,0 +1,229 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+// RaffleStatus is where a raffle is in its lifecycle
+type RaffleStatus string
+
+const (
+	// Accepting entries, prize held in escrow
+	RAFFLE_OPEN RaffleStatus = "open"
+	// Winner drawn and paid
+	RAFFLE_DRAWN RaffleStatus = "drawn"
+	// Nobody entered, prize returned to the owner
+	RAFFLE_REFUNDED RaffleStatus = "refunded"
+)
+
+// Raffle is a prize escrowed from its owner and given to one random entrant
+type Raffle struct {
+	RaffleID int64
+	OwnerID  string
+	// Database ID of the server or group the raffle runs in
+	ChatID string
+	// Where the raffle was announced: a Discord channel, or the Telegram group
+	ChannelID string
+	// Announcement users can react to, "" if none
+	MessageID string
+	PrizeRaw  uint64
+	// SHA-256 of Seed, published when the raffle starts
+	SeedHash string
+	// Kept secret until the draw
+	Seed   string
+	EndsAt int64
+	Status RaffleStatus
+	// "" until drawn
+	WinnerID  string
+	CreatedAt int64
+}
+
+const raffleColumns = "raffle_id, owner_id, chat_id, channel_id, message_id, prize_raw, seed_hash, seed, ends_at, status, winner_id, created_at"
+
+func scanRaffles(rows *sql.Rows) ([]Raffle, error) {
+	defer rows.Close()
+
+	var raffles []Raffle
+	for rows.Next() {
+		var r Raffle
+		var status string
+		err := rows.Scan(&r.RaffleID, &r.OwnerID, &r.ChatID, &r.ChannelID, &r.MessageID, &r.PrizeRaw, &r.SeedHash, &r.Seed, &r.EndsAt, &status, &r.WinnerID, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		r.Status = RaffleStatus(status)
+		raffles = append(raffles, r)
+	}
+	return raffles, rows.Err()
+}
+
+// getRaffle returns the first raffle matching a WHERE clause, or sql.ErrNoRows
+func (db sqlDatabase) getRaffle(where string, args ...interface{}) (Raffle, error) {
+	rows, err := db.query("SELECT "+raffleColumns+" FROM raffles WHERE "+where+" LIMIT 1", args...)
+	if err != nil {
+		return Raffle{}, err
+	}
+	raffles, err := scanRaffles(rows)
+	if err != nil {
+		return Raffle{}, err
+	}
+	if len(raffles) == 0 {
+		return Raffle{}, sql.ErrNoRows
+	}
+	return raffles[0], nil
+}
+
+// CreateRaffle opens a raffle and moves its prize from the owner into
+// escrow, failing if the owner can't afford it or the chat already has an
+// open raffle. Returns the new raffle's ID.
+func (db sqlDatabase) CreateRaffle(raffle Raffle) (int64, error) {
+	tx, err := db.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var raffleID int64
+	err = tx.QueryRow(
+		`INSERT INTO raffles (owner_id, chat_id, channel_id, prize_raw, seed_hash, seed, ends_at, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING raffle_id`,
+		raffle.OwnerID, raffle.ChatID, raffle.ChannelID, raffle.PrizeRaw, raffle.SeedHash, raffle.Seed, raffle.EndsAt, string(RAFFLE_OPEN),
+	).Scan(&raffleID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Escrow the prize, if the owner has enough
+	res, err := tx.Exec("UPDATE users SET balance_raw = balance_raw - ? WHERE user_id = ? AND balance_raw >= ?", raffle.PrizeRaw, raffle.OwnerID, raffle.PrizeRaw)
+	if err != nil {
+		return 0, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if aff < 1 {
+		return 0, errors.New("owner not found or balance too low")
+	}
+	if err = appendLedger(tx, newTxID(), raffle.OwnerID, LEDGER_RAFFLE, strconv.FormatInt(raffleID, 10), -int64(raffle.PrizeRaw)); err != nil {
+		return 0, err
+	}
+
+	return raffleID, tx.Commit()
+}
+
+// GetRaffle returns a raffle by ID, or sql.ErrNoRows
+func (db sqlDatabase) GetRaffle(raffleID int64) (Raffle, error) {
+	return db.getRaffle("raffle_id = ?", raffleID)
+}
+
+// GetOpenRaffle returns the open raffle of a chat, or sql.ErrNoRows
+func (db sqlDatabase) GetOpenRaffle(chatID string) (Raffle, error) {
+	return db.getRaffle("chat_id = ? AND status = ?", chatID, string(RAFFLE_OPEN))
+}
+
+// GetRaffleByMessage returns the raffle announced in a message, or sql.ErrNoRows
+func (db sqlDatabase) GetRaffleByMessage(messageID string) (Raffle, error) {
+	return db.getRaffle("message_id = ?", messageID)
+}
+
+// SetRaffleMessage records the announcement users can react to
+func (db sqlDatabase) SetRaffleMessage(raffleID int64, messageID string) error {
+	_, err := db.exec("UPDATE raffles SET message_id = ? WHERE raffle_id = ?", messageID, raffleID)
+	return err
+}
+
+// ListDueRaffles returns the open raffles that ended at or before now
+func (db sqlDatabase) ListDueRaffles(now int64) ([]Raffle, error) {
+	rows, err := db.query(
+		"SELECT "+raffleColumns+" FROM raffles WHERE status = ? AND ends_at <= ? ORDER BY ends_at",
+		string(RAFFLE_OPEN), now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanRaffles(rows)
+}
+
+// JoinRaffle enters a user into a raffle that is open and hasn't ended by
+// now, reporting whether they weren't entered already
+func (db sqlDatabase) JoinRaffle(raffleID int64, userID string, now int64) (bool, error) {
+	result, err := db.exec(
+		`INSERT INTO raffle_entries (raffle_id, user_id)
+		SELECT raffle_id, ? FROM raffles WHERE raffle_id = ? AND status = ? AND ends_at > ?
+		ON CONFLICT DO NOTHING`,
+		userID, raffleID, string(RAFFLE_OPEN), now,
+	)
+	if err != nil {
+		return false, err
+	}
+	aff, err := result.RowsAffected()
+	return aff > 0, err
+}
+
+// ListRaffleEntries returns the users entered into a raffle, ordered by user ID
+func (db sqlDatabase) ListRaffleEntries(raffleID int64) ([]string, error) {
+	rows, err := db.query("SELECT user_id FROM raffle_entries WHERE raffle_id = ? ORDER BY user_id", raffleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// FinishRaffle closes an open raffle and pays out its prize: to winnerID,
+// or back to the owner if winnerID is "". Reports whether the raffle was
+// still open, so a raffle is only ever paid once.
+func (db sqlDatabase) FinishRaffle(raffleID int64, winnerID string) (bool, error) {
+	tx, err := db.begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	status := RAFFLE_DRAWN
+	if winnerID == "" {
+		status = RAFFLE_REFUNDED
+	}
+	var ownerID string
+	var prizeRaw uint64
+	err = tx.QueryRow(
+		"UPDATE raffles SET status = ?, winner_id = ? WHERE raffle_id = ? AND status = ? RETURNING owner_id, prize_raw",
+		string(status), winnerID, raffleID, string(RAFFLE_OPEN),
+	).Scan(&ownerID, &prizeRaw)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	payeeID := winnerID
+	if payeeID == "" {
+		payeeID = ownerID
+	}
+	_, err = tx.Exec("INSERT INTO users (user_id) VALUES (?) ON CONFLICT DO NOTHING", payeeID)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec("UPDATE users SET balance_raw = balance_raw + ? WHERE user_id = ?", prizeRaw, payeeID)
+	if err != nil {
+		return false, err
+	}
+	if err = appendLedger(tx, newTxID(), payeeID, LEDGER_RAFFLE, strconv.FormatInt(raffleID, 10), int64(prizeRaw)); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
@@ -0,0 +1,122 @@
+package db
+
+import "database/sql"
+
+// ScheduledRain is a rain repeated at a fixed interval on behalf of its owner
+type ScheduledRain struct {
+	ScheduleID int64
+	OwnerID    string
+	ServerID   string
+	AmountRaw  uint64
+	// Seconds between runs
+	IntervalSeconds int64
+	// Most users each run rains on, 0 for no limit
+	MaxUsers int
+	// Largest amount the owner confirmed each run for, 0 if they weren't
+	// asked to. Runs of more are checked for confirmation again.
+	ConfirmedRaw uint64
+	// Unix time of the next run
+	NextRun int64
+	// Paused schedules don't run, e.g. after the owner ran out of funds
+	Paused    bool
+	CreatedAt int64
+}
+
+const scheduledRainColumns = "schedule_id, owner_id, server_id, amount_raw, interval_seconds, max_users, confirmed_raw, next_run, paused, created_at"
+
+func scanScheduledRains(rows *sql.Rows) ([]ScheduledRain, error) {
+	defer rows.Close()
+
+	var rains []ScheduledRain
+	for rows.Next() {
+		var r ScheduledRain
+		var paused int
+		err := rows.Scan(&r.ScheduleID, &r.OwnerID, &r.ServerID, &r.AmountRaw, &r.IntervalSeconds, &r.MaxUsers, &r.ConfirmedRaw, &r.NextRun, &paused, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		r.Paused = paused == 1
+		rains = append(rains, r)
+	}
+	return rains, rows.Err()
+}
+
+// CreateScheduledRain stores a new schedule and returns its ID
+func (db sqlDatabase) CreateScheduledRain(rain ScheduledRain) (int64, error) {
+	var scheduleID int64
+	err := db.queryRow(
+		`INSERT INTO scheduled_rains (owner_id, server_id, amount_raw, interval_seconds, max_users, confirmed_raw, next_run)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING schedule_id`,
+		rain.OwnerID, rain.ServerID, rain.AmountRaw, rain.IntervalSeconds, rain.MaxUsers, rain.ConfirmedRaw, rain.NextRun,
+	).Scan(&scheduleID)
+	return scheduleID, err
+}
+
+// ListScheduledRains returns a user's schedules, oldest first
+func (db sqlDatabase) ListScheduledRains(ownerID string) ([]ScheduledRain, error) {
+	rows, err := db.query(
+		"SELECT "+scheduledRainColumns+" FROM scheduled_rains WHERE owner_id = ? ORDER BY schedule_id",
+		ownerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanScheduledRains(rows)
+}
+
+// ListDueScheduledRains returns the unpaused schedules whose next run is at or before now
+func (db sqlDatabase) ListDueScheduledRains(now int64) ([]ScheduledRain, error) {
+	rows, err := db.query(
+		"SELECT "+scheduledRainColumns+" FROM scheduled_rains WHERE paused = 0 AND next_run <= ? ORDER BY next_run",
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanScheduledRains(rows)
+}
+
+// ClaimScheduledRain moves a schedule's next run from fromNextRun to
+// toNextRun, reporting whether this caller won the run. Claiming before
+// running means a run is never repeated, even across restarts.
+func (db sqlDatabase) ClaimScheduledRain(scheduleID, fromNextRun, toNextRun int64) (bool, error) {
+	result, err := db.exec(
+		"UPDATE scheduled_rains SET next_run = ? WHERE schedule_id = ? AND next_run = ? AND paused = 0",
+		toNextRun, scheduleID, fromNextRun,
+	)
+	if err != nil {
+		return false, err
+	}
+	aff, err := result.RowsAffected()
+	return aff > 0, err
+}
+
+// PauseScheduledRain stops a schedule from running until it is resumed
+func (db sqlDatabase) PauseScheduledRain(scheduleID int64) error {
+	_, err := db.exec("UPDATE scheduled_rains SET paused = 1 WHERE schedule_id = ?", scheduleID)
+	return err
+}
+
+// ResumeScheduledRain restarts one of a user's paused schedules at nextRun,
+// reporting whether it was paused
+func (db sqlDatabase) ResumeScheduledRain(scheduleID int64, ownerID string, nextRun int64) (bool, error) {
+	result, err := db.exec(
+		"UPDATE scheduled_rains SET paused = 0, next_run = ? WHERE schedule_id = ? AND owner_id = ? AND paused = 1",
+		nextRun, scheduleID, ownerID,
+	)
+	if err != nil {
+		return false, err
+	}
+	aff, err := result.RowsAffected()
+	return aff > 0, err
+}
+
+// DeleteScheduledRain cancels one of a user's schedules, reporting whether it existed
+func (db sqlDatabase) DeleteScheduledRain(scheduleID int64, ownerID string) (bool, error) {
+	result, err := db.exec("DELETE FROM scheduled_rains WHERE schedule_id = ? AND owner_id = ?", scheduleID, ownerID)
+	if err != nil {
+		return false, err
+	}
+	aff, err := result.RowsAffected()
+	return aff > 0, err
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+)
+
+// RainSettings are the rain and activity rules of one server or group
+type RainSettings struct {
+	// Minimum rain amount in USD
+	MinAmountUSD float64
+	// Amount of people who have to be active for rain to work
+	MinActiveCount int
+	// Activity score required to receive rain
+	ActivityRequirement int
+	// Maximum activity score
+	ActivityMax int
+	// Minimum seconds between messages to increase score
+	ActivityDeltaMin int64
+	// Maximum seconds between messages to increase score
+	ActivityDeltaMax int64
+	// After this many seconds between messages, activity is reset
+	ActivityDeltaReset int64
+}
+
+// DefaultRainSettings returns the settings of servers that haven't changed any
+func DefaultRainSettings() RainSettings {
+	return RainSettings{
+		MinAmountUSD:        constants.RAIN_MIN_AMOUNT_USD,
+		MinActiveCount:      constants.RAIN_MIN_ACTIVE_COUNT,
+		ActivityRequirement: constants.RAIN_ACTIVITY_REQUIREMENT,
+		ActivityMax:         constants.ACTIVITY_MAX,
+		ActivityDeltaMin:    constants.ACTIVITY_DELTA_MIN,
+		ActivityDeltaMax:    constants.ACTIVITY_DELTA_MAX,
+		ActivityDeltaReset:  constants.ACTIVITY_DELTA_RESET,
+	}
+}
+
+// GetRainSettings returns a server's rain settings, or the defaults
+func (db sqlDatabase) GetRainSettings(serverID string) (RainSettings, error) {
+	var rs RainSettings
+	err := db.queryRow(`
+		SELECT rain_min_amount_usd, rain_min_active_count, rain_activity_requirement,
+			activity_max, activity_delta_min, activity_delta_max, activity_delta_reset
+		FROM guild_settings
+		WHERE server_id = ?
+	`, serverID).Scan(
+		&rs.MinAmountUSD, &rs.MinActiveCount, &rs.ActivityRequirement,
+		&rs.ActivityMax, &rs.ActivityDeltaMin, &rs.ActivityDeltaMax, &rs.ActivityDeltaReset,
+	)
+	if err == sql.ErrNoRows {
+		return DefaultRainSettings(), nil
+	}
+	return rs, err
+}
+
+// SetRainSettings stores a server's rain settings
+func (db sqlDatabase) SetRainSettings(serverID string, rs RainSettings) error {
+	_, err := db.exec(`
+		INSERT INTO guild_settings (
+			server_id, rain_min_amount_usd, rain_min_active_count, rain_activity_requirement,
+			activity_max, activity_delta_min, activity_delta_max, activity_delta_reset
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (server_id) DO UPDATE SET
+			rain_min_amount_usd = excluded.rain_min_amount_usd,
+			rain_min_active_count = excluded.rain_min_active_count,
+			rain_activity_requirement = excluded.rain_activity_requirement,
+			activity_max = excluded.activity_max,
+			activity_delta_min = excluded.activity_delta_min,
+			activity_delta_max = excluded.activity_delta_max,
+			activity_delta_reset = excluded.activity_delta_reset
+	`, serverID, rs.MinAmountUSD, rs.MinActiveCount, rs.ActivityRequirement,
+		rs.ActivityMax, rs.ActivityDeltaMin, rs.ActivityDeltaMax, rs.ActivityDeltaReset,
+	)
+	return err
+}
+
+// ResetRainSettings puts a server back on the default rain settings
+func (db sqlDatabase) ResetRainSettings(serverID string) error {
+	_, err := db.exec("DELETE FROM guild_settings WHERE server_id = ?", serverID)
+	return err
+}
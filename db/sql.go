@@ -0,0 +1,187 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	BACKEND_SQLITE   = "sqlite"
+	BACKEND_POSTGRES = "postgres"
+)
+
+// dialect covers the differences between the SQL backends. Queries are
+// written once with ? placeholders; schema statements use {{now}} for the
+// current unix time and {{serial}} for an auto-incrementing primary key.
+type dialect struct {
+	driver   string
+	numbered bool
+	schema   *strings.Replacer
+}
+
+var sqliteDialect = dialect{
+	driver: "sqlite3",
+	schema: strings.NewReplacer(
+		"{{now}}", "(strftime('%s', 'now'))",
+		"{{serial}}", "INTEGER PRIMARY KEY AUTOINCREMENT",
+	),
+}
+
+var postgresDialect = dialect{
+	driver:   "postgres",
+	numbered: true,
+	schema: strings.NewReplacer(
+		"{{now}}", "(EXTRACT(EPOCH FROM now())::BIGINT)",
+		"{{serial}}", "BIGSERIAL PRIMARY KEY",
+	),
+}
+
+// rebind rewrites ? placeholders into the backend's own syntax
+func (d dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(n))
+		} else {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+// PriceFunc returns the current USD price of IVY, which the ledger records
+// next to each entry. If it fails, entries are recorded without a price.
+type PriceFunc func() (float64, error)
+
+// sqlDatabase implements Database on top of database/sql
+type sqlDatabase struct {
+	inner   *sql.DB
+	dialect dialect
+	// nil to record no prices
+	price PriceFunc
+}
+
+// Open connects to the given backend and brings its schema up to date.
+// For SQLite the DSN is a file path, for PostgreSQL a connection string.
+// price may be nil.
+func Open(backend, dsn string, price PriceFunc) (Database, error) {
+	db, err := open(backend, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.price = price
+	return db, nil
+}
+
+func open(backend, dsn string) (sqlDatabase, error) {
+	var d dialect
+	switch backend {
+	case BACKEND_SQLITE, "":
+		d = sqliteDialect
+		dsn = sqliteDSN(dsn)
+	case BACKEND_POSTGRES:
+		d = postgresDialect
+	default:
+		return sqlDatabase{}, fmt.Errorf("unknown database backend %q", backend)
+	}
+
+	sqlDB, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return sqlDatabase{}, err
+	}
+
+	db := sqlDatabase{inner: sqlDB, dialect: d}
+
+	// Bring the schema up to date
+	if err := db.migrate(); err != nil {
+		sqlDB.Close()
+		return sqlDatabase{}, err
+	}
+
+	return db, nil
+}
+
+// sqliteDSN adds what concurrent use needs to a SQLite path. Writers wait
+// for each other rather than failing with "database is locked", WAL lets
+// reads carry on meanwhile, and transactions take the write lock as they
+// begin, so two that read first can't deadlock upgrading to it.
+func sqliteDSN(path string) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+}
+
+// Close closes the database connection
+func (db sqlDatabase) Close() error {
+	return db.inner.Close()
+}
+
+func (db sqlDatabase) exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.inner.Exec(db.dialect.rebind(query), args...)
+}
+
+func (db sqlDatabase) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.inner.Query(db.dialect.rebind(query), args...)
+}
+
+func (db sqlDatabase) queryRow(query string, args ...interface{}) *sql.Row {
+	return db.inner.QueryRow(db.dialect.rebind(query), args...)
+}
+
+func (db sqlDatabase) begin() (*txn, error) {
+	// Look the price up before the transaction opens, so it isn't held open
+	// waiting on whatever provides the price
+	var priceUSD sql.NullFloat64
+	if db.price != nil {
+		if price, err := db.price(); err == nil {
+			priceUSD = sql.NullFloat64{Float64: price, Valid: true}
+		}
+	}
+
+	tx, err := db.inner.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &txn{inner: tx, dialect: db.dialect, priceUSD: priceUSD}, nil
+}
+
+// txn is a transaction that rebinds its queries like sqlDatabase does
+type txn struct {
+	inner   *sql.Tx
+	dialect dialect
+	// USD price of IVY when the transaction began, for its ledger entries
+	priceUSD sql.NullFloat64
+}
+
+func (tx *txn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.inner.Exec(tx.dialect.rebind(query), args...)
+}
+
+func (tx *txn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.inner.Query(tx.dialect.rebind(query), args...)
+}
+
+func (tx *txn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.inner.QueryRow(tx.dialect.rebind(query), args...)
+}
+
+func (tx *txn) Commit() error {
+	return tx.inner.Commit()
+}
+
+func (tx *txn) Rollback() error {
+	return tx.inner.Rollback()
+}
@@ -0,0 +1,58 @@
+-- bot.db as deployed before schema versioning, with a little data in it
+CREATE TABLE users (
+	user_id TEXT PRIMARY KEY,
+	balance_raw INTEGER DEFAULT 0
+);
+CREATE TABLE deposits (
+	deposit_id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	timestamp INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+	amount_raw INTEGER NOT NULL,
+	completed INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_deposit_user ON deposits(user_id);
+CREATE TABLE withdrawals (
+	withdraw_id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	timestamp INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+	amount_raw INTEGER NOT NULL,
+	signature TEXT NOT NULL
+);
+CREATE INDEX idx_withdrawal_user ON withdrawals(user_id);
+CREATE TABLE activity (
+	server_id TEXT NOT NULL,
+	user_id TEXT NOT NULL,
+	score INTEGER DEFAULT 1,
+	last_message_timestamp INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+	PRIMARY KEY (server_id, user_id)
+);
+CREATE INDEX idx_activity_server ON activity(server_id);
+CREATE TABLE rain_channels (
+	server_id TEXT NOT NULL,
+	channel_id TEXT NOT NULL,
+	PRIMARY KEY (server_id, channel_id)
+);
+CREATE TABLE wallets (
+	wallet TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	linked_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+CREATE INDEX idx_wallet_user ON wallets(user_id);
+CREATE TABLE contest (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+
+INSERT INTO users (user_id, balance_raw) VALUES
+	('111', 1500000000),
+	('tg:222', 250000000),
+	('333', 0);
+INSERT INTO deposits (deposit_id, user_id, timestamp, amount_raw, completed) VALUES
+	('aa', '111', 1700000000, 1500000000, 1),
+	('bb', 'tg:222', 1700000100, 100000000, 0);
+INSERT INTO withdrawals (withdraw_id, user_id, timestamp, amount_raw, signature) VALUES
+	('cc', '111', 1700000200, 500000000, 'sig');
+INSERT INTO activity (server_id, user_id, score, last_message_timestamp) VALUES
+	('telegram', 'tg:222', 7, 1700000300);
+INSERT INTO rain_channels (server_id, channel_id) VALUES ('g1', 'c1');
+INSERT INTO contest (key, value) VALUES ('address', 'addr');
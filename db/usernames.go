@@ -0,0 +1,66 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// TelegramUsername records that a user was last seen under a username
+type TelegramUsername struct {
+	// Database ID of the user, "tg:<user id>"
+	UserID string
+	SeenAt int64
+}
+
+// SeeTelegramUsername records the username a user currently has, forgetting
+// any they had before. An empty username only forgets.
+func (db sqlDatabase) SeeTelegramUsername(userID, username string) error {
+	username = strings.ToLower(username)
+
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM telegram_usernames WHERE user_id = ? AND username != ?", userID, username)
+	if err != nil {
+		return err
+	}
+	if username != "" {
+		_, err = tx.Exec(
+			`INSERT INTO telegram_usernames (username, user_id, seen_at) VALUES (?, ?, ?)
+			ON CONFLICT (username, user_id) DO UPDATE SET seen_at = excluded.seen_at`,
+			username, userID, time.Now().Unix(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LookupTelegramUsername returns every user seen under a username since
+// they were last seen under another, most recent first. Usernames are
+// matched case-insensitively.
+func (db sqlDatabase) LookupTelegramUsername(username string) ([]TelegramUsername, error) {
+	rows, err := db.query(
+		"SELECT user_id, seen_at FROM telegram_usernames WHERE username = ? ORDER BY seen_at DESC",
+		strings.ToLower(username),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []TelegramUsername
+	for rows.Next() {
+		var u TelegramUsername
+		if err := rows.Scan(&u.UserID, &u.SeenAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
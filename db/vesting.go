@@ -0,0 +1,148 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Vesting is an amount already taken from its sender that is released to
+// the recipient's balance as time passes
+type Vesting struct {
+	VestingID   int64
+	SenderID    string
+	RecipientID string
+	AmountRaw   uint64
+	// How much has been credited to the recipient so far
+	ReleasedRaw uint64
+	// Unix times: vesting accrues from StartAt to EndAt, but nothing is
+	// released before CliffAt
+	StartAt   int64
+	CliffAt   int64
+	EndAt     int64
+	CreatedAt int64
+}
+
+const vestingColumns = "vesting_id, sender_id, recipient_id, amount_raw, released_raw, start_at, cliff_at, end_at, created_at"
+
+func scanVestings(rows *sql.Rows) ([]Vesting, error) {
+	defer rows.Close()
+
+	var vestings []Vesting
+	for rows.Next() {
+		var v Vesting
+		err := rows.Scan(&v.VestingID, &v.SenderID, &v.RecipientID, &v.AmountRaw, &v.ReleasedRaw, &v.StartAt, &v.CliffAt, &v.EndAt, &v.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		vestings = append(vestings, v)
+	}
+	return vestings, rows.Err()
+}
+
+// CreateVesting debits the sender the whole amount and stores the schedule
+// that releases it, failing if the sender can't afford it. Returns the new
+// vesting's ID.
+func (db sqlDatabase) CreateVesting(vesting Vesting) (int64, error) {
+	tx, err := db.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Deduct from sender, if they have enough
+	res, err := tx.Exec("UPDATE users SET balance_raw = balance_raw - ? WHERE user_id = ? AND balance_raw >= ?", vesting.AmountRaw, vesting.SenderID, vesting.AmountRaw)
+	if err != nil {
+		return 0, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if aff < 1 {
+		return 0, ErrInsufficientBalance
+	}
+
+	var vestingID int64
+	err = tx.QueryRow(
+		`INSERT INTO vestings (sender_id, recipient_id, amount_raw, start_at, cliff_at, end_at)
+		VALUES (?, ?, ?, ?, ?, ?) RETURNING vesting_id`,
+		vesting.SenderID, vesting.RecipientID, vesting.AmountRaw, vesting.StartAt, vesting.CliffAt, vesting.EndAt,
+	).Scan(&vestingID)
+	if err != nil {
+		return 0, err
+	}
+	if err = appendLedger(tx, newTxID(), vesting.SenderID, LEDGER_VESTING, vesting.RecipientID, -int64(vesting.AmountRaw)); err != nil {
+		return 0, err
+	}
+
+	return vestingID, tx.Commit()
+}
+
+// ListReleasableVestings returns the vestings past their cliff at now that
+// haven't been fully released
+func (db sqlDatabase) ListReleasableVestings(now int64) ([]Vesting, error) {
+	rows, err := db.query(
+		"SELECT "+vestingColumns+" FROM vestings WHERE released_raw < amount_raw AND cliff_at <= ? ORDER BY vesting_id",
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanVestings(rows)
+}
+
+// ListLockedVestings returns a recipient's vestings that haven't been fully
+// released, oldest first
+func (db sqlDatabase) ListLockedVestings(recipientID string) ([]Vesting, error) {
+	rows, err := db.query(
+		"SELECT "+vestingColumns+" FROM vestings WHERE recipient_id = ? AND released_raw < amount_raw ORDER BY vesting_id",
+		recipientID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanVestings(rows)
+}
+
+// ReleaseVesting credits the recipient the difference as a vesting's
+// released amount goes from fromReleasedRaw to toReleasedRaw, reporting
+// whether it was still at fromReleasedRaw so nothing is credited twice
+func (db sqlDatabase) ReleaseVesting(vestingID int64, fromReleasedRaw, toReleasedRaw uint64) (bool, error) {
+	if toReleasedRaw <= fromReleasedRaw {
+		return false, errors.New("release must increase the released amount")
+	}
+
+	tx, err := db.begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var senderID, recipientID string
+	err = tx.QueryRow(
+		`UPDATE vestings SET released_raw = ?
+		WHERE vesting_id = ? AND released_raw = ? AND amount_raw >= ? RETURNING sender_id, recipient_id`,
+		toReleasedRaw, vestingID, fromReleasedRaw, toReleasedRaw,
+	).Scan(&senderID, &recipientID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	deltaRaw := toReleasedRaw - fromReleasedRaw
+	_, err = tx.Exec("INSERT INTO users (user_id) VALUES (?) ON CONFLICT DO NOTHING", recipientID)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec("UPDATE users SET balance_raw = balance_raw + ? WHERE user_id = ?", deltaRaw, recipientID)
+	if err != nil {
+		return false, err
+	}
+	if err = appendLedger(tx, newTxID(), recipientID, LEDGER_VESTING, senderID, int64(deltaRaw)); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
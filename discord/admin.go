@@ -0,0 +1,103 @@
+// discord/admin.go
+package discord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const ADMIN_USAGE = "$admin grant @user [role] [global] OR $admin revoke @user [global] OR $admin list [global]"
+const ADMIN_DETAILS = `Manage who can administer the bot. Roles apply to the server they're granted in, or everywhere with ` + "`global`" + `.
+Roles: moderator (default) can change settings such as rain channels, admin can also grant and revoke roles.
+Members with Manage Server are admins of their own server.
+Example: $admin grant @violet admin
+Example: $admin revoke @violet`
+
+func AdminCommand(database db.Database, args []string, c *Context) {
+	if len(args) == 0 {
+		renderError(c, core.ErrUsage, ADMIN_USAGE, ADMIN_DETAILS)
+		return
+	}
+
+	switch args[0] {
+	case "grant", "revoke":
+		if len(args) < 2 {
+			renderError(c, core.ErrUsage, ADMIN_USAGE, ADMIN_DETAILS)
+			return
+		}
+		targetID, ok := parseMention(args[1])
+		if !ok {
+			c.ReactErr()
+			c.Error("Please mention a valid user")
+			return
+		}
+		req := newAdminRequest(c, args[2:])
+		req.Mentions = []string{targetID}
+
+		if args[0] == "grant" {
+			result, err := core.GrantRole(database, req)
+			if err != nil {
+				renderError(c, err, ADMIN_USAGE, ADMIN_DETAILS)
+				return
+			}
+			c.ReactOk()
+			c.Success(
+				fmt.Sprintf("%s is now **%s** in %s", formatUser(result.UserID), result.Role, formatScope(result.Scope)),
+				"Role Granted", "")
+		} else {
+			result, err := core.RevokeRole(database, req)
+			if err != nil {
+				renderError(c, err, ADMIN_USAGE, ADMIN_DETAILS)
+				return
+			}
+			c.ReactOk()
+			c.Success(
+				fmt.Sprintf("%s no longer has a role in %s", formatUser(result.UserID), formatScope(result.Scope)),
+				"Role Revoked", "")
+		}
+
+	case "list":
+		scope, grants, err := core.ListRoles(database, newAdminRequest(c, args[1:]))
+		if err != nil {
+			renderError(c, err, ADMIN_USAGE, ADMIN_DETAILS)
+			return
+		}
+
+		var list strings.Builder
+		for _, g := range grants {
+			list.WriteString(fmt.Sprintf("• %s - %s\n", formatUser(g.UserID), g.Role))
+		}
+		if len(grants) == 0 {
+			list.WriteString("No roles granted")
+		}
+
+		c.ReactOk()
+		c.Success(list.String(), "Roles in "+formatScope(scope), "")
+
+	default:
+		renderError(c, core.ErrUsage, ADMIN_USAGE, ADMIN_DETAILS)
+	}
+}
+
+// newAdminRequest is newRequest for privileged commands, which also asks
+// Discord whether the caller can manage the server
+func newAdminRequest(c *Context, args []string) core.Request {
+	req := newRequest(c, args)
+	if c.GuildID != "" {
+		perms, err := c.Session.UserChannelPermissions(c.Author.ID, c.ChannelID)
+		req.ChatAdmin = err == nil && perms&(discordgo.PermissionAdministrator|discordgo.PermissionManageGuild) != 0
+	}
+	return req
+}
+
+// formatScope renders a role scope for display
+func formatScope(scope string) string {
+	if scope == db.SCOPE_GLOBAL {
+		return "all servers and groups"
+	}
+	return "this server"
+}
@@ -0,0 +1,77 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const ALERT_USAGE_NAME string = "$alert above|below <price> [rearm] OR $alert list OR $alert remove <id>"
+const ALERT_USAGE_DETAILS string = `Get a DM when the IVY price crosses a level.
+
+• $alert above 0.05 - Tell me once IVY reaches $0.05
+• $alert below 0.03 rearm - Tell me every time IVY drops to $0.03, after it has gone back up
+• $alert list - Show your alerts
+• $alert remove 3 - Remove alert #3`
+
+func AlertCommand(database db.Database, args []string, c *Context) {
+	if len(args) < 1 {
+		renderError(c, core.ErrUsage, ALERT_USAGE_NAME, ALERT_USAGE_DETAILS)
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		handleAlertList(database, c, newRequest(c, args[1:]))
+	case "remove":
+		alertID, err := core.RemoveAlert(database, newRequest(c, args[1:]))
+		if err != nil {
+			renderError(c, err, ALERT_USAGE_NAME, ALERT_USAGE_DETAILS)
+			return
+		}
+		c.ReactOk()
+		c.Success(fmt.Sprintf("Alert #%d was removed", alertID), "Alert Removed", "")
+	default:
+		alert, err := core.CreateAlert(database, newRequest(c, args))
+		if err != nil {
+			renderError(c, err, ALERT_USAGE_NAME, ALERT_USAGE_DETAILS)
+			return
+		}
+		c.ReactOk()
+		footer := "It fires once, then stays in $alert list until you remove it"
+		if alert.Rearm {
+			footer = "It fires again each time the price crosses back"
+		}
+		c.Success(
+			fmt.Sprintf("You'll get a DM when IVY goes %s **%s**", alert.Direction, core.FormatPriceUSD(alert.TargetUSD)),
+			fmt.Sprintf("Alert #%d Set", alert.AlertID),
+			footer)
+	}
+}
+
+func handleAlertList(database db.Database, c *Context, req core.Request) {
+	alerts, err := core.ListAlerts(database, req)
+	if err != nil {
+		renderError(c, err, ALERT_USAGE_NAME, ALERT_USAGE_DETAILS)
+		return
+	}
+	c.ReactOk()
+
+	if len(alerts) == 0 {
+		c.Success("No alerts yet. Set one with `$alert above <price>` or `$alert below <price>`", "Your Alerts", "")
+		return
+	}
+	lines := make([]string, len(alerts))
+	for i, alert := range alerts {
+		lines[i] = fmt.Sprintf("**#%d** • %s **%s**", alert.AlertID, alert.Direction, core.FormatPriceUSD(alert.TargetUSD))
+		if alert.Rearm {
+			lines[i] += " • rearms"
+		}
+		if !alert.Armed {
+			lines[i] += fmt.Sprintf(" • fired <t:%d:R>", alert.FiredAt)
+		}
+	}
+	c.Success(strings.Join(lines, "\n"), "Your Alerts", "")
+}
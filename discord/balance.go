@@ -6,36 +6,48 @@ import (
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 	"github.com/ivypowered/ivy-sprite-bot/db"
 )
 
-func BalanceCommand(database db.Database, args []string, s *discordgo.Session, m *discordgo.MessageCreate) {
-	database.EnsureUserExists(m.Author.ID)
-
-	balanceRaw, err := database.GetUserBalanceRaw(m.Author.ID)
+func BalanceCommand(database db.Database, args []string, c *Context) {
+	result, err := core.Balance(database, newRequest(c, args))
 	if err != nil {
-		DmError(s, m.Author.ID, err.Error())
+		c.Error(err.Error())
 		return
 	}
 
 	// Convert RAW to display value
-	balance := float64(balanceRaw) / constants.IVY_FACTOR
+	balance := float64(result.BalanceRaw) / constants.IVY_FACTOR
 
 	// Create the embed for DM
-	name := m.Author.GlobalName
+	name := c.Author.GlobalName
 	if name == "" {
-		name = m.Author.Username
+		name = c.Author.Username
+	}
+	// USD values are left out while there's no fresh price
+	usd := func(ivy float64) string {
+		if result.Price <= 0 {
+			return ""
+		}
+		return fmt.Sprintf(" (\U00002248 $%.2f)", ivy*result.Price)
 	}
-	price := constants.PRICE.Get(constants.RPC_CLIENT)
 	embed := &discordgo.MessageEmbed{
 		Color: constants.IVY_GREEN,
 		Author: &discordgo.MessageEmbedAuthor{
 			Name:    name + "'s Ivy wallet",
-			IconURL: m.Author.AvatarURL("128"),
+			IconURL: c.Author.AvatarURL("128"),
 		},
-		Description: fmt.Sprintf("**Balance**\n<:ivy:1398745198472986654> **%.9f IVY** (\U00002248 $%.2f)", balance, balance*price),
+		Description: fmt.Sprintf("**Balance**\n<:ivy:1398745198472986654> **%.9f IVY**%s", balance, usd(balance)),
+	}
+	if result.LockedRaw > 0 {
+		locked := float64(result.LockedRaw) / constants.IVY_FACTOR
+		embed.Description += fmt.Sprintf("\n\n**Locked in vesting**\n🔒 **%.9f IVY**%s", locked, usd(locked))
+	}
+	if result.Price <= 0 {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: "USD values are unavailable until the IVY price updates"}
 	}
 
 	// Send balance via DM
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	c.Send(embed)
 }
@@ -0,0 +1,143 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const BOUNTY_USAGE_NAME string = "$bounty create <amount> <description> OR $bounty award <id> @user OR $bounty cancel <id> OR $bounty list"
+const BOUNTY_USAGE_DETAILS string = `Offer IVY for a task, like a bug report or art, and pay whoever does it.
+
+• $bounty create 25 Draw a banner for the server - Post a bounty
+• $bounty award 3 @user - Pay bounty #3 to a user
+• $bounty cancel 3 - Take bounty #3 down and get the reward back
+• $bounty list - Show this server's open bounties, or your own in DMs
+
+The reward is taken from your balance when the bounty is posted and held until you award or cancel it.`
+
+func BountyCommand(database db.Database, args []string, c *Context) {
+	if len(args) < 1 {
+		renderError(c, core.ErrUsage, BOUNTY_USAGE_NAME, BOUNTY_USAGE_DETAILS)
+		return
+	}
+
+	switch args[0] {
+	case "create":
+		runBountyCreate(database, c, newRequest(c, args[1:]))
+	case "award":
+		var rest []string
+		var winnerIDs []string
+		for _, arg := range args[1:] {
+			if userID, ok := parseMention(arg); ok {
+				winnerIDs = append(winnerIDs, userID)
+			} else {
+				rest = append(rest, arg)
+			}
+		}
+		req := newRequest(c, rest)
+		req.Mentions = winnerIDs
+		handleBountyAward(database, c, req)
+	case "cancel":
+		bounty, err := core.CancelBounty(database, newRequest(c, args[1:]))
+		if err != nil {
+			renderError(c, err, BOUNTY_USAGE_NAME, BOUNTY_USAGE_DETAILS)
+			return
+		}
+		c.ReactOk()
+		c.Success(
+			fmt.Sprintf("Bounty #%d was cancelled and its **%.9f** IVY reward returned to your balance",
+				bounty.BountyID, float64(bounty.AmountRaw)/constants.IVY_FACTOR),
+			"Bounty Cancelled",
+			"")
+	case "list":
+		handleBountyList(database, c, newRequest(c, args[1:]))
+	default:
+		renderError(c, core.ErrUsage, BOUNTY_USAGE_NAME, BOUNTY_USAGE_DETAILS)
+	}
+}
+
+func runBountyCreate(database db.Database, c *Context, req core.Request) {
+	bounty, err := core.CreateBounty(database, req)
+	if err != nil {
+		renderActionError(database, c, err, BOUNTY_USAGE_NAME, BOUNTY_USAGE_DETAILS)
+		return
+	}
+	c.ReactOk()
+
+	c.Send(&discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("💰 Bounty #%d", bounty.BountyID),
+		Description: fmt.Sprintf("%s\n\nReward: **%.9f** IVY, posted by <@%s>", bounty.Description, float64(bounty.AmountRaw)/constants.IVY_FACTOR, bounty.CreatorID),
+		Color:       constants.IVY_GREEN,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("The reward is held in escrow until it's awarded with $bounty award %d @user", bounty.BountyID),
+		},
+	})
+}
+
+func handleBountyAward(database db.Database, c *Context, req core.Request) {
+	bounty, err := core.AwardBounty(database, req)
+	if err != nil {
+		renderError(c, err, BOUNTY_USAGE_NAME, BOUNTY_USAGE_DETAILS)
+		return
+	}
+	amount := float64(bounty.AmountRaw) / constants.IVY_FACTOR
+	c.ReactOk()
+
+	if !c.Private() {
+		c.Send(&discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("💰 Bounty #%d Awarded", bounty.BountyID),
+			Description: fmt.Sprintf("%s earned **%.9f** IVY for:\n%s", formatUser(bounty.WinnerID), amount, bounty.Description),
+			Color:       constants.IVY_GREEN,
+		})
+	}
+	c.Success(
+		fmt.Sprintf("Bounty #%d's **%.9f** IVY reward was paid to %s", bounty.BountyID, amount, formatUser(bounty.WinnerID)),
+		"Bounty Awarded",
+		"")
+
+	if core.IsTelegramID(bounty.WinnerID) {
+		return
+	}
+	DmSuccess(c.Session, bounty.WinnerID,
+		fmt.Sprintf("You earned **%.9f** IVY from <@%s>'s bounty #%d:\n%s", amount, bounty.CreatorID, bounty.BountyID, bounty.Description),
+		"Bounty Awarded",
+		"")
+}
+
+func handleBountyList(database db.Database, c *Context, req core.Request) {
+	bounties, err := core.ListBounties(database, req)
+	if err != nil {
+		renderError(c, err, BOUNTY_USAGE_NAME, BOUNTY_USAGE_DETAILS)
+		return
+	}
+	c.ReactOk()
+
+	title := "Open Bounties"
+	if req.Private {
+		title = "Your Bounties"
+	}
+	if len(bounties) == 0 {
+		c.Success("No bounties yet. Post one with `$bounty create <amount> <description>`", title, "")
+		return
+	}
+
+	var lines []string
+	for _, bounty := range bounties {
+		line := fmt.Sprintf("**#%d** • **%.9f** IVY • %s", bounty.BountyID, float64(bounty.AmountRaw)/constants.IVY_FACTOR, bounty.Description)
+		switch {
+		case bounty.Status == db.BOUNTY_AWARDED:
+			line += fmt.Sprintf(" • awarded to %s", formatUser(bounty.WinnerID))
+		case bounty.Status != db.BOUNTY_OPEN:
+			line += fmt.Sprintf(" • %s", bounty.Status)
+		case !req.Private:
+			line += fmt.Sprintf(" • by <@%s>", bounty.CreatorID)
+		}
+		lines = append(lines, line)
+	}
+	c.Success(strings.Join(lines, "\n"), title, "")
+}
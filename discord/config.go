@@ -0,0 +1,49 @@
+// discord/config.go
+package discord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const CONFIG_USAGE = "$config rain OR $config rain <setting> <value> OR $config rain reset"
+const CONFIG_DETAILS = `Show or change this server's rain rules. Changing them requires the moderator role or Manage Server.
+Example: $config rain min_usd 1.00
+Example: $config rain delta_max 30m`
+
+func ConfigCommand(database db.Database, args []string, c *Context) {
+	if len(args) == 0 || args[0] != "rain" {
+		renderError(c, core.ErrUsage, CONFIG_USAGE, CONFIG_DETAILS)
+		return
+	}
+	args = args[1:]
+
+	var settings db.RainSettings
+	var err error
+	title := "Rain Settings"
+	switch {
+	case len(args) == 0:
+		settings, err = core.RainConfig(database, newRequest(c, args))
+	case len(args) == 1 && args[0] == "reset":
+		settings, err = core.ResetRainConfig(database, newAdminRequest(c, nil))
+		title = "Rain Settings Reset"
+	default:
+		settings, err = core.SetRainConfig(database, newAdminRequest(c, args))
+		title = "Rain Settings Updated"
+	}
+	if err != nil {
+		renderError(c, err, CONFIG_USAGE, CONFIG_DETAILS)
+		return
+	}
+
+	var text strings.Builder
+	for _, key := range core.RAIN_CONFIG_KEYS {
+		text.WriteString(fmt.Sprintf("`%s` **%s** - %s\n", key.Name, key.Get(settings), key.Description))
+	}
+
+	c.ReactOk()
+	c.Success(text.String(), title, "")
+}
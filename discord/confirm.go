@@ -0,0 +1,129 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// actionFunc runs a request that may be held back for confirmation
+type actionFunc func(database db.Database, c *Context, req core.Request)
+
+// Commands that can be resumed once the caller confirms them
+var CONFIRMABLE = map[core.ActionKind]actionFunc{
+	core.ACTION_TIP:           runTip,
+	core.ACTION_RAIN:          runRain,
+	core.ACTION_WITHDRAW:      runWithdraw,
+	core.ACTION_RAFFLE:        runRaffleStart,
+	core.ACTION_BOUNTY:        runBountyCreate,
+	core.ACTION_VEST:          runVest,
+	core.ACTION_RAIN_SCHEDULE: runRainSchedule,
+}
+
+// renderActionError asks the caller to confirm a request core held back,
+// or renders any other error like renderError
+func renderActionError(database db.Database, c *Context, err error, usageName string, usageDetails string) {
+	var confirm *core.ConfirmationRequiredError
+	if !errors.As(err, &confirm) {
+		renderError(c, err, usageName, usageDetails)
+		return
+	}
+
+	actionID, err := core.SavePendingAction(database, confirm)
+	if err != nil {
+		renderError(c, err, usageName, usageDetails)
+		return
+	}
+
+	c.ReactClock()
+	embed := clockEmbed("Confirm "+string(confirm.Kind), confirm.Error())
+	embed.Footer = &discordgo.MessageEmbedFooter{
+		Text: fmt.Sprintf("Expires in %s", constants.CONFIRM_EXPIRY),
+	}
+	c.Prompt(embed, []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Confirm",
+					Style:    discordgo.SuccessButton,
+					CustomID: "confirm:" + actionID,
+				},
+				discordgo.Button{
+					Label:    "Cancel",
+					Style:    discordgo.SecondaryButton,
+					CustomID: "cancel:" + actionID,
+				},
+			},
+		},
+	})
+}
+
+// handleConfirmation answers a click on a confirmation prompt's buttons
+func handleConfirmation(database db.Database, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	answer, actionID, ok := strings.Cut(i.MessageComponentData().CustomID, ":")
+	if !ok || (answer != "confirm" && answer != "cancel") {
+		return
+	}
+
+	author := interactionAuthor(i)
+	kind, req, err := core.TakePendingAction(database, author.ID, actionID)
+	if err != nil {
+		err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Embeds: []*discordgo.MessageEmbed{errorEmbed(err.Error())},
+				Flags:  discordgo.MessageFlagsEphemeral,
+			},
+		})
+		if err != nil {
+			log.Printf("can't answer confirmation: %v\n", err)
+		}
+		return
+	}
+
+	// Take the buttons off the prompt, so it can't be answered again
+	title := "Cancelled"
+	if answer == "confirm" {
+		title = "Confirmed"
+	}
+	var description string
+	if len(i.Message.Embeds) > 0 {
+		description = i.Message.Embeds[0].Description
+	}
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{clockEmbed(title, description)},
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		log.Printf("can't answer confirmation: %v\n", err)
+		return
+	}
+	if answer == "cancel" {
+		return
+	}
+
+	run, ok := CONFIRMABLE[kind]
+	if !ok {
+		return
+	}
+	// The interaction was answered above, so replies become followups
+	c := &Context{
+		Session:     s,
+		Author:      author,
+		GuildID:     i.GuildID,
+		ChannelID:   i.ChannelID,
+		interaction: i,
+		ephemeral:   req.Private,
+		replied:     true,
+	}
+	run(database, c, req)
+}
@@ -6,26 +6,27 @@ import (
 	"github.com/bwmarrin/discordgo"
 	"github.com/gagliardetto/solana-go"
 	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 	"github.com/ivypowered/ivy-sprite-bot/db"
 )
 
 const CONTEST_USAGE = "$contest set <address>"
-const CONTEST_DETAILS = "Set the contest game address (Violet only)"
+const CONTEST_DETAILS = "Set the contest game address (global admins only)"
 
-func ContestCommand(database db.Database, args []string, s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.GuildID != "" {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Contest command is DM only")
+func ContestCommand(database db.Database, args []string, c *Context) {
+	if c.GuildID != "" {
+		c.ReactErr()
+		c.Error("Contest command is DM only")
 		return
 	}
-	// Check if user is Violet
-	if m.Author.ID != constants.VIOLET_ID {
-		DmError(s, m.Author.ID, "This command can only be used by Violet.")
+	// The contest is shared by every server
+	if err := core.Authorize(database, newRequest(c, args), db.SCOPE_GLOBAL, db.ROLE_ADMIN); err != nil {
+		c.Error(err.Error())
 		return
 	}
 
 	if len(args) < 2 || args[0] != "set" {
-		DmUsage(s, m.Author.ID, CONTEST_USAGE, CONTEST_DETAILS)
+		c.Usage(CONTEST_USAGE, CONTEST_DETAILS)
 		return
 	}
 
@@ -33,14 +34,14 @@ func ContestCommand(database db.Database, args []string, s *discordgo.Session, m
 	address := strings.TrimSpace(args[1])
 	_, err := solana.PublicKeyFromBase58(address)
 	if err != nil {
-		DmError(s, m.Author.ID, "Invalid Solana address format.")
+		c.Error("Invalid Solana address format.")
 		return
 	}
 
 	// Set the contest address
 	err = database.SetContestAddress(address)
 	if err != nil {
-		DmError(s, m.Author.ID, "Failed to set contest address.")
+		c.Error("Failed to set contest address.")
 		return
 	}
 
@@ -56,5 +57,5 @@ func ContestCommand(database db.Database, args []string, s *discordgo.Session, m
 			},
 		},
 	}
-	s.ChannelMessageSendEmbed(m.Author.ID, embed)
+	c.Reply(embed)
 }
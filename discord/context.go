@@ -0,0 +1,179 @@
+package discord
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Context is one invocation of a command, either a $ message or a slash
+// command. Commands answer through it so they work the same either way.
+type Context struct {
+	Session   *discordgo.Session
+	Author    *discordgo.User
+	GuildID   string
+	ChannelID string
+
+	// Set for $ commands
+	message *discordgo.MessageCreate
+	// Set for slash commands
+	interaction *discordgo.InteractionCreate
+	// Slash command whose replies only the caller can see, so it counts as a DM
+	ephemeral bool
+	// Stands in for a reaction on slash commands, shown if nothing was replied
+	status  string
+	replied bool
+}
+
+func newMessageContext(s *discordgo.Session, m *discordgo.MessageCreate) *Context {
+	return &Context{
+		Session:   s,
+		Author:    m.Author,
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		message:   m,
+	}
+}
+
+// newInteractionContext acknowledges a slash command. Discord drops it
+// unless this happens within 3 seconds, so it's done before any work.
+func newInteractionContext(s *discordgo.Session, i *discordgo.InteractionCreate, ephemeral bool) (*Context, error) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Context{
+		Session:     s,
+		Author:      interactionAuthor(i),
+		GuildID:     i.GuildID,
+		ChannelID:   i.ChannelID,
+		interaction: i,
+		ephemeral:   ephemeral,
+	}, nil
+}
+
+// interactionAuthor returns who triggered an interaction, in a server or a DM
+func interactionAuthor(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// Private reports whether only the caller can see the replies
+func (c *Context) Private() bool {
+	return c.GuildID == "" || c.ephemeral
+}
+
+func (c *Context) react(emoji string) {
+	if c.interaction != nil {
+		c.status = emoji
+		return
+	}
+	c.Session.MessageReactionAdd(c.ChannelID, c.message.ID, emoji)
+}
+
+func (c *Context) ReactOk() {
+	c.react("\U00002705") // green check
+}
+
+func (c *Context) ReactClock() {
+	c.react("\U0001F552") // three o' clock
+}
+
+func (c *Context) ReactErr() {
+	c.react("\U0000274C") // x
+}
+
+// Reply sends an embed only the caller can see: a DM for $ commands, an
+// ephemeral response for slash commands
+func (c *Context) Reply(embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	if c.interaction == nil {
+		return dm(c.Session, c.Author.ID, embed)
+	}
+	if !c.replied {
+		c.replied = true
+		return c.Session.InteractionResponseEdit(c.interaction.Interaction, &discordgo.WebhookEdit{
+			Embeds: &[]*discordgo.MessageEmbed{embed},
+		})
+	}
+	return c.Session.FollowupMessageCreate(c.interaction.Interaction, true, &discordgo.WebhookParams{
+		Embeds: []*discordgo.MessageEmbed{embed},
+		Flags:  discordgo.MessageFlagsEphemeral,
+	})
+}
+
+// Prompt sends an embed with buttons to the caller: a reply in the channel
+// for $ commands, an ephemeral response for slash commands
+func (c *Context) Prompt(embed *discordgo.MessageEmbed, components []discordgo.MessageComponent) (*discordgo.Message, error) {
+	if c.interaction == nil {
+		return c.Session.ChannelMessageSendComplex(c.ChannelID, &discordgo.MessageSend{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+			Reference:  c.message.Reference(),
+		})
+	}
+	if !c.replied {
+		c.replied = true
+		return c.Session.InteractionResponseEdit(c.interaction.Interaction, &discordgo.WebhookEdit{
+			Embeds:     &[]*discordgo.MessageEmbed{embed},
+			Components: &components,
+		})
+	}
+	return c.Session.FollowupMessageCreate(c.interaction.Interaction, true, &discordgo.WebhookParams{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
+		Flags:      discordgo.MessageFlagsEphemeral,
+	})
+}
+
+// Send posts an embed publicly in the channel the command came from
+func (c *Context) Send(embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return c.Session.ChannelMessageSendEmbed(c.ChannelID, embed)
+}
+
+// SendFile posts an embed publicly in the channel the command came from,
+// with a file it can show as attachment://name
+func (c *Context) SendFile(embed *discordgo.MessageEmbed, file *discordgo.File) (*discordgo.Message, error) {
+	return c.Session.ChannelMessageSendComplex(c.ChannelID, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{embed},
+		Files:  []*discordgo.File{file},
+	})
+}
+
+func (c *Context) Usage(commandName string, commandDetails string) (*discordgo.Message, error) {
+	return c.Reply(usageEmbed(commandName, commandDetails))
+}
+
+func (c *Context) Error(message string) (*discordgo.Message, error) {
+	return c.Reply(errorEmbed(message))
+}
+
+func (c *Context) Clock(title string, message string) (*discordgo.Message, error) {
+	return c.Reply(clockEmbed(title, message))
+}
+
+func (c *Context) Success(message string, header string, footer string) (*discordgo.Message, error) {
+	return c.Reply(successEmbed(message, header, footer))
+}
+
+// finish resolves a slash command that only "reacted", so Discord doesn't
+// keep showing it as thinking
+func (c *Context) finish() {
+	if c.interaction == nil || c.replied {
+		return
+	}
+	status := c.status
+	if status == "" {
+		status = "\U00002705"
+	}
+	_, err := c.Session.InteractionResponseEdit(c.interaction.Interaction, &discordgo.WebhookEdit{
+		Content: &status,
+	})
+	if err != nil {
+		log.Printf("can't finish interaction: %v\n", err)
+	}
+}
@@ -2,81 +2,43 @@
 package discord
 
 import (
-	"database/sql"
-	"encoding/hex"
 	"fmt"
-	"net/url"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 	"github.com/ivypowered/ivy-sprite-bot/db"
-	"github.com/ivypowered/ivy-sprite-bot/util"
 )
 
-func DepositCommand(database db.Database, args []string, s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.GuildID != "" {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Withdrawals can only be processed in DMs for security. Please send this command directly to me.")
-		return
-	}
+const DEPOSIT_USAGE = "$deposit amount OR $deposit check id"
+const DEPOSIT_DETAILS = "Create a new deposit or check an existing one\nExample: $deposit 0.75\nExample: $deposit check 3a8fb7"
 
+func DepositCommand(database db.Database, args []string, c *Context) {
 	if len(args) == 0 {
-		DmUsage(s, m.Author.ID, "$deposit amount OR $deposit check id", "Create a new deposit or check an existing one\nExample: $deposit 0.75\nExample: $deposit check 3a8fb7")
+		renderError(c, core.ErrUsage, DEPOSIT_USAGE, DEPOSIT_DETAILS)
 		return
 	}
 
 	// Check if this is a deposit check
 	if args[0] == "check" {
-		if len(args) != 2 {
-			DmUsage(s, m.Author.ID, "$deposit check <deposit_id>", "Check the status of a pending deposit")
-			return
-		}
-		checkDeposit(database, args[1], s, m)
+		checkDeposit(database, args[1:], c)
 		return
 	}
 
 	if args[0] == "list" {
-		listDeposits(database, s, m)
-		return
-	}
-
-	// Parse amount for new deposit
-	amount, err := util.ParseAmount(args[0])
-	if err != nil || amount <= 0 {
-		DmError(s, m.Author.ID, "Please enter a valid positive amount")
-		return
-	}
-
-	// Convert to RAW
-	amountRaw := uint64(amount * constants.IVY_FACTOR)
-
-	database.EnsureUserExists(m.Author.ID)
-
-	// Generate deposit ID
-	depositIDBytes := util.GenerateID(amountRaw)
-	depositID := hex.EncodeToString(depositIDBytes[:])
-
-	// Create deposit record
-	err = database.CreateDeposit(depositID, m.Author.ID, amountRaw)
-	if err != nil {
-		DmError(s, m.Author.ID, "Error creating deposit")
+		listDeposits(database, c)
 		return
 	}
 
-	// Get user info
-	user, err := s.User(m.Author.ID)
+	result, err := core.CreateDeposit(database, newRequest(c, args))
 	if err != nil {
-		DmError(s, m.Author.ID, "Error getting user info")
+		renderError(c, err, DEPOSIT_USAGE, DEPOSIT_DETAILS)
 		return
 	}
 
 	// Create deposit URL
-	depositURL := fmt.Sprintf(
-		"https://sprite.ivypowered.com/deposit?deposit_id=%s&user_id=%s&name=%s",
-		depositID,
-		m.Author.ID,
-		url.QueryEscape(user.Username),
-	)
+	depositURL := core.DepositURL(result.DepositID, c.Author.ID, c.Author.Username)
+	amount := float64(result.AmountRaw) / constants.IVY_FACTOR
 
 	// Send success embed
 	embed := &discordgo.MessageEmbed{
@@ -90,12 +52,12 @@ func DepositCommand(database db.Database, args []string, s *discordgo.Session, m
 			},
 			{
 				Name:   "Deposit ID",
-				Value:  fmt.Sprintf("`%s`", depositID[:8]+"..."),
+				Value:  fmt.Sprintf("`%s`", result.DepositID[:8]+"..."),
 				Inline: true,
 			},
 			{
 				Name:   "Instructions",
-				Value:  "1. Click the link below to complete your deposit\n2. After sending, use `$deposit check " + depositID[:6] + "` to verify",
+				Value:  "1. Click the link below to complete your deposit\n2. Your balance is credited automatically once it arrives\n3. Didn't get a DM? Use `$deposit check " + result.DepositID[:6] + "` to verify",
 				Inline: false,
 			},
 			{
@@ -106,82 +68,35 @@ func DepositCommand(database db.Database, args []string, s *discordgo.Session, m
 		},
 	}
 
-	channel, err := s.UserChannelCreate(m.Author.ID)
-	if err != nil {
-		return
-	}
-	s.ChannelMessageSendEmbed(channel.ID, embed)
+	c.Reply(embed)
 }
 
-func checkDeposit(database db.Database, depositIDPrefix string, s *discordgo.Session, m *discordgo.MessageCreate) {
-	// Find matching deposit - need to access inner DB for this query
-	var fullDepositID string
-	var amountRaw uint64
-	var completed int
-	// If there's duplicate deposits, we select the latest one!
-	// Note: This requires exposing the inner DB or adding a method to Database
-	// For now, let's add a method to Database for this specific query
-	fullDepositID, amountRaw, completed, err := database.FindDepositByPrefix(m.Author.ID, depositIDPrefix)
-
-	if err == sql.ErrNoRows {
-		DmError(s, m.Author.ID, "No deposit found with that ID")
-		return
-	} else if err != nil {
-		DmError(s, m.Author.ID, fmt.Sprintf("Error checking deposit: %v", err))
-		return
-	}
-
-	if completed == 1 {
-		DmSuccess(s, m.Author.ID, "This deposit has already been completed!", "Deposit Already Processed", "")
-		return
-	}
-
-	// Decode deposit ID
-	depositIDBytes, err := hex.DecodeString(fullDepositID)
-	if err != nil || len(depositIDBytes) != 32 {
-		DmError(s, m.Author.ID, "Invalid deposit ID format")
-		return
-	}
-	var depositID32 [32]byte
-	copy(depositID32[:], depositIDBytes)
-
-	// Check if deposit is complete on-chain
-	isComplete, err := util.IsDepositComplete(constants.RPC_CLIENT, constants.SPRITE_VAULT, depositID32)
+func checkDeposit(database db.Database, args []string, c *Context) {
+	result, err := core.CheckDeposit(database, newRequest(c, args))
 	if err != nil {
-		DmError(s, m.Author.ID, fmt.Sprintf("Error checking deposit status: %v", err))
-		return
-	}
-
-	if !isComplete {
-		DmClock(s, m.Author.ID, "Deposit incomplete", "Backend says deposit `"+fullDepositID[:8]+"...` is incomplete, try again!")
+		renderError(c, err, "$deposit check <deposit_id>", "Check the status of a pending deposit")
 		return
 	}
 
-	// Complete the deposit
-	err = database.CompleteDeposit(fullDepositID)
-	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Error completing deposit")
-		return
+	switch result.Status {
+	case core.DEPOSIT_ALREADY_COMPLETE:
+		c.Success("This deposit has already been completed!", "Deposit Already Processed", "")
+	case core.DEPOSIT_PENDING:
+		c.Clock("Deposit incomplete", "Backend says deposit `"+result.DepositID[:8]+"...` is incomplete, try again!")
+	case core.DEPOSIT_COMPLETED:
+		newBalance := float64(result.BalanceRaw) / constants.IVY_FACTOR
+		amount := float64(result.AmountRaw) / constants.IVY_FACTOR
+		c.Success(
+			fmt.Sprintf("Deposited `%.9f IVY`\nNew balance: `%.9f IVY`", amount, newBalance),
+			"Deposit complete",
+			"")
 	}
-
-	// Get new balance
-	newBalanceRaw, _ := database.GetUserBalanceRaw(m.Author.ID)
-	newBalance := float64(newBalanceRaw) / constants.IVY_FACTOR
-	amount := float64(amountRaw) / constants.IVY_FACTOR
-
-	DmSuccess(s, m.Author.ID,
-		fmt.Sprintf("Deposited `%.9f IVY`\nNew balance: `%.9f IVY`", amount, newBalance),
-		"Deposit complete",
-		"")
 }
 
-func listDeposits(database db.Database, s *discordgo.Session, m *discordgo.MessageCreate) {
-	// This requires adding a method to Database for listing deposits
-	deposits, err := database.ListDeposits(m.Author.ID, 10)
+func listDeposits(database db.Database, c *Context) {
+	deposits, err := core.ListDeposits(database, newRequest(c, nil))
 	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Error fetching deposits")
+		renderError(c, err, DEPOSIT_USAGE, DEPOSIT_DETAILS)
 		return
 	}
 
@@ -199,16 +114,11 @@ func listDeposits(database db.Database, s *discordgo.Session, m *discordgo.Messa
 			status := "❌ Pending"
 			if deposit.Completed {
 				status = "✅ Complete"
+			} else if deposit.Expired {
+				status = "⌛ Expired"
 			}
 
-			// Get user info for URL
-			user, _ := s.User(m.Author.ID)
-			depositURL := fmt.Sprintf(
-				"https://sprite.ivypowered.com/deposit?deposit_id=%s&user_id=%s&name=%s",
-				deposit.DepositID,
-				m.Author.ID,
-				url.QueryEscape(user.Username),
-			)
+			depositURL := core.DepositURL(deposit.DepositID, c.Author.ID, c.Author.Username)
 
 			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
 				Name:   fmt.Sprintf("%s %.9f IVY", status, amount),
@@ -218,10 +128,6 @@ func listDeposits(database db.Database, s *discordgo.Session, m *discordgo.Messa
 		}
 	}
 
-	ReactOk(s, m)
-	channel, err := s.UserChannelCreate(m.Author.ID)
-	if err != nil {
-		return
-	}
-	s.ChannelMessageSendEmbed(channel.ID, embed)
+	c.ReactOk()
+	c.Reply(embed)
 }
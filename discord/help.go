@@ -6,7 +6,7 @@ import (
 	"github.com/ivypowered/ivy-sprite-bot/db"
 )
 
-func HelpCommand(database db.Database, args []string, s *discordgo.Session, m *discordgo.MessageCreate) {
+func HelpCommand(database db.Database, args []string, c *Context) {
 	// Create help embed for DM
 	embed := &discordgo.MessageEmbed{
 		Title: "Commands",
@@ -17,9 +17,34 @@ func HelpCommand(database db.Database, args []string, s *discordgo.Session, m *d
 				Value:  "`$balance` - Check your current balance",
 				Inline: false,
 			},
+			{
+				Name:   "Price",
+				Value:  "`$price [1h|24h|7d]` - Show the IVY price and a chart of it\n`$alert above|below <price> [rearm]` - Get a DM when the price crosses a level\n`$alert list` - List your alerts\n`$alert remove <id>` - Remove an alert",
+				Inline: false,
+			},
 			{
 				Name:   "Tip",
-				Value:  "`$tip @user <amount>` - Send coins to another user",
+				Value:  "`$tip @user <amount>` - Send coins to another user\n`$tip @user1 @user2 <amount> each|split` - Tip several users at once",
+				Inline: false,
+			},
+			{
+				Name:   "Vest",
+				Value:  "`$vest @user <amount> over 30d [cliff 7d]` - Send coins that unlock gradually",
+				Inline: false,
+			},
+			{
+				Name:   "Raffle",
+				Value:  "`$raffle start <prize> <duration>` - Raffle a prize to one random entrant\n`$raffle join` - Enter this server's raffle, or react to it with 🎟️\n`$raffle verify <id>` - Check a raffle's draw",
+				Inline: false,
+			},
+			{
+				Name:   "Bounty",
+				Value:  "`$bounty create <amount> <description>` - Offer a reward for a task\n`$bounty award <id> @user` - Pay a bounty\n`$bounty cancel <id>` - Cancel a bounty and get the reward back\n`$bounty list` - List open bounties",
+				Inline: false,
+			},
+			{
+				Name:   "Move",
+				Value:  "`$move <amount> <telegram_id>` - Move coins to your Telegram account",
 				Inline: false,
 			},
 			{
@@ -29,7 +54,12 @@ func HelpCommand(database db.Database, args []string, s *discordgo.Session, m *d
 			},
 			{
 				Name:   "Withdraw",
-				Value:  "`$withdraw <amount>` - Withdraw coins\n`$withdraw list` - List recent withdrawals",
+				Value:  "`$withdraw <amount>` - Withdraw coins\n`$withdraw list` - List recent withdrawals\n`$withdraw cancel <id>` - Cancel an unclaimed withdrawal",
+				Inline: false,
+			},
+			{
+				Name:   "History",
+				Value:  "`$history [page]` - Show your transaction history\n`$history kind=tip,rain from=2025-01-01 to=2025-01-31` - Filter by kind and date",
 				Inline: false,
 			},
 			{
@@ -52,6 +82,16 @@ func HelpCommand(database db.Database, args []string, s *discordgo.Session, m *d
 				Value:  "`$pnl` - Show profit-and-loss for current contest\n`$pnl <address>` - Show profit-and-loss for a specific game\n`$pnl leaderboard` - Show profit-and-loss leaderboard",
 				Inline: false,
 			},
+			{
+				Name:   "Admin",
+				Value:  "`$admin grant @user [role] [global]` - Grant a role\n`$admin revoke @user [global]` - Revoke a role\n`$admin list [global]` - List roles\n`$config rain` - Show or change this server's rain rules",
+				Inline: false,
+			},
+			{
+				Name:   "Slash Commands",
+				Value:  "`/balance`, `/tip`, `/rain`, `/deposit`, `/withdraw`, `/pnl`, `/volume` and `/link` work too. Replies to `/deposit`, `/withdraw` and `/link` are only visible to you, so they can be used in any channel.",
+				Inline: false,
+			},
 			{
 				Name:   "Help",
 				Value:  "`$help` - Show this help message",
@@ -61,9 +101,5 @@ func HelpCommand(database db.Database, args []string, s *discordgo.Session, m *d
 	}
 
 	// Send help via DM
-	channel, err := s.UserChannelCreate(m.Author.ID)
-	if err != nil {
-		return
-	}
-	s.ChannelMessageSendEmbed(channel.ID, embed)
+	c.Reply(embed)
 }
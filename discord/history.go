@@ -0,0 +1,97 @@
+// discord/history.go
+package discord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const HISTORY_USAGE = "$history [page] [kind=tip,rain,...] [from=YYYY-MM-DD] [to=YYYY-MM-DD]"
+const HISTORY_DETAILS = `Show every movement of your balance: tips, rains, moves, deposits and withdrawals.
+
+Examples:
+• $history - Most recent transactions
+• $history 2 - Second page
+• $history kind=tip,rain - Only tips and rains
+• $history from=2025-01-01 to=2025-01-31 - Only January`
+
+func HistoryCommand(database db.Database, args []string, c *Context) {
+	result, err := core.History(database, newRequest(c, args))
+	if err != nil {
+		renderError(c, err, HISTORY_USAGE, HISTORY_DETAILS)
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:  "Transaction History",
+		Color:  constants.IVY_GREEN,
+		Fields: []*discordgo.MessageEmbedField{},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d/%d • %d transactions", result.Page, result.Pages, result.Total),
+		},
+	}
+
+	if len(result.Entries) == 0 {
+		embed.Description = "No transactions found"
+	}
+	for _, e := range result.Entries {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   formatHistoryName(e),
+			Value:  formatHistoryValue(e),
+			Inline: false,
+		})
+	}
+
+	c.ReactOk()
+	c.Reply(embed)
+}
+
+func formatHistoryName(e db.LedgerEntry) string {
+	arrow := "\U00002B07" // incoming
+	if e.AmountRaw < 0 {
+		arrow = "\U00002B06" // outgoing
+	}
+	amount := float64(e.AmountRaw) / constants.IVY_FACTOR
+	return fmt.Sprintf("%s %+.9f IVY • %s", arrow, amount, e.Kind)
+}
+
+func formatHistoryValue(e db.LedgerEntry) string {
+	var parts []string
+	if party := formatCounterparty(e); party != "" {
+		parts = append(parts, party)
+	}
+	if e.PriceUSD > 0 {
+		amount := float64(e.AmountRaw) / constants.IVY_FACTOR
+		if amount < 0 {
+			amount = -amount
+		}
+		parts = append(parts, fmt.Sprintf("\U00002248 $%.2f", amount*e.PriceUSD))
+	}
+	parts = append(parts, fmt.Sprintf("<t:%d:f>", e.Timestamp))
+	return strings.Join(parts, " • ")
+}
+
+func formatCounterparty(e db.LedgerEntry) string {
+	if e.Counterparty == "" {
+		return ""
+	}
+	switch e.Kind {
+	case db.LEDGER_DEPOSIT, db.LEDGER_WITHDRAWAL, db.LEDGER_REFUND, db.LEDGER_CLAWBACK:
+		// Counterparty is the deposit or withdrawal ID
+		return fmt.Sprintf("ID `%s...`", e.Counterparty[:min(8, len(e.Counterparty))])
+	case db.LEDGER_RAFFLE:
+		return fmt.Sprintf("raffle #%s", e.Counterparty)
+	case db.LEDGER_BOUNTY:
+		return fmt.Sprintf("bounty #%s", e.Counterparty)
+	}
+	direction := "from"
+	if e.AmountRaw < 0 {
+		direction = "to"
+	}
+	return fmt.Sprintf("%s %s", direction, formatUser(e.Counterparty))
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/ivypowered/ivy-sprite-bot/db"
 )
 
-func IdCommand(database db.Database, args []string, s *discordgo.Session, m *discordgo.MessageCreate) {
+func IdCommand(database db.Database, args []string, c *Context) {
 	// Create embed with ID information
 	embed := &discordgo.MessageEmbed{
 		Title: "Your ID",
@@ -17,24 +17,22 @@ func IdCommand(database db.Database, args []string, s *discordgo.Session, m *dis
 		Fields: []*discordgo.MessageEmbedField{
 			{
 				Name:   "Discord ID",
-				Value:  fmt.Sprintf("`%s`", m.Author.ID),
+				Value:  fmt.Sprintf("`%s`", c.Author.ID),
 				Inline: false,
 			},
 			{
 				Name:   "How to receive from Telegram",
-				Value:  fmt.Sprintf("In Telegram, users can type:\n`/move [amount] %s`\n\nTo transfer IVY from Telegram to your Discord account!", m.Author.ID),
+				Value:  fmt.Sprintf("In Telegram, users can type:\n`/move [amount] %s`\n\nTo transfer IVY from Telegram to your Discord account!", c.Author.ID),
 				Inline: false,
 			},
 		},
 	}
 
 	// Send via DM
-	channel, err := s.UserChannelCreate(m.Author.ID)
-	if err != nil {
-		ReactErr(s, m)
+	if _, err := c.Reply(embed); err != nil {
+		c.ReactErr()
 		return
 	}
 
-	ReactOk(s, m)
-	s.ChannelMessageSendEmbed(channel.ID, embed)
+	c.ReactOk()
 }
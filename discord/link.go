@@ -27,18 +27,18 @@ Example flow:
 2. Visit the URL and sign with your wallet
 3. Copy the response and run $link complete <response>`
 
-func LinkCommand(database db.Database, args []string, s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.GuildID != "" {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Links can only be processed in DMs for security. Please send this command directly to me.")
+func LinkCommand(database db.Database, args []string, c *Context) {
+	if !c.Private() {
+		c.ReactErr()
+		c.Error("Links can only be processed in DMs for security. Please send this command directly to me.")
 		return
 	}
 
 	// Ensure user exists
-	database.EnsureUserExists(m.Author.ID)
+	database.EnsureUserExists(c.Author.ID)
 
 	if len(args) == 0 {
-		DmUsage(s, m.Author.ID, LINK_USAGE, LINK_DETAILS)
+		c.Usage(LINK_USAGE, LINK_DETAILS)
 		return
 	}
 
@@ -46,40 +46,40 @@ func LinkCommand(database db.Database, args []string, s *discordgo.Session, m *d
 	switch args[0] {
 	case "complete":
 		if len(args) != 2 {
-			DmUsage(s, m.Author.ID, "$link complete <response>", "Complete wallet linking with the response from the website")
+			c.Usage("$link complete <response>", "Complete wallet linking with the response from the website")
 			return
 		}
-		verifyAndLink(database, args[1], s, m)
+		verifyAndLink(database, args[1], c)
 		return
 
 	case "list":
-		listWallets(database, s, m)
+		listWallets(database, c)
 		return
 
 	case "remove":
 		if len(args) != 2 {
-			DmUsage(s, m.Author.ID, "$link remove <wallet>", "Remove a linked wallet")
+			c.Usage("$link remove <wallet>", "Remove a linked wallet")
 			return
 		}
-		removeWallet(database, args[1], s, m)
+		removeWallet(database, args[1], c)
 		return
 
 	default:
 		// Assume it's a wallet address
-		generateLinkURL(args[0], s, m)
+		generateLinkURL(args[0], c)
 		return
 	}
 }
 
-func generateLinkURL(walletStr string, s *discordgo.Session, m *discordgo.MessageCreate) {
+func generateLinkURL(walletStr string, c *Context) {
 	// Validate wallet address
 	wallet, err := solana.PublicKeyFromBase58(walletStr)
 	if err != nil {
-		DmUsage(s, m.Author.ID, LINK_USAGE, LINK_DETAILS)
+		c.Usage(LINK_USAGE, LINK_DETAILS)
 		return
 	}
 
-	linkURL := util.LinkGenerateURL(wallet, m.Author.ID)
+	linkURL := util.LinkGenerateURL(wallet, c.Author.ID)
 
 	embed := &discordgo.MessageEmbed{
 		Title: "🔗 Link Your Wallet",
@@ -116,21 +116,17 @@ func generateLinkURL(walletStr string, s *discordgo.Session, m *discordgo.Messag
 		},
 	}
 
-	channel, err := s.UserChannelCreate(m.Author.ID)
-	if err != nil {
-		return
-	}
-	s.ChannelMessageSendEmbed(channel.ID, embed)
+	c.Reply(embed)
 }
 
-func verifyAndLink(database db.Database, responseBase64 string, s *discordgo.Session, m *discordgo.MessageCreate) {
+func verifyAndLink(database db.Database, responseBase64 string, c *Context) {
 	// Remove any whitespace
 	responseBase64 = strings.TrimSpace(responseBase64)
 
 	// Decode hex response
 	responseBytes, err := base64.StdEncoding.DecodeString(responseBase64)
 	if err != nil || len(responseBytes) != 104 {
-		DmError(s, m.Author.ID, "Invalid response format. Please copy the entire response from the website.")
+		c.Error("Invalid response format. Please copy the entire response from the website.")
 		return
 	}
 
@@ -139,30 +135,30 @@ func verifyAndLink(database db.Database, responseBase64 string, s *discordgo.Ses
 	copy(response[:], responseBytes)
 
 	// Verify the signature
-	wallet, err := util.LinkVerify(response, m.Author.ID)
+	wallet, err := util.LinkVerify(response, c.Author.ID)
 	if err != nil {
-		DmError(s, m.Author.ID, fmt.Sprintf("Failed to verify signature: %v", err))
+		c.Error(fmt.Sprintf("Failed to verify signature: %v", err))
 		return
 	}
 
 	// Link the wallet
 	walletStr := solana.PublicKey(wallet).String()
-	err = database.LinkWallet(walletStr, m.Author.ID)
+	err = database.LinkWallet(walletStr, c.Author.ID)
 	if err != nil {
-		DmError(s, m.Author.ID, fmt.Sprintf("Failed to link wallet: %v", err))
+		c.Error(fmt.Sprintf("Failed to link wallet: %v", err))
 		return
 	}
 
-	DmSuccess(s, m.Author.ID,
+	c.Success(
 		fmt.Sprintf("Successfully linked wallet:\n`%s`", walletStr),
 		"Wallet Linked",
 		"")
 }
 
-func listWallets(database db.Database, s *discordgo.Session, m *discordgo.MessageCreate) {
-	wallets, err := database.GetUserWallets(m.Author.ID)
+func listWallets(database db.Database, c *Context) {
+	wallets, err := database.GetUserWallets(c.Author.ID)
 	if err != nil {
-		DmError(s, m.Author.ID, "Error fetching wallets")
+		c.Error("Error fetching wallets")
 		return
 	}
 
@@ -184,28 +180,24 @@ func listWallets(database db.Database, s *discordgo.Session, m *discordgo.Messag
 		}
 	}
 
-	channel, err := s.UserChannelCreate(m.Author.ID)
-	if err != nil {
-		return
-	}
-	s.ChannelMessageSendEmbed(channel.ID, embed)
+	c.Reply(embed)
 }
 
-func removeWallet(database db.Database, wallet string, s *discordgo.Session, m *discordgo.MessageCreate) {
+func removeWallet(database db.Database, wallet string, c *Context) {
 	// Validate wallet address
 	_, err := solana.PublicKeyFromBase58(wallet)
 	if err != nil {
-		DmError(s, m.Author.ID, "Invalid wallet address format")
+		c.Error("Invalid wallet address format")
 		return
 	}
 
-	err = database.UnlinkWallet(wallet, m.Author.ID)
+	err = database.UnlinkWallet(wallet, c.Author.ID)
 	if err != nil {
-		DmError(s, m.Author.ID, fmt.Sprintf("Failed to remove wallet: %v", err))
+		c.Error(fmt.Sprintf("Failed to remove wallet: %v", err))
 		return
 	}
 
-	DmSuccess(s, m.Author.ID,
+	c.Success(
 		fmt.Sprintf("Successfully removed wallet:\n`%s`", wallet),
 		"Wallet Removed",
 		"")
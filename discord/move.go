@@ -0,0 +1,23 @@
+// discord/move.go
+package discord
+
+import (
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const MOVE_USAGE = "$move <amount> <telegram_id>"
+const MOVE_DETAILS = `Transfer funds from Discord to a Telegram account. Must be used in DMs.
+Example: $move 10.5 tg:123456789
+
+The Telegram account must already exist in Ivy Sprite. Get your Telegram ID by typing /id in Telegram.`
+
+func MoveCommand(database db.Database, args []string, c *Context) {
+	result, err := core.Move(database, newRequest(c, args))
+	if err != nil {
+		renderError(c, err, MOVE_USAGE, MOVE_DETAILS)
+		return
+	}
+
+	renderTransfer(c, result)
+}
@@ -47,64 +47,64 @@ type PnlLeaderboardResponse struct {
 	Data   []PnlEntry `json:"data"`
 }
 
-func PnlCommand(database db.Database, args []string, s *discordgo.Session, m *discordgo.MessageCreate) {
+func PnlCommand(database db.Database, args []string, c *Context) {
 	// Ensure user exists
-	database.EnsureUserExists(m.Author.ID)
+	database.EnsureUserExists(c.Author.ID)
 
 	// Handle subcommands
 	if len(args) == 0 {
 		// Show PnL for current contest
-		showContestPnl(database, s, m)
+		showContestPnl(database, c)
 		return
 	}
 
 	if args[0] == "leaderboard" {
 		// Check for "realized" modifier
 		realized := len(args) > 1 && args[1] == "realized"
-		showPnlLeaderboard(database, realized, s, m)
+		showPnlLeaderboard(database, realized, c)
 		return
 	}
 
 	// Otherwise, treat as game address
-	showGamePnl(database, args[0], s, m)
+	showGamePnl(database, args[0], c)
 }
 
-func showContestPnl(database db.Database, s *discordgo.Session, m *discordgo.MessageCreate) {
+func showContestPnl(database db.Database, c *Context) {
 	// Get contest address
 	contestAddress, err := database.GetContestAddress()
 	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Failed to retrieve contest address.")
+		c.ReactErr()
+		c.Error("Failed to retrieve contest address.")
 		return
 	}
 
 	if contestAddress == "" {
-		DmError(s, m.Author.ID, "No contest is currently active. Ask Violet to set one!")
+		c.Error("No contest is currently active. Ask Violet to set one!")
 		return
 	}
 
-	showGamePnl(database, contestAddress, s, m)
+	showGamePnl(database, contestAddress, c)
 }
 
-func showGamePnl(database db.Database, gameAddress string, s *discordgo.Session, m *discordgo.MessageCreate) {
+func showGamePnl(database db.Database, gameAddress string, c *Context) {
 	// Validate game address
 	_, err := solana.PublicKeyFromBase58(gameAddress)
 	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Invalid game address format")
+		c.ReactErr()
+		c.Error("Invalid game address format")
 		return
 	}
 
 	// Get user's linked wallets
-	wallets, err := database.GetUserWallets(m.Author.ID)
+	wallets, err := database.GetUserWallets(c.Author.ID)
 	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Error fetching your linked wallets")
+		c.ReactErr()
+		c.Error("Error fetching your linked wallets")
 		return
 	}
 
 	if len(wallets) == 0 {
-		DmError(s, m.Author.ID, "You have no linked wallets. Use `$link <wallet>` to link a wallet.")
+		c.Error("You have no linked wallets. Use `$link <wallet>` to link a wallet.")
 		return
 	}
 
@@ -142,7 +142,7 @@ func showGamePnl(database db.Database, gameAddress string, s *discordgo.Session,
 	}
 
 	if !hasData {
-		DmError(s, m.Author.ID, "No trading data found for this game.")
+		c.Error("No trading data found for this game.")
 		return
 	}
 
@@ -190,21 +190,21 @@ func showGamePnl(database db.Database, gameAddress string, s *discordgo.Session,
 		},
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
-	ReactOk(s, m)
+	c.Send(embed)
+	c.ReactOk()
 }
 
-func showPnlLeaderboard(database db.Database, realized bool, s *discordgo.Session, m *discordgo.MessageCreate) {
+func showPnlLeaderboard(database db.Database, realized bool, c *Context) {
 	// Get contest address
 	contestAddress, err := database.GetContestAddress()
 	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Failed to retrieve contest address.")
+		c.ReactErr()
+		c.Error("Failed to retrieve contest address.")
 		return
 	}
 
 	if contestAddress == "" {
-		DmError(s, m.Author.ID, "No contest is currently active. Ask Violet to set one!")
+		c.Error("No contest is currently active. Ask Violet to set one!")
 		return
 	}
 
@@ -212,24 +212,24 @@ func showPnlLeaderboard(database db.Database, realized bool, s *discordgo.Sessio
 	url := fmt.Sprintf("%s/games/%s/pnl_board?count=25&skip=0&realized=%t", constants.AGGREGATOR_URL, contestAddress, realized)
 	resp, err := http.Get(url)
 	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Failed to fetch leaderboard data.")
+		c.ReactErr()
+		c.Error("Failed to fetch leaderboard data.")
 		return
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Failed to read leaderboard data.")
+		c.ReactErr()
+		c.Error("Failed to read leaderboard data.")
 		return
 	}
 
 	var pnlResponse PnlLeaderboardResponse
 	err = json.Unmarshal(body, &pnlResponse)
 	if err != nil || pnlResponse.Status != "ok" {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Failed to parse leaderboard data.")
+		c.ReactErr()
+		c.Error("Failed to parse leaderboard data.")
 		return
 	}
 
@@ -371,8 +371,8 @@ func showPnlLeaderboard(database db.Database, realized bool, s *discordgo.Sessio
 		}
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
-	ReactOk(s, m)
+	c.Send(embed)
+	c.ReactOk()
 }
 
 // Helper function for clean display names
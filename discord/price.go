@@ -0,0 +1,57 @@
+package discord
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const PRICE_USAGE_NAME string = "$price [1h|24h|7d]"
+const PRICE_USAGE_DETAILS string = "Show the IVY price, how much it moved and a chart of it over the last hour, day (default) or week."
+
+func PriceCommand(database db.Database, args []string, c *Context) {
+	result, err := core.Price(newRequest(c, args))
+	if err != nil {
+		renderError(c, err, PRICE_USAGE_NAME, PRICE_USAGE_DETAILS)
+		return
+	}
+
+	description := fmt.Sprintf("<:ivy:1398745198472986654> **$%.6f** per IVY", result.Latest.PriceUSD)
+	if result.HasChange {
+		arrow := "\U0001F53C" // up button
+		if result.ChangePercent < 0 {
+			arrow = "\U0001F53D" // down button
+		}
+		description += fmt.Sprintf("\n%s **%+.2f%%** over %s", arrow, result.ChangePercent, result.Window)
+	}
+	if result.Stale {
+		description += fmt.Sprintf("\n\n\U000026A0\U0000FE0F This price is out of date, it was last updated <t:%d:R>", result.Latest.Timestamp)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "IVY Price",
+		Description: description,
+		Color:       constants.IVY_GREEN,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Median of %d price sources", result.Latest.Sources),
+		},
+		Timestamp: time.Unix(result.Latest.Timestamp, 0).UTC().Format(time.RFC3339),
+	}
+	if result.Chart == nil {
+		embed.Footer.Text += " • Not enough history for a chart yet"
+		c.Send(embed)
+		return
+	}
+
+	embed.Image = &discordgo.MessageEmbedImage{URL: "attachment://ivy-price.png"}
+	c.SendFile(embed, &discordgo.File{
+		Name:        "ivy-price.png",
+		ContentType: "image/png",
+		Reader:      bytes.NewReader(result.Chart),
+	})
+}
@@ -0,0 +1,164 @@
+package discord
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const RAFFLE_USAGE_NAME string = "$raffle start <prize> <duration> OR $raffle join OR $raffle verify <id>"
+const RAFFLE_USAGE_DETAILS string = `Give away a prize to one random entrant.
+
+• $raffle start 10 24h - Raffle 10 IVY, drawn in 24 hours
+• $raffle join - Enter this server's raffle, or react to it with 🎟️
+• $raffle verify 3 - Show everything needed to check raffle #3's draw
+
+The prize is taken from your balance when the raffle starts. A hash of the secret seed is published at the start and the seed itself at the draw, so anyone can check the winner wasn't picked by hand.`
+
+// Reaction that enters a raffle
+const RAFFLE_EMOJI = "🎟️"
+
+// Most entrants listed by $raffle verify, to stay within Discord's 1024
+// characters per embed field
+const RAFFLE_VERIFY_MAX_ENTRANTS = 45
+
+func RaffleCommand(database db.Database, args []string, c *Context) {
+	if len(args) < 1 {
+		renderError(c, core.ErrUsage, RAFFLE_USAGE_NAME, RAFFLE_USAGE_DETAILS)
+		return
+	}
+
+	switch args[0] {
+	case "start":
+		runRaffleStart(database, c, newRequest(c, args[1:]))
+	case "join":
+		raffle, err := core.JoinRaffle(database, newRequest(c, args[1:]))
+		if err != nil {
+			renderError(c, err, RAFFLE_USAGE_NAME, RAFFLE_USAGE_DETAILS)
+			return
+		}
+		c.ReactOk()
+		c.Success(
+			fmt.Sprintf("You're in raffle #%d for **%.9f** IVY. It's drawn <t:%d:R>, good luck!",
+				raffle.RaffleID, float64(raffle.PrizeRaw)/constants.IVY_FACTOR, raffle.EndsAt),
+			"Raffle Joined",
+			"")
+	case "verify":
+		draw, err := core.VerifyRaffle(database, newRequest(c, args[1:]))
+		if err != nil {
+			renderError(c, err, RAFFLE_USAGE_NAME, RAFFLE_USAGE_DETAILS)
+			return
+		}
+		c.ReactOk()
+		c.Reply(raffleVerifyEmbed(draw))
+	default:
+		renderError(c, core.ErrUsage, RAFFLE_USAGE_NAME, RAFFLE_USAGE_DETAILS)
+	}
+}
+
+func runRaffleStart(database db.Database, c *Context, req core.Request) {
+	raffle, err := core.StartRaffle(database, req, c.ChannelID)
+	if err != nil {
+		renderActionError(database, c, err, RAFFLE_USAGE_NAME, RAFFLE_USAGE_DETAILS)
+		return
+	}
+	c.ReactOk()
+
+	announcement, err := c.Send(&discordgo.MessageEmbed{
+		Title: fmt.Sprintf("🎟️ Raffle #%d", raffle.RaffleID),
+		Description: fmt.Sprintf("<@%s> is raffling **%.9f** IVY!\n\nReact with %s or run `$raffle join` to enter. The winner is drawn <t:%d:R>.",
+			raffle.OwnerID, float64(raffle.PrizeRaw)/constants.IVY_FACTOR, RAFFLE_EMOJI, raffle.EndsAt),
+		Color: constants.IVY_GREEN,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Seed hash", Value: fmt.Sprintf("`%s`", raffle.SeedHash)},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("The seed is revealed at the draw. Check it with $raffle verify %d", raffle.RaffleID),
+		},
+	})
+	if err != nil {
+		// Entering with $raffle join still works
+		return
+	}
+	if err := database.SetRaffleMessage(raffle.RaffleID, announcement.ID); err != nil {
+		return
+	}
+	c.Session.MessageReactionAdd(c.ChannelID, announcement.ID, RAFFLE_EMOJI)
+}
+
+// handleRaffleReaction enters users who react to a raffle announcement
+func handleRaffleReaction(database db.Database, s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	if r.UserID == s.State.User.ID || (r.Member != nil && r.Member.User != nil && r.Member.User.Bot) {
+		return
+	}
+	// Clients may leave off the variation selector
+	if strings.TrimSuffix(r.Emoji.Name, "\uFE0F") != strings.TrimSuffix(RAFFLE_EMOJI, "\uFE0F") {
+		return
+	}
+
+	raffle, err := core.JoinRaffleByMessage(database, r.UserID, r.MessageID)
+	if err == sql.ErrNoRows {
+		// Not a raffle announcement
+		return
+	}
+	if err != nil {
+		DmError(s, r.UserID, err.Error())
+		return
+	}
+	DmSuccess(s, r.UserID,
+		fmt.Sprintf("You're in raffle #%d for **%.9f** IVY. It's drawn <t:%d:R>, good luck!",
+			raffle.RaffleID, float64(raffle.PrizeRaw)/constants.IVY_FACTOR, raffle.EndsAt),
+		"Raffle Joined",
+		"")
+}
+
+// raffleVerifyEmbed shows a raffle's commitment, and once drawn, its seed
+// and how the winner follows from it
+func raffleVerifyEmbed(draw core.RaffleDraw) *discordgo.MessageEmbed {
+	raffle := draw.Raffle
+	entrants := draw.Entrants
+	more := ""
+	if len(entrants) > RAFFLE_VERIFY_MAX_ENTRANTS {
+		more = fmt.Sprintf("\n...and %d more", len(entrants)-RAFFLE_VERIFY_MAX_ENTRANTS)
+		entrants = entrants[:RAFFLE_VERIFY_MAX_ENTRANTS]
+	}
+	entrantList := "None"
+	if len(entrants) > 0 {
+		entrantList = fmt.Sprintf("```%s```%s", strings.Join(entrants, ","), more)
+	}
+
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "Status", Value: string(raffle.Status), Inline: true},
+		{Name: "Prize", Value: fmt.Sprintf("%.9f IVY", float64(raffle.PrizeRaw)/constants.IVY_FACTOR), Inline: true},
+		{Name: "Ends", Value: fmt.Sprintf("<t:%d:f>", raffle.EndsAt), Inline: true},
+		{Name: "Seed hash", Value: fmt.Sprintf("`%s`", raffle.SeedHash)},
+	}
+	description := "The seed is revealed once the raffle is drawn."
+	if raffle.Seed != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Seed", Value: fmt.Sprintf("`%s`", raffle.Seed)})
+		description = "SHA-256 of the seed must equal the seed hash. The winner is entrant number " +
+			"SHA-256(seed + \":\" + entrants joined by \",\") mod entrant count, counting from 0."
+	}
+	if raffle.WinnerID != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  "Winner",
+			Value: fmt.Sprintf("%s (entrant %d)", formatUser(raffle.WinnerID), core.RaffleWinnerIndex(raffle.Seed, draw.Entrants)),
+		})
+	}
+	fields = append(fields, &discordgo.MessageEmbedField{
+		Name:  fmt.Sprintf("Entrants (%d, sorted)", len(draw.Entrants)),
+		Value: entrantList,
+	})
+
+	return &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("🎟️ Raffle #%d", raffle.RaffleID),
+		Description: description,
+		Color:       constants.IVY_GREEN,
+		Fields:      fields,
+	}
+}
@@ -3,17 +3,17 @@ package discord
 
 import (
 	"fmt"
-	"math"
-	"strconv"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 	"github.com/ivypowered/ivy-sprite-bot/db"
-	"github.com/ivypowered/ivy-sprite-bot/util"
 )
 
-const RAIN_USAGE_NAME string = "$rain amount OR $rain channels [add|remove|list|clear]"
+const RAIN_USAGE_NAME string = "$rain amount [max=N] [mode=even|weighted] [role=@role [active]] OR $rain channels [add|remove|list|clear]"
 const RAIN_USAGE_DETAILS string = `Rain coins on active users in whitelisted channels.
 
 Channel Management:
@@ -21,160 +21,166 @@ Channel Management:
 • $rain channels remove #channel1 - Remove channels from whitelist
 • $rain channels list - Show whitelisted channels
 • $rain channels clear - Clear all whitelisted channels
+• $config rain - Show or change this server's rain rules
 
 Rain Usage:
 • $rain amount - Rain on active users (requires whitelisted channels)
-• $rain amount max=[amount] - Rain on up to [amount] active users`
+• $rain amount max=[amount] - Rain on up to [amount] active users
+• $rain amount mode=weighted - Give more to users with a higher activity score
+• $rain amount role=@role - Rain on everyone with a role, active or not
+• $rain amount role=@role active - Rain on active users with a role
 
-func RainCommand(database db.Database, args []string, s *discordgo.Session, m *discordgo.MessageCreate) {
+Scheduled Rains:
+• $rain schedule amount every 24h [max=N] - Repeat a rain on this server's active users
+• $rain schedule list - Show your scheduled rains
+• $rain schedule cancel id - Stop a scheduled rain
+• $rain schedule resume id - Restart a rain paused for lack of funds`
+
+// Most members Discord returns per request
+const ROLE_MEMBERS_PAGE_SIZE = 1000
+
+func RainCommand(database db.Database, args []string, c *Context) {
 	// Handle check command
 	if len(args) == 2 && args[0] == "check" {
 		server := args[1]
-		activeUsers, err := database.GetActiveUsersForRain(server, constants.RAIN_ACTIVITY_REQUIREMENT)
+		result, err := core.RainCheck(database, server, c.Author.ID)
 		if err != nil {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, err.Error())
+			c.ReactErr()
+			c.Error(err.Error())
 			return
 		}
-		ReactOk(s, m)
-		DmSuccess(s, m.Author.ID, fmt.Sprintf("Active users for %s: **%d**", server, len(activeUsers)), "Rain information", "")
+		c.ReactOk()
+		c.Success(
+			fmt.Sprintf("Active users for %s: **%d**\nRain needs %d+ active users with a score of %d+",
+				server, result.EligibleCount, result.Settings.MinActiveCount, result.Settings.ActivityRequirement),
+			"Rain information", "")
 		return
 	}
 
-	// Rain only works in guild channels
-	if m.GuildID == "" {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Rain command can only be used in server channels, not DMs")
+	if len(args) < 1 {
+		renderError(c, core.ErrUsage, RAIN_USAGE_NAME, RAIN_USAGE_DETAILS)
 		return
 	}
 
-	if len(args) < 1 {
-		ReactErr(s, m)
-		DmUsage(s, m.Author.ID, RAIN_USAGE_NAME, RAIN_USAGE_DETAILS)
+	// Handle scheduled rains
+	if args[0] == "schedule" {
+		handleRainSchedule(database, args[1:], c)
 		return
 	}
 
 	// Handle channel management subcommands
-	if args[0] == "channels" {
-		handleRainChannels(database, args[1:], s, m)
+	if args[0] == "channels" && c.GuildID != "" {
+		handleRainChannels(database, args[1:], c)
 		return
 	}
 
 	// Check if any channels are whitelisted
-	rainChannels, err := database.GetRainChannels(m.GuildID)
-	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Error checking rain channels")
-		return
-	}
-
-	if len(rainChannels) == 0 {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "No channels are whitelisted for rain. Use `$rain channels add #channel` to add channels.")
-		return
-	}
+	if c.GuildID != "" {
+		rainChannels, err := database.GetRainChannels(c.GuildID)
+		if err != nil {
+			c.ReactErr()
+			c.Error("Error checking rain channels")
+			return
+		}
 
-	// Parse max users parameter
-	var maxUsers int = math.MaxInt
-	if len(args) >= 2 {
-		maxp := args[1]
-		if strings.HasPrefix(maxp, "max=") {
-			var err error
-			maxUsers, err = strconv.Atoi(maxp[4:])
-			if err != nil || maxUsers < 0 {
-				ReactErr(s, m)
-				DmUsage(s, m.Author.ID, RAIN_USAGE_NAME, RAIN_USAGE_DETAILS)
-				return
-			}
+		if len(rainChannels) == 0 {
+			c.ReactErr()
+			c.Error("No channels are whitelisted for rain. Use `$rain channels add #channel` to add channels.")
+			return
 		}
 	}
 
-	// Parse amount
-	amount, err := util.ParseAmount(args[0])
-	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Please enter a valid positive amount")
-		return
+	// Rain on the members of a role instead of the active users
+	var roleID string
+	var rainArgs []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "role=") {
+			roleID = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(arg, "role="), "<@&"), ">")
+		} else {
+			rainArgs = append(rainArgs, arg)
+		}
 	}
-
-	// Enforce minimum
-	price := constants.PRICE.Get(constants.RPC_CLIENT)
-	rainMin := (math.Max(0, (constants.RAIN_MIN_AMOUNT_USD-0.01)) / price) // $0.01 threshold
-	if amount < rainMin {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, fmt.Sprintf(
-			"Rain amount must be at least $%.2f (%.9f IVY)", constants.RAIN_MIN_AMOUNT_USD, rainMin,
-		))
-		return
+	req := newRequest(c, rainArgs)
+	if roleID != "" {
+		if c.GuildID == "" {
+			renderError(c, core.ErrGroupOnly, RAIN_USAGE_NAME, RAIN_USAGE_DETAILS)
+			return
+		}
+		c.ReactClock()
+		members, err := roleMembers(c.Session, c.GuildID, roleID)
+		if err != nil {
+			c.ReactErr()
+			c.Error("Error fetching the members of that role")
+			return
+		}
+		if len(members) == 0 {
+			c.ReactErr()
+			c.Error("Nobody has that role")
+			return
+		}
+		req.Mentions = members
 	}
 
-	// Convert to RAW
-	amountRaw := uint64(amount * constants.IVY_FACTOR)
-
-	// Ensure sender exists in database
-	database.EnsureUserExists(m.Author.ID)
-
-	// Check sender's balance
-	senderBalanceRaw, err := database.GetUserBalanceRaw(m.Author.ID)
-	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Error checking balance")
-		return
-	}
+	runRain(database, c, req)
+}
 
-	if senderBalanceRaw < amountRaw {
-		senderBalance := float64(senderBalanceRaw) / constants.IVY_FACTOR
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, fmt.Sprintf("Insufficient balance. Your balance: **%.9f** IVY", senderBalance))
-		return
+// roleMembers lists the users with a role, paging through the whole guild.
+// The @everyone role has the guild's ID.
+func roleMembers(s *discordgo.Session, guildID, roleID string) ([]string, error) {
+	var userIDs []string
+	after := ""
+	for {
+		members, err := s.GuildMembers(guildID, after, ROLE_MEMBERS_PAGE_SIZE)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if member.User.Bot {
+				continue
+			}
+			if roleID == guildID || slices.Contains(member.Roles, roleID) {
+				userIDs = append(userIDs, member.User.ID)
+			}
+		}
+		if len(members) < ROLE_MEMBERS_PAGE_SIZE {
+			return userIDs, nil
+		}
+		after = members[len(members)-1].User.ID
 	}
+}
 
-	// Get active users for this server
-	activeUsers, err := database.GetActiveUsersForRain(m.GuildID, constants.RAIN_ACTIVITY_REQUIREMENT)
+func runRain(database db.Database, c *Context, req core.Request) {
+	result, err := core.Rain(database, req)
 	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Error finding active users")
+		renderActionError(database, c, err, RAIN_USAGE_NAME, RAIN_USAGE_DETAILS)
 		return
 	}
 
-	// Remove sender from active users list (can't rain on yourself)
-	var eligibleUsers []string
-	for _, userID := range activeUsers {
-		if userID != m.Author.ID {
-			eligibleUsers = append(eligibleUsers, userID)
-		}
-	}
+	// Get new balances for notifications
+	amount := float64(result.AmountRaw) / constants.IVY_FACTOR
+	newBalance := float64(result.SenderBalanceRaw) / constants.IVY_FACTOR
+	amountPerUser := float64(result.AmountPerUserRaw) / constants.IVY_FACTOR
+	amountMax := float64(slices.Max(result.RecipientAmountsRaw)) / constants.IVY_FACTOR
 
-	if len(eligibleUsers) == 0 {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, fmt.Sprintf("No active users found in whitelisted channels. Users need an activity score of %d+ in whitelisted channels to receive rain.", constants.RAIN_ACTIVITY_REQUIREMENT))
-		return
-	}
+	c.ReactOk()
 
-	// Bound by maximum users
-	if len(eligibleUsers) > maxUsers {
-		eligibleUsers = eligibleUsers[:maxUsers]
+	// Role rain reaches users whether or not they're active
+	users := "active users"
+	if req.Mentions != nil {
+		users = "users"
 	}
 
-	// Process the rain transaction
-	amountPerUserRaw, err := database.ProcessRain(m.Author.ID, eligibleUsers, amountRaw, senderBalanceRaw)
-	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, fmt.Sprintf("Error processing rain: %v", err))
-		return
+	share := fmt.Sprintf("Each user received **%.9f** IVY", amountPerUser)
+	perUser := fmt.Sprintf("Amount per user: **%.9f** IVY", amountPerUser)
+	if result.Weighted {
+		share = fmt.Sprintf("Weighted by activity, users received **%.9f** to **%.9f** IVY", amountPerUser, amountMax)
+		perUser = fmt.Sprintf("Amounts per user: **%.9f** to **%.9f** IVY", amountPerUser, amountMax)
 	}
 
-	// Get new balances for notifications
-	newBalanceRaw, _ := database.GetUserBalanceRaw(m.Author.ID)
-	newBalance := float64(newBalanceRaw) / constants.IVY_FACTOR
-	amountPerUser := float64(amountPerUserRaw) / constants.IVY_FACTOR
-
-	ReactOk(s, m)
-
 	// Send confirmation in channel
-	_, _ = s.ChannelMessageSendEmbed(m.ChannelID, &discordgo.MessageEmbed{
+	c.Send(&discordgo.MessageEmbed{
 		Title:       "💧 Rain Complete!",
-		Description: fmt.Sprintf("<@%s> rained **%.9f** IVY on **%d** active users!\n\nEach user received **%.9f** IVY", m.Author.ID, amount, len(eligibleUsers), amountPerUser),
+		Description: fmt.Sprintf("<@%s> rained **%.9f** IVY on **%d** %s!\n\n%s", c.Author.ID, amount, len(result.Recipients), users, share),
 		Color:       0x00ff00,
 		Footer: &discordgo.MessageEmbedFooter{
 			Text: "Stay active to receive future rains!",
@@ -182,41 +188,40 @@ func RainCommand(database db.Database, args []string, s *discordgo.Session, m *d
 	})
 
 	// DM sender confirmation
-	DmSuccess(s, m.Author.ID,
-		fmt.Sprintf("Successfully rained **%.9f** IVY on **%d** active users\n\nAmount per user: **%.9f** IVY\nYour new balance: **%.9f** IVY",
-			amount, len(eligibleUsers), amountPerUser, newBalance),
+	c.Success(
+		fmt.Sprintf("Successfully rained **%.9f** IVY on **%d** %s\n\n%s\nYour new balance: **%.9f** IVY",
+			amount, len(result.Recipients), users, perUser, newBalance),
 		"Rain Sent",
 		"")
 
 	// DM each recipient
-	for _, recipientID := range eligibleUsers {
-		recipientBalanceRaw, _ := database.GetUserBalanceRaw(recipientID)
-		recipientBalance := float64(recipientBalanceRaw) / constants.IVY_FACTOR
-		DmSuccess(s, recipientID,
+	for i, recipientID := range result.Recipients {
+		recipientAmount := float64(result.RecipientAmountsRaw[i]) / constants.IVY_FACTOR
+		recipientBalance := float64(result.RecipientBalancesRaw[i]) / constants.IVY_FACTOR
+		DmSuccess(c.Session, recipientID,
 			fmt.Sprintf("You received **%.9f** IVY from <@%s>'s rain!\n\nYour new balance: **%.9f** IVY",
-				amountPerUser, m.Author.ID, recipientBalance),
+				recipientAmount, c.Author.ID, recipientBalance),
 			"Rain Received",
 			"")
 	}
 }
 
-func handleRainChannels(database db.Database, args []string, s *discordgo.Session, m *discordgo.MessageCreate) {
+func handleRainChannels(database db.Database, args []string, c *Context) {
 	if len(args) == 0 {
-		ReactErr(s, m)
-		DmUsage(s, m.Author.ID, RAIN_USAGE_NAME, RAIN_USAGE_DETAILS)
+		c.ReactErr()
+		c.Usage(RAIN_USAGE_NAME, RAIN_USAGE_DETAILS)
 		return
 	}
 
 	switch args[0] {
 	case "add":
 		if len(args) != 2 {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Please mention exactly one channel to add")
+			c.ReactErr()
+			c.Error("Please mention exactly one channel to add")
 			return
 		}
-		if m.Author.ID != constants.VIOLET_ID {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "For now only violet can change these variables")
+		if err := authorizeRainChannels(database, c); err != nil {
+			renderError(c, err, RAIN_USAGE_NAME, RAIN_USAGE_DETAILS)
 			return
 		}
 
@@ -224,66 +229,64 @@ func handleRainChannels(database db.Database, args []string, s *discordgo.Sessio
 		channelID := strings.TrimPrefix(strings.TrimSuffix(args[1], ">"), "<#")
 
 		// Verify channel exists in this guild
-		channel, err := s.Channel(channelID)
-		if err != nil || channel.GuildID != m.GuildID {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Invalid channel or channel not found in this server")
+		channel, err := c.Session.Channel(channelID)
+		if err != nil || channel.GuildID != c.GuildID {
+			c.ReactErr()
+			c.Error("Invalid channel or channel not found in this server")
 			return
 		}
 
 		if channel.Type != discordgo.ChannelTypeGuildText {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Only text channels can be added to rain whitelist")
+			c.ReactErr()
+			c.Error("Only text channels can be added to rain whitelist")
 			return
 		}
 
-		err = database.AddRainChannel(m.GuildID, channelID)
+		err = database.AddRainChannel(c.GuildID, channelID)
 		if err != nil {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Failed to add channel to rain whitelist")
+			c.ReactErr()
+			c.Error("Failed to add channel to rain whitelist")
 			return
 		}
 
-		ReactOk(s, m)
-		DmSuccess(s, m.Author.ID, fmt.Sprintf("Added <#%s> to rain whitelist", channelID), "Channel Added", "")
+		c.ReactOk()
+		c.Success(fmt.Sprintf("Added <#%s> to rain whitelist", channelID), "Channel Added", "")
 
 	case "remove":
 		if len(args) != 2 {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Please mention exactly one channel to remove")
+			c.ReactErr()
+			c.Error("Please mention exactly one channel to remove")
 			return
 		}
-		// Check if user is violet
-		if m.Author.ID != "1348921951493554277" {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "For now only violet can change these variables")
+		if err := authorizeRainChannels(database, c); err != nil {
+			renderError(c, err, RAIN_USAGE_NAME, RAIN_USAGE_DETAILS)
 			return
 		}
 
 		// Parse channel mention
 		channelID := strings.TrimPrefix(strings.TrimSuffix(args[1], ">"), "<#")
 
-		err := database.RemoveRainChannel(m.GuildID, channelID)
+		err := database.RemoveRainChannel(c.GuildID, channelID)
 		if err != nil {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Failed to remove channel from rain whitelist")
+			c.ReactErr()
+			c.Error("Failed to remove channel from rain whitelist")
 			return
 		}
 
-		ReactOk(s, m)
-		DmSuccess(s, m.Author.ID, fmt.Sprintf("Removed <#%s> from rain whitelist", channelID), "Channel Removed", "")
+		c.ReactOk()
+		c.Success(fmt.Sprintf("Removed <#%s> from rain whitelist", channelID), "Channel Removed", "")
 
 	case "list":
-		channels, err := database.GetRainChannels(m.GuildID)
+		channels, err := database.GetRainChannels(c.GuildID)
 		if err != nil {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Error retrieving channel list")
+			c.ReactErr()
+			c.Error("Error retrieving channel list")
 			return
 		}
 
 		if len(channels) == 0 {
-			ReactOk(s, m)
-			DmSuccess(s, m.Author.ID, "No channels are currently whitelisted for rain", "Rain Channels", "")
+			c.ReactOk()
+			c.Success("No channels are currently whitelisted for rain", "Rain Channels", "")
 			return
 		}
 
@@ -292,28 +295,124 @@ func handleRainChannels(database db.Database, args []string, s *discordgo.Sessio
 			channelList += fmt.Sprintf("• <#%s>\n", channelID)
 		}
 
-		ReactOk(s, m)
-		DmSuccess(s, m.Author.ID, channelList, "Rain Channels", fmt.Sprintf("Total: %d channels", len(channels)))
+		c.ReactOk()
+		c.Success(channelList, "Rain Channels", fmt.Sprintf("Total: %d channels", len(channels)))
 
 	case "clear":
-		// Check if user is violet
-		if m.Author.ID != "1348921951493554277" {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "For now only violet can change these variables")
+		if err := authorizeRainChannels(database, c); err != nil {
+			renderError(c, err, RAIN_USAGE_NAME, RAIN_USAGE_DETAILS)
 			return
 		}
-		err := database.ClearRainChannels(m.GuildID)
+		err := database.ClearRainChannels(c.GuildID)
+		if err != nil {
+			c.ReactErr()
+			c.Error("Error clearing channel list")
+			return
+		}
+
+		c.ReactOk()
+		c.Success("All channels have been removed from the rain whitelist", "Channels Cleared", "")
+
+	default:
+		c.ReactErr()
+		c.Usage(RAIN_USAGE_NAME, RAIN_USAGE_DETAILS)
+	}
+}
+
+// authorizeRainChannels checks the caller may change this server's rain channels
+func authorizeRainChannels(database db.Database, c *Context) error {
+	return core.Authorize(database, newAdminRequest(c, nil), c.GuildID, db.ROLE_MODERATOR)
+}
+
+const RAIN_SCHEDULE_USAGE_NAME = "$rain schedule amount every interval [max=N] OR $rain schedule [list|cancel id|resume id]"
+const RAIN_SCHEDULE_USAGE_DETAILS = "Repeat a rain on this server's active users until you cancel it. If your balance runs out, the rain is paused until you resume it.\nExample: $rain schedule 5 every 24h max=20"
+
+func handleRainSchedule(database db.Database, args []string, c *Context) {
+	if len(args) == 0 {
+		renderError(c, core.ErrUsage, RAIN_SCHEDULE_USAGE_NAME, RAIN_SCHEDULE_USAGE_DETAILS)
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		rains, err := core.ListScheduledRains(database, newRequest(c, nil))
 		if err != nil {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Error clearing channel list")
+			renderError(c, err, RAIN_SCHEDULE_USAGE_NAME, RAIN_SCHEDULE_USAGE_DETAILS)
 			return
 		}
+		embed := &discordgo.MessageEmbed{
+			Title:  "Scheduled Rains",
+			Color:  constants.IVY_GREEN,
+			Fields: []*discordgo.MessageEmbedField{},
+		}
+		if len(rains) == 0 {
+			embed.Description = "You have no scheduled rains"
+		}
+		for _, rain := range rains {
+			value := fmt.Sprintf("Server: `%s`\n", rain.ServerID)
+			if rain.MaxUsers > 0 {
+				value += fmt.Sprintf("Up to %d users\n", rain.MaxUsers)
+			}
+			if rain.Paused {
+				value += "⏸ Paused"
+			} else {
+				value += fmt.Sprintf("Next run <t:%d:R>", rain.NextRun)
+			}
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name: fmt.Sprintf("#%d • %.9f IVY every %s",
+					rain.ScheduleID, float64(rain.AmountRaw)/constants.IVY_FACTOR, formatInterval(rain.IntervalSeconds)),
+				Value: value,
+			})
+		}
+		c.ReactOk()
+		c.Reply(embed)
 
-		ReactOk(s, m)
-		DmSuccess(s, m.Author.ID, "All channels have been removed from the rain whitelist", "Channels Cleared", "")
+	case "cancel":
+		req := newRequest(c, args[1:])
+		if err := core.CancelScheduledRain(database, req); err != nil {
+			renderError(c, err, RAIN_SCHEDULE_USAGE_NAME, RAIN_SCHEDULE_USAGE_DETAILS)
+			return
+		}
+		c.ReactOk()
+		c.Success(fmt.Sprintf("Scheduled rain %s is cancelled", args[1]), "Scheduled Rain Cancelled", "")
+
+	case "resume":
+		nextRun, err := core.ResumeScheduledRain(database, newRequest(c, args[1:]))
+		if err != nil {
+			renderError(c, err, RAIN_SCHEDULE_USAGE_NAME, RAIN_SCHEDULE_USAGE_DETAILS)
+			return
+		}
+		c.ReactOk()
+		c.Success(fmt.Sprintf("Scheduled rain %s will next run <t:%d:R>", args[1], nextRun), "Scheduled Rain Resumed", "")
 
 	default:
-		ReactErr(s, m)
-		DmUsage(s, m.Author.ID, RAIN_USAGE_NAME, RAIN_USAGE_DETAILS)
+		runRainSchedule(database, c, newRequest(c, args))
+	}
+}
+
+// runRainSchedule sets up a scheduled rain, possibly after confirmation
+func runRainSchedule(database db.Database, c *Context, req core.Request) {
+	rain, err := core.ScheduleRain(database, req)
+	if err != nil {
+		renderActionError(database, c, err, RAIN_SCHEDULE_USAGE_NAME, RAIN_SCHEDULE_USAGE_DETAILS)
+		return
+	}
+	c.ReactOk()
+	c.Success(
+		fmt.Sprintf("Raining **%.9f** IVY on this server's active users every %s, starting <t:%d:R>\n\nCancel it with `$rain schedule cancel %d`",
+			float64(rain.AmountRaw)/constants.IVY_FACTOR, formatInterval(rain.IntervalSeconds), rain.NextRun, rain.ScheduleID),
+		fmt.Sprintf("Scheduled Rain #%d", rain.ScheduleID),
+		"Keep enough IVY in your balance, or the rain will be paused")
+}
+
+// formatInterval renders whole seconds as a short duration, like 24h or 1h30m
+func formatInterval(seconds int64) string {
+	s := (time.Duration(seconds) * time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
 	}
+	return s
 }
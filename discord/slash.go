@@ -0,0 +1,325 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+type slashOptions = []*discordgo.ApplicationCommandInteractionDataOption
+
+// slashCommand is a typed front end for one of the $ commands: its options
+// are turned back into $ arguments, so both share a single implementation
+type slashCommand struct {
+	command *discordgo.ApplicationCommand
+	// Replies are only visible to the caller, which makes the command usable
+	// in servers even when it handles secrets like claim links
+	ephemeral bool
+	run       CommandFunc
+	args      func(options slashOptions) []string
+}
+
+// Lower bounds of numeric options, which discordgo takes by pointer
+var MIN_RAIN_RECIPIENTS = 1.0
+
+// The amount is taken as text and parsed like a $ argument, so it stays exact
+// and can be given in USD
+var amountOption = &discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionString,
+	Name:        "amount",
+	Description: "Amount of IVY, or of USD like $5",
+	Required:    true,
+}
+
+func idOption(description string) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "id",
+		Description: description,
+		Required:    true,
+	}
+}
+
+func walletOption(description string) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "wallet",
+		Description: description,
+		Required:    true,
+	}
+}
+
+func subcommand(name, description string, options ...*discordgo.ApplicationCommandOption) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        name,
+		Description: description,
+		Options:     options,
+	}
+}
+
+// optionArg renders an option value the way it would be typed after a $ command
+func optionArg(o *discordgo.ApplicationCommandInteractionDataOption) string {
+	switch o.Type {
+	case discordgo.ApplicationCommandOptionUser:
+		return fmt.Sprintf("<@%s>", o.UserValue(nil).ID)
+	case discordgo.ApplicationCommandOptionRole:
+		return fmt.Sprintf("<@&%s>", o.Value)
+	case discordgo.ApplicationCommandOptionChannel:
+		return fmt.Sprintf("<#%s>", o.Value)
+	case discordgo.ApplicationCommandOptionInteger:
+		return strconv.FormatInt(o.IntValue(), 10)
+	case discordgo.ApplicationCommandOptionBoolean:
+		return strconv.FormatBool(o.BoolValue())
+	default:
+		return o.StringValue()
+	}
+}
+
+// positionalArgs passes option values in the order they were given
+func positionalArgs(options slashOptions) []string {
+	var args []string
+	for _, o := range options {
+		args = append(args, optionArg(o))
+	}
+	return args
+}
+
+// subcommandArgs passes the subcommand name followed by its option values.
+// A subcommand in bare maps to the $ command without a subcommand.
+func subcommandArgs(bare string) func(options slashOptions) []string {
+	return func(options slashOptions) []string {
+		if len(options) == 0 {
+			return nil
+		}
+		var args []string
+		if options[0].Name != bare {
+			args = append(args, options[0].Name)
+		}
+		return append(args, positionalArgs(options[0].Options)...)
+	}
+}
+
+var SLASH_COMMANDS = []slashCommand{
+	{
+		command: &discordgo.ApplicationCommand{
+			Name:        "balance",
+			Description: "Check your current balance",
+		},
+		run:  BalanceCommand,
+		args: positionalArgs,
+	},
+	{
+		command: &discordgo.ApplicationCommand{
+			Name:        "price",
+			Description: "Show the IVY price and a chart of it",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "window",
+					Description: "How far back to chart, a day by default",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "1 hour", Value: "1h"},
+						{Name: "24 hours", Value: "24h"},
+						{Name: "7 days", Value: "7d"},
+					},
+				},
+			},
+		},
+		run:  PriceCommand,
+		args: positionalArgs,
+	},
+	{
+		command: &discordgo.ApplicationCommand{
+			Name:        "tip",
+			Description: "Send coins to another user",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "Who to tip",
+					Required:    true,
+				},
+				amountOption,
+			},
+		},
+		run:  TipCommand,
+		args: positionalArgs,
+	},
+	{
+		command: &discordgo.ApplicationCommand{
+			Name:        "rain",
+			Description: "Rain coins on active users",
+			Options: []*discordgo.ApplicationCommandOption{
+				amountOption,
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "max",
+					Description: "Rain on at most this many users",
+					MinValue:    &MIN_RAIN_RECIPIENTS,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionRole,
+					Name:        "role",
+					Description: "Rain on everyone with this role instead of the active users",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "active",
+					Description: "With a role, only rain on its active members",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "mode",
+					Description: "How to split the amount",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Evenly", Value: core.RAIN_MODE_EVEN},
+						{Name: "Weighted by activity", Value: core.RAIN_MODE_WEIGHTED},
+					},
+				},
+			},
+		},
+		run: RainCommand,
+		args: func(options slashOptions) []string {
+			var args []string
+			for _, o := range options {
+				switch o.Name {
+				case "max", "role", "mode":
+					args = append(args, o.Name+"="+optionArg(o))
+				case "active":
+					if o.BoolValue() {
+						args = append(args, core.RAIN_ACTIVE)
+					}
+				default:
+					args = append(args, optionArg(o))
+				}
+			}
+			return args
+		},
+	},
+	{
+		command: &discordgo.ApplicationCommand{
+			Name:        "deposit",
+			Description: "Deposit coins into your account",
+			Options: []*discordgo.ApplicationCommandOption{
+				subcommand("create", "Create a new deposit", amountOption),
+				subcommand("check", "Check the status of a deposit", idOption("Deposit ID, or its first few characters")),
+				subcommand("list", "List recent deposits"),
+			},
+		},
+		ephemeral: true,
+		run:       DepositCommand,
+		args:      subcommandArgs("create"),
+	},
+	{
+		command: &discordgo.ApplicationCommand{
+			Name:        "withdraw",
+			Description: "Withdraw coins from your account",
+			Options: []*discordgo.ApplicationCommandOption{
+				subcommand("create", "Create a new withdrawal", amountOption, walletOption("Solana address to withdraw to")),
+				subcommand("list", "List recent withdrawals"),
+				subcommand("cancel", "Cancel an unclaimed withdrawal and refund it", idOption("Withdrawal ID, or its first few characters")),
+			},
+		},
+		ephemeral: true,
+		run:       WithdrawCommand,
+		args:      subcommandArgs("create"),
+	},
+	{
+		command: &discordgo.ApplicationCommand{
+			Name:        "pnl",
+			Description: "View profit and loss statistics",
+			Options: []*discordgo.ApplicationCommandOption{
+				subcommand("show", "Show your profit and loss", &discordgo.ApplicationCommandOption{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "game",
+					Description: "Game address, defaults to the current contest",
+				}),
+				subcommand("leaderboard", "Show the leaderboard for the current contest", &discordgo.ApplicationCommandOption{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "realized",
+					Description: "Only count realized gains",
+				}),
+			},
+		},
+		run: PnlCommand,
+		args: func(options slashOptions) []string {
+			if len(options) == 0 {
+				return nil
+			}
+			sub := options[0]
+			if sub.Name == "show" {
+				return positionalArgs(sub.Options)
+			}
+			args := []string{"leaderboard"}
+			if len(sub.Options) > 0 && sub.Options[0].BoolValue() {
+				args = append(args, "realized")
+			}
+			return args
+		},
+	},
+	{
+		command: &discordgo.ApplicationCommand{
+			Name:        "volume",
+			Description: "View trading volume",
+			Options: []*discordgo.ApplicationCommandOption{
+				subcommand("show", "Show your total trading volume across all linked wallets"),
+				subcommand("leaderboard", "Show the volume leaderboard for the current contest"),
+			},
+		},
+		run:  VolumeCommand,
+		args: subcommandArgs("show"),
+	},
+	{
+		command: &discordgo.ApplicationCommand{
+			Name:        "link",
+			Description: "Link a Solana wallet to your account",
+			Options: []*discordgo.ApplicationCommandOption{
+				subcommand("start", "Get a link to sign with your wallet", walletOption("Wallet address to link")),
+				subcommand("complete", "Finish linking with the response from the website", &discordgo.ApplicationCommandOption{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "response",
+					Description: "Response copied from the website",
+					Required:    true,
+				}),
+				subcommand("list", "List your linked wallets"),
+				subcommand("remove", "Remove a linked wallet", walletOption("Wallet address to remove")),
+			},
+		},
+		ephemeral: true,
+		run:       LinkCommand,
+		args:      subcommandArgs("start"),
+	},
+}
+
+// registerSlashCommands replaces the bot's application commands with SLASH_COMMANDS
+func registerSlashCommands(s *discordgo.Session) error {
+	commands := make([]*discordgo.ApplicationCommand, len(SLASH_COMMANDS))
+	for i, sc := range SLASH_COMMANDS {
+		commands[i] = sc.command
+	}
+	_, err := s.ApplicationCommandBulkOverwrite(s.State.User.ID, "", commands)
+	return err
+}
+
+// handleSlashCommand runs the $ command behind an application command
+func handleSlashCommand(database db.Database, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	for _, sc := range SLASH_COMMANDS {
+		if sc.command.Name != data.Name {
+			continue
+		}
+		c, err := newInteractionContext(s, i, sc.ephemeral)
+		if err != nil {
+			log.Printf("can't acknowledge /%s: %v\n", data.Name, err)
+			return
+		}
+		sc.run(database, sc.args(data.Options), c)
+		c.finish()
+		return
+	}
+}
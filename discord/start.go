@@ -8,18 +8,18 @@ import (
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 	"github.com/ivypowered/ivy-sprite-bot/db"
 )
 
 type CommandFunc func(
 	db db.Database,
 	args []string,
-	s *discordgo.Session,
-	m *discordgo.MessageCreate,
+	c *Context,
 )
 
 // starts the discord connection, returns a function that closes it!
-func Start(db db.Database, token string, submitC <-chan string) (func() error, error) {
+func Start(db db.Database, token string, submitC <-chan string, notifyC <-chan core.Notification) (func() error, error) {
 	if token == "" {
 		return nil, errors.New("no token passed to discord.Start")
 	}
@@ -30,17 +30,26 @@ func Start(db db.Database, token string, submitC <-chan string) (func() error, e
 	}
 
 	commands := map[string]CommandFunc{
+		"admin":    AdminCommand,
+		"alert":    AlertCommand,
 		"balance":  BalanceCommand,
+		"bounty":   BountyCommand,
+		"config":   ConfigCommand,
 		"deposit":  DepositCommand,
 		"help":     HelpCommand,
+		"history":  HistoryCommand,
 		"id":       IdCommand,
 		"rain":     RainCommand,
 		"tip":      TipCommand,
+		"vest":     VestCommand,
 		"link":     LinkCommand,
+		"move":     MoveCommand,
+		"price":    PriceCommand,
 		"withdraw": WithdrawCommand,
 		"contest":  ContestCommand,
 		"volume":   VolumeCommand,
 		"pnl":      PnlCommand,
+		"raffle":   RaffleCommand,
 	}
 
 	// Register message handler
@@ -84,12 +93,28 @@ func Start(db db.Database, token string, submitC <-chan string) (func() error, e
 
 		// Look up and execute command
 		if f, exists := commands[cmdName]; exists {
-			f(db, args, s, m)
+			f(db, args, newMessageContext(s, m))
 		}
 	})
 
+	// Register slash command and button handler
+	dg.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		switch i.Type {
+		case discordgo.InteractionApplicationCommand:
+			handleSlashCommand(db, s, i)
+		case discordgo.InteractionMessageComponent:
+			handleConfirmation(db, s, i)
+		}
+	})
+
+	// Enter raffles by reacting to their announcement
+	dg.AddHandler(func(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+		handleRaffleReaction(db, s, r)
+	})
+
 	// Set intents
-	dg.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsDirectMessages
+	// Guild members are privileged: role rain needs it enabled in the developer portal
+	dg.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsDirectMessages | discordgo.IntentsGuildMembers | discordgo.IntentsGuildMessageReactions
 
 	// Open websocket connection
 	err = dg.Open()
@@ -97,7 +122,12 @@ func Start(db db.Database, token string, submitC <-chan string) (func() error, e
 		return nil, fmt.Errorf("Error opening connection: %v", err)
 	}
 
-	// Submit logic
+	// The $ commands keep working if this fails
+	if err := registerSlashCommands(dg); err != nil {
+		log.Printf("can't register slash commands: %v\n", err)
+	}
+
+	// Submit and notification logic
 	closeSubmitC := make(chan struct{})
 	go func() {
 		for {
@@ -113,6 +143,14 @@ func Start(db db.Database, token string, submitC <-chan string) (func() error, e
 				if err != nil {
 					log.Printf("can't submit link: %v\n", err)
 				}
+			case notification, ok := <-notifyC:
+				if !ok {
+					// closed
+					return
+				}
+				if err := sendNotification(dg, notification); err != nil {
+					log.Printf("can't notify %s: %v\n", notification.UserID, err)
+				}
 			}
 		}
 	}()
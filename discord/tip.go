@@ -4,105 +4,136 @@ package discord
 import (
 	"fmt"
 	"regexp"
+	"slices"
+	"strings"
 
-	"github.com/bwmarrin/discordgo"
 	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 	"github.com/ivypowered/ivy-sprite-bot/db"
-	"github.com/ivypowered/ivy-sprite-bot/util"
 )
 
 var DISCORD_ID_REGEX = regexp.MustCompile(`<@!?(\d+)>`)
 var TELEGRAM_ID_REGEX = regexp.MustCompile(`tg:(\d+)$`)
 
-func TipCommand(database db.Database, args []string, s *discordgo.Session, m *discordgo.MessageCreate) {
-	if len(args) != 2 {
-		ReactErr(s, m)
-		DmUsage(s, m.Author.ID, "$tip @user <amount>", "Send coins to another user. Mention the user and specify a positive amount.")
-		return
+const TIP_USAGE = "$tip @user <amount> OR $tip @user1 @user2 ... <amount> each|split"
+const TIP_DETAILS = "Send coins to other users. Mention them and specify a positive amount.\nWith several users, `each` sends everyone the amount and `split` divides it between them.\nExample: $tip @alice @bob 5 each"
+
+// parseMention extracts a database ID from a Discord mention or a tg: ID
+func parseMention(arg string) (string, bool) {
+	if discordMatches := DISCORD_ID_REGEX.FindStringSubmatch(arg); len(discordMatches) == 2 {
+		return discordMatches[1], true
 	}
+	if tgMatches := TELEGRAM_ID_REGEX.FindStringSubmatch(arg); len(tgMatches) == 2 {
+		return tgMatches[0], true
+	}
+	return "", false
+}
 
-	// Extract user ID from mention
-	discordMatches := DISCORD_ID_REGEX.FindStringSubmatch(args[0])
-	tgMatches := TELEGRAM_ID_REGEX.FindStringSubmatch(args[0])
-	var recipientID string
-	if len(discordMatches) == 2 {
-		recipientID = discordMatches[1]
-		// Ensure Discord user exists
-		database.EnsureUserExists(recipientID)
-	} else if len(tgMatches) == 2 {
-		recipientID = tgMatches[0]
-		// Don't ensure TG user exists, because it might be wrong
-		extant, err := database.IsUserExtant(recipientID)
-		if err != nil {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, fmt.Sprintf("Error querying db: %v", err))
-			return
+func TipCommand(database db.Database, args []string, c *Context) {
+	// Mentions come first, then the amount and maybe how to share it
+	var recipientIDs []string
+	for len(args) > 0 {
+		recipientID, ok := parseMention(args[0])
+		if !ok {
+			break
 		}
-		if !extant {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, fmt.Sprintf("Telegram user %s not found, make sure they have run /balance at least once", recipientID))
-			return
-		}
-	} else {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Please mention a valid user")
+		recipientIDs = append(recipientIDs, recipientID)
+		args = args[1:]
+	}
+	if len(recipientIDs) == 0 && len(args) == 2 {
+		c.ReactErr()
+		c.Error("Please mention a valid user")
 		return
 	}
-
-	// Parse amount
-	amount, err := util.ParseAmount(args[1])
-	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Please enter a valid positive amount")
+	if len(recipientIDs) == 0 || len(args) == 0 || len(args) > 2 {
+		renderError(c, core.ErrUsage, TIP_USAGE, TIP_DETAILS)
 		return
 	}
 
-	// Convert to RAW
-	amountRaw := uint64(amount * constants.IVY_FACTOR)
-
-	// Ensure author exists in database
-	database.EnsureUserExists(m.Author.ID)
+	req := newRequest(c, args)
+	req.Mentions = recipientIDs
+	runTip(database, c, req)
+}
 
-	// Check sender's balance
-	senderBalanceRaw, err := database.GetUserBalanceRaw(m.Author.ID)
-	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Error checking balance")
+func runTip(database db.Database, c *Context, req core.Request) {
+	if len(req.Mentions) > 1 || len(req.Args) > 1 {
+		runTipMany(database, c, req)
 		return
 	}
 
-	if senderBalanceRaw < amountRaw {
-		senderBalance := float64(senderBalanceRaw) / constants.IVY_FACTOR
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, fmt.Sprintf("Insufficient balance. Your balance: **%.9f** IVY", senderBalance))
+	result, err := core.Tip(database, req)
+	if err != nil {
+		renderActionError(database, c, err, TIP_USAGE, TIP_DETAILS)
 		return
 	}
 
-	// Perform transfer
-	err = database.TransferFundsRaw(m.Author.ID, recipientID, amountRaw)
+	renderTransfer(c, result)
+}
+
+func runTipMany(database db.Database, c *Context, req core.Request) {
+	result, err := core.TipMany(database, req)
 	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, fmt.Sprintf("Error processing transfer: %v", err))
+		renderActionError(database, c, err, TIP_USAGE, TIP_DETAILS)
 		return
 	}
 
-	// Get new balances
-	newBalanceRaw, _ := database.GetUserBalanceRaw(m.Author.ID)
-	newBalance := float64(newBalanceRaw) / constants.IVY_FACTOR
+	amount := float64(result.AmountRaw) / constants.IVY_FACTOR
+	amountPerUser := float64(result.AmountPerUserRaw) / constants.IVY_FACTOR
+	amountMax := float64(slices.Max(result.RecipientAmountsRaw)) / constants.IVY_FACTOR
+	newBalance := float64(result.SenderBalanceRaw) / constants.IVY_FACTOR
+	recipients := make([]string, len(result.Recipients))
+	for i, recipientID := range result.Recipients {
+		recipients[i] = formatUser(recipientID)
+	}
+
+	c.ReactOk()
+
+	// A split that doesn't divide evenly gives some recipients one RAW more
+	sent := fmt.Sprintf("Successfully sent **%.9f** IVY to each of %s", amountPerUser, strings.Join(recipients, ", "))
+	if amountMax != amountPerUser {
+		sent = fmt.Sprintf("Successfully sent **%.9f** to **%.9f** IVY to each of %s", amountPerUser, amountMax, strings.Join(recipients, ", "))
+	}
+
+	// DM sender a single summary
+	c.Success(
+		fmt.Sprintf("%s\n\nTotal: **%.9f** IVY\nYour new balance: **%.9f** IVY", sent, amount, newBalance),
+		"Transfer Complete",
+		"")
 
-	ReactOk(s, m)
+	// DM each recipient
+	for i, recipientID := range result.Recipients {
+		if core.IsTelegramID(recipientID) {
+			continue
+		}
+		recipientAmount := float64(result.RecipientAmountsRaw[i]) / constants.IVY_FACTOR
+		recipientBalance := float64(result.RecipientBalancesRaw[i]) / constants.IVY_FACTOR
+		DmSuccess(c.Session, recipientID,
+			fmt.Sprintf("You received **%.9f** IVY from <@%s>\n\nYour new balance: **%.9f** IVY", recipientAmount, c.Author.ID, recipientBalance),
+			"Payment Received",
+			"")
+	}
+}
+
+// renderTransfer confirms a tip or move to both parties
+func renderTransfer(c *Context, result core.TransferResult) {
+	amount := float64(result.AmountRaw) / constants.IVY_FACTOR
+	newBalance := float64(result.SenderBalanceRaw) / constants.IVY_FACTOR
+
+	c.ReactOk()
 
 	// DM sender confirmation
-	DmSuccess(s, m.Author.ID,
-		fmt.Sprintf("Successfully sent **%.9f** IVY to <@%s>\n\nYour new balance: **%.9f** IVY", amount, recipientID, newBalance),
+	c.Success(
+		fmt.Sprintf("Successfully sent **%.9f** IVY to %s\n\nYour new balance: **%.9f** IVY", amount, formatUser(result.RecipientID), newBalance),
 		"Transfer Complete",
 		"")
 
 	// DM recipient notification
-	recipientBalanceRaw, _ := database.GetUserBalanceRaw(recipientID)
-	recipientBalance := float64(recipientBalanceRaw) / constants.IVY_FACTOR
-	DmSuccess(s, recipientID,
-		fmt.Sprintf("You received **%.9f** IVY from <@%s>\n\nYour new balance: **%.9f** IVY", amount, m.Author.ID, recipientBalance),
+	if core.IsTelegramID(result.RecipientID) {
+		return
+	}
+	recipientBalance := float64(result.RecipientBalanceRaw) / constants.IVY_FACTOR
+	DmSuccess(c.Session, result.RecipientID,
+		fmt.Sprintf("You received **%.9f** IVY from <@%s>\n\nYour new balance: **%.9f** IVY", amount, c.Author.ID, recipientBalance),
 		"Payment Received",
 		"")
 }
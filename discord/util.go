@@ -1,32 +1,35 @@
 package discord
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/bwmarrin/discordgo"
 	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 )
 
-func ReactOk(s *discordgo.Session, m *discordgo.MessageCreate) {
-	s.MessageReactionAdd(m.ChannelID, m.ID, "\U00002705") // green check
-}
-
-func ReactClock(s *discordgo.Session, m *discordgo.MessageCreate) {
-	s.MessageReactionAdd(m.ChannelID, m.ID, "\U0001F552") // three o' clock
-}
-
-func ReactErr(s *discordgo.Session, m *discordgo.MessageCreate) {
-	s.MessageReactionAdd(m.ChannelID, m.ID, "\U0000274C") // x
-}
-
-// DmUsage sends a usage embed to a user via DM
-func DmUsage(s *discordgo.Session, userID string, commandName string, commandDetails string) (*discordgo.Message, error) {
+// dm sends an embed to a user via DM
+func dm(s *discordgo.Session, userID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
 	// Create DM channel
 	channel, err := s.UserChannelCreate(userID)
 	if err != nil {
 		return nil, err
 	}
 
+	// Send the message
+	msg, err := s.ChannelMessageSendEmbed(channel.ID, embed)
+	if err != nil {
+		// User may have blocked the bot
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func usageEmbed(commandName string, commandDetails string) *discordgo.MessageEmbed {
 	// Create purple embed
-	embed := &discordgo.MessageEmbed{
+	return &discordgo.MessageEmbed{
 		Title: "Usage",
 		Color: constants.IVY_PURPLE, // Purple
 		Fields: []*discordgo.MessageEmbedField{
@@ -37,75 +40,27 @@ func DmUsage(s *discordgo.Session, userID string, commandName string, commandDet
 			},
 		},
 	}
-
-	// Send the message
-	msg, err := s.ChannelMessageSendEmbed(channel.ID, embed)
-	if err != nil {
-		// User may have blocked the bot
-		return nil, err
-	}
-
-	return msg, nil
 }
 
-// DmError sends an error embed to a user via DM
-func DmError(s *discordgo.Session, userID string, message string) (*discordgo.Message, error) {
-	// Create DM channel
-	channel, err := s.UserChannelCreate(userID)
-	if err != nil {
-		return nil, err
-	}
-
+func errorEmbed(message string) *discordgo.MessageEmbed {
 	// Create red embed
-	embed := &discordgo.MessageEmbed{
+	return &discordgo.MessageEmbed{
 		Title:       "Error",
 		Description: message,
 		Color:       constants.IVY_RED, // Red
 	}
-
-	// Send the message
-	msg, err := s.ChannelMessageSendEmbed(channel.ID, embed)
-	if err != nil {
-		// User may have blocked the bot
-		return nil, err
-	}
-
-	return msg, nil
 }
 
-// DmClock sends an clock embed to a user via DM
-func DmClock(s *discordgo.Session, userID string, title string, message string) (*discordgo.Message, error) {
-	// Create DM channel
-	channel, err := s.UserChannelCreate(userID)
-	if err != nil {
-		return nil, err
-	}
-
+func clockEmbed(title string, message string) *discordgo.MessageEmbed {
 	// Create white embed
-	embed := &discordgo.MessageEmbed{
+	return &discordgo.MessageEmbed{
 		Title:       title,
 		Description: message,
 		Color:       constants.IVY_WHITE, // White
 	}
-
-	// Send the message
-	msg, err := s.ChannelMessageSendEmbed(channel.ID, embed)
-	if err != nil {
-		// User may have blocked the bot
-		return nil, err
-	}
-
-	return msg, nil
 }
 
-// DmSuccess sends a success embed to a user via DM
-func DmSuccess(s *discordgo.Session, userID string, message string, header string, footer string) (*discordgo.Message, error) {
-	// Create DM channel
-	channel, err := s.UserChannelCreate(userID)
-	if err != nil {
-		return nil, err
-	}
-
+func successEmbed(message string, header string, footer string) *discordgo.MessageEmbed {
 	// Use default header if empty
 	if header == "" {
 		header = "Success"
@@ -125,12 +80,84 @@ func DmSuccess(s *discordgo.Session, userID string, message string, header strin
 		}
 	}
 
-	// Send the message
-	msg, err := s.ChannelMessageSendEmbed(channel.ID, embed)
-	if err != nil {
-		// User may have blocked the bot
-		return nil, err
+	return embed
+}
+
+// DmError sends an error embed to a user via DM
+func DmError(s *discordgo.Session, userID string, message string) (*discordgo.Message, error) {
+	return dm(s, userID, errorEmbed(message))
+}
+
+// DmClock sends an clock embed to a user via DM
+func DmClock(s *discordgo.Session, userID string, title string, message string) (*discordgo.Message, error) {
+	return dm(s, userID, clockEmbed(title, message))
+}
+
+// DmSuccess sends a success embed to a user via DM
+func DmSuccess(s *discordgo.Session, userID string, message string, header string, footer string) (*discordgo.Message, error) {
+	return dm(s, userID, successEmbed(message, header, footer))
+}
+
+// newRequest builds a core request from a command invocation
+func newRequest(c *Context, args []string) core.Request {
+	return core.Request{
+		CallerID: c.Author.ID,
+		ChatID:   c.GuildID,
+		Private:  c.Private(),
+		Args:     args,
 	}
+}
 
-	return msg, nil
+// renderError reacts to the command and tells the user about an error returned by core
+func renderError(c *Context, err error, usageName string, usageDetails string) {
+	c.ReactErr()
+	var insufficient *core.InsufficientBalanceError
+	switch {
+	case errors.Is(err, core.ErrUsage):
+		c.Usage(usageName, usageDetails)
+	case errors.Is(err, core.ErrPrivateOnly):
+		c.Error("This command can only be used in DMs for security. Please send it directly to me.")
+	case errors.Is(err, core.ErrGroupOnly):
+		c.Error("This command can only be used in server channels, not DMs")
+	case errors.As(err, &insufficient):
+		balance := float64(insufficient.BalanceRaw) / constants.IVY_FACTOR
+		c.Error(fmt.Sprintf("Insufficient balance. Your balance: **%.9f** IVY", balance))
+	default:
+		c.Error(err.Error())
+	}
+}
+
+// formatUser renders a database ID as a mention, or as-is for Telegram users
+func formatUser(userID string) string {
+	if core.IsTelegramID(userID) {
+		return fmt.Sprintf("`%s`", userID)
+	}
+	return fmt.Sprintf("<@%s>", userID)
+}
+
+// sendNotification delivers a core notification to a Discord user via DM,
+// or to its channel
+func sendNotification(s *discordgo.Session, n core.Notification) error {
+	var embed *discordgo.MessageEmbed
+	switch n.Kind {
+	case core.NOTIFY_SUCCESS:
+		embed = successEmbed(n.Message, n.Title, "")
+	case core.NOTIFY_CLOCK:
+		embed = clockEmbed(n.Title, n.Message)
+	case core.NOTIFY_ERROR:
+		embed = errorEmbed(n.Message)
+	default:
+		return nil
+	}
+	if n.ChannelID == "" {
+		_, err := dm(s, n.UserID, embed)
+		return err
+	}
+	send := &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}}
+	if n.Mention != "" {
+		// Mentions only ping from the message content, not the embed
+		send.Content = formatUser(n.Mention)
+	}
+	_, err := s.ChannelMessageSendComplex(n.ChannelID, send)
+	return err
 }
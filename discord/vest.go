@@ -0,0 +1,70 @@
+package discord
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const VEST_USAGE_NAME string = "$vest @user <amount> over <duration> [cliff <duration>]"
+const VEST_USAGE_DETAILS string = `Send IVY that unlocks gradually, like a grant or a team allocation.
+
+• $vest @user 1000 over 30d - Unlock 1000 IVY evenly over 30 days
+• $vest @user 1000 over 365d cliff 90d - Unlock nothing for 90 days, then catch up and keep unlocking until the year is up
+
+The amount leaves your balance right away. The recipient sees what's still locked in $balance.`
+
+func VestCommand(database db.Database, args []string, c *Context) {
+	if len(args) < 1 {
+		renderError(c, core.ErrUsage, VEST_USAGE_NAME, VEST_USAGE_DETAILS)
+		return
+	}
+	recipientID, ok := parseMention(args[0])
+	if !ok {
+		c.ReactErr()
+		c.Error("Please mention a valid user")
+		return
+	}
+
+	req := newRequest(c, args[1:])
+	req.Mentions = []string{recipientID}
+	runVest(database, c, req)
+}
+
+func runVest(database db.Database, c *Context, req core.Request) {
+	vesting, err := core.Vest(database, req)
+	if err != nil {
+		renderActionError(database, c, err, VEST_USAGE_NAME, VEST_USAGE_DETAILS)
+		return
+	}
+	c.ReactOk()
+
+	amount := float64(vesting.AmountRaw) / constants.IVY_FACTOR
+	schedule := fmt.Sprintf("Unlocks gradually until <t:%d:f>", vesting.EndAt)
+	if vesting.CliffAt > vesting.StartAt {
+		schedule = fmt.Sprintf("Nothing unlocks until <t:%d:f>, then it unlocks gradually until <t:%d:f>", vesting.CliffAt, vesting.EndAt)
+	}
+
+	if !c.Private() {
+		c.Send(&discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("🔒 Vesting #%d", vesting.VestingID),
+			Description: fmt.Sprintf("<@%s> is vesting **%.9f** IVY to %s\n\n%s", vesting.SenderID, amount, formatUser(vesting.RecipientID), schedule),
+			Color:       constants.IVY_GREEN,
+		})
+	}
+	c.Success(
+		fmt.Sprintf("**%.9f** IVY left your balance and is vesting to %s\n\n%s", amount, formatUser(vesting.RecipientID), schedule),
+		fmt.Sprintf("Vesting #%d Created", vesting.VestingID),
+		"")
+
+	if core.IsTelegramID(vesting.RecipientID) {
+		return
+	}
+	DmSuccess(c.Session, vesting.RecipientID,
+		fmt.Sprintf("<@%s> is vesting **%.9f** IVY to you\n\n%s. Check what's still locked with `$balance`.", vesting.SenderID, amount, schedule),
+		"Incoming Vesting",
+		"")
+}
@@ -35,26 +35,26 @@ type VolumeBoardResponse struct {
 	Data   []VolumeEntry `json:"data"`
 }
 
-func VolumeCommand(database db.Database, args []string, s *discordgo.Session, m *discordgo.MessageCreate) {
+func VolumeCommand(database db.Database, args []string, c *Context) {
 	// Check if user wants leaderboard
 	if len(args) > 0 && args[0] == "leaderboard" {
-		showLeaderboard(database, s, m)
+		showLeaderboard(database, c)
 		return
 	}
 
 	// Regular volume command
-	showUserVolume(database, s, m)
+	showUserVolume(database, c)
 }
 
-func showUserVolume(database db.Database, s *discordgo.Session, m *discordgo.MessageCreate) {
+func showUserVolume(database db.Database, c *Context) {
 	// Ensure user exists
-	database.EnsureUserExists(m.Author.ID)
+	database.EnsureUserExists(c.Author.ID)
 
 	// Get user's linked wallets
-	wallets, err := database.GetUserWallets(m.Author.ID)
+	wallets, err := database.GetUserWallets(c.Author.ID)
 	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Error fetching your linked wallets")
+		c.ReactErr()
+		c.Error("Error fetching your linked wallets")
 		return
 	}
 
@@ -65,7 +65,7 @@ func showUserVolume(database db.Database, s *discordgo.Session, m *discordgo.Mes
 			Color:       constants.IVY_GREEN,
 			Description: "You have no linked wallets. Use `$link <wallet>` to link a wallet and start tracking your trading volume!",
 		}
-		s.ChannelMessageSendEmbed(m.ChannelID, embed)
+		c.Send(embed)
 		return
 	}
 
@@ -78,8 +78,8 @@ func showUserVolume(database db.Database, s *discordgo.Session, m *discordgo.Mes
 		url := fmt.Sprintf("%s/volume/%s", constants.AGGREGATOR_URL, wallets[0])
 		resp, err := http.Get(url)
 		if err != nil {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Failed to fetch volume data")
+			c.ReactErr()
+			c.Error("Failed to fetch volume data")
 			return
 		}
 		defer resp.Body.Close()
@@ -91,15 +91,15 @@ func showUserVolume(database db.Database, s *discordgo.Session, m *discordgo.Mes
 
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Failed to read volume data")
+			c.ReactErr()
+			c.Error("Failed to read volume data")
 			return
 		}
 
 		err = json.Unmarshal(body, &singleResponse)
 		if err != nil || singleResponse.Status != "ok" {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Failed to parse volume data")
+			c.ReactErr()
+			c.Error("Failed to parse volume data")
 			return
 		}
 
@@ -110,32 +110,32 @@ func showUserVolume(database db.Database, s *discordgo.Session, m *discordgo.Mes
 		reqBody := VolumeMultipleRequest{Users: wallets}
 		jsonBody, err := json.Marshal(reqBody)
 		if err != nil {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Failed to prepare request")
+			c.ReactErr()
+			c.Error("Failed to prepare request")
 			return
 		}
 
 		url := fmt.Sprintf("%s/volume/multiple", constants.AGGREGATOR_URL)
 		resp, err := http.Post(url, "application/json", bytes.NewReader(jsonBody))
 		if err != nil {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Failed to fetch volume data")
+			c.ReactErr()
+			c.Error("Failed to fetch volume data")
 			return
 		}
 		defer resp.Body.Close()
 
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Failed to read volume data")
+			c.ReactErr()
+			c.Error("Failed to read volume data")
 			return
 		}
 
 		var multiResponse VolumeMultipleResponse
 		err = json.Unmarshal(body, &multiResponse)
 		if err != nil || multiResponse.Status != "ok" {
-			ReactErr(s, m)
-			DmError(s, m.Author.ID, "Failed to parse volume data")
+			c.ReactErr()
+			c.Error("Failed to parse volume data")
 			return
 		}
 
@@ -198,21 +198,21 @@ func showUserVolume(database db.Database, s *discordgo.Session, m *discordgo.Mes
 	}
 
 	// Send the embed
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
-	ReactOk(s, m)
+	c.Send(embed)
+	c.ReactOk()
 }
 
-func showLeaderboard(database db.Database, s *discordgo.Session, m *discordgo.MessageCreate) {
+func showLeaderboard(database db.Database, c *Context) {
 	// Get contest address
 	contestAddress, err := database.GetContestAddress()
 	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Failed to retrieve contest address.")
+		c.ReactErr()
+		c.Error("Failed to retrieve contest address.")
 		return
 	}
 
 	if contestAddress == "" {
-		DmError(s, m.Author.ID, "No contest is currently active. Ask Violet to set one!")
+		c.Error("No contest is currently active. Ask Violet to set one!")
 		return
 	}
 
@@ -220,24 +220,24 @@ func showLeaderboard(database db.Database, s *discordgo.Session, m *discordgo.Me
 	url := fmt.Sprintf("%s/games/%s/volume_board?count=25&skip=0", constants.AGGREGATOR_URL, contestAddress)
 	resp, err := http.Get(url)
 	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Failed to fetch leaderboard data.")
+		c.ReactErr()
+		c.Error("Failed to fetch leaderboard data.")
 		return
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Failed to read leaderboard data.")
+		c.ReactErr()
+		c.Error("Failed to read leaderboard data.")
 		return
 	}
 
 	var volumeResponse VolumeBoardResponse
 	err = json.Unmarshal(body, &volumeResponse)
 	if err != nil || volumeResponse.Status != "ok" {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Failed to parse leaderboard data.")
+		c.ReactErr()
+		c.Error("Failed to parse leaderboard data.")
 		return
 	}
 
@@ -315,6 +315,6 @@ func showLeaderboard(database db.Database, s *discordgo.Session, m *discordgo.Me
 		embed.Description = leaderboardText.String()
 	}
 
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
-	ReactOk(s, m)
+	c.Send(embed)
+	c.ReactOk()
 }
@@ -2,106 +2,47 @@
 package discord
 
 import (
-	"encoding/hex"
 	"fmt"
-	"net/url"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/gagliardetto/solana-go"
 	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 	"github.com/ivypowered/ivy-sprite-bot/db"
-	"github.com/ivypowered/ivy-sprite-bot/util"
 )
 
-func getWithdrawUrl(withdrawId, discordId, discordName, signature string) string {
-	return fmt.Sprintf(
-		"https://sprite.ivypowered.com/withdraw?withdraw_id=%s&user_id=%s&name=%s&authority=%s&signature=%s",
-		withdrawId,
-		discordId,
-		url.QueryEscape(discordName),
-		constants.WITHDRAW_AUTHORITY_B58,
-		signature,
-	)
-}
-
-func WithdrawCommand(database db.Database, args []string, s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.GuildID != "" {
-		ReactErr(s, m)
-		DmError(s, m.Author.ID, "Withdrawals can only be processed in DMs for security. Please send this command directly to me.")
-		return
-	}
+const WITHDRAW_USAGE = "$withdraw amount sol_address OR $withdraw list OR $withdraw cancel id"
+const WITHDRAW_DETAILS = "Withdraw coins from your account, list past withdrawals or cancel an unclaimed one. Must be used in DMs.\nExample: $withdraw 0.5 A32dqo7aTp3eHhxpSA6Cw67zWosKc3ymiYz2DbPVx8BK\nExample: $withdraw cancel 3a8fb7"
 
-	if len(args) == 0 || args[0] != "list" && len(args) != 2 {
-		DmUsage(s, m.Author.ID, "$withdraw amount sol_address OR $withdraw list", "Withdraw coins from your account or list past withdrawals. Must be used in DMs.\nExample: $withdraw 0.5 A32dqo7aTp3eHhxpSA6Cw67zWosKc3ymiYz2DbPVx8BK")
+func WithdrawCommand(database db.Database, args []string, c *Context) {
+	if len(args) > 0 && args[0] == "list" {
+		listWithdrawals(database, c)
 		return
 	}
 
-	if args[0] == "list" {
-		listWithdrawals(database, s, m)
+	if len(args) > 0 && args[0] == "cancel" {
+		cancelWithdrawal(database, args[1:], c)
 		return
 	}
 
-	amount, err := util.ParseAmount(args[0])
-	if err != nil || amount <= 0 {
-		DmError(s, m.Author.ID, "Please enter a valid positive amount")
-		return
-	}
-
-	// Convert to RAW
-	amountRaw := uint64(amount * constants.IVY_FACTOR)
-
-	userKey, err := solana.PublicKeyFromBase58(args[1])
-	if err != nil {
-		DmError(s, m.Author.ID, "Please enter a valid base58-encoded Solana address")
-		return
-	}
-
-	database.EnsureUserExists(m.Author.ID)
-
-	balanceRaw, err := database.GetUserBalanceRaw(m.Author.ID)
-	if err != nil {
-		DmError(s, m.Author.ID, "Error checking balance")
-		return
-	}
-
-	if balanceRaw < amountRaw {
-		balance := float64(balanceRaw) / constants.IVY_FACTOR
-		DmError(s, m.Author.ID, fmt.Sprintf("Insufficient balance. Your balance: **%.9f** IVY", balance))
-		return
-	}
-
-	// Generate withdrawal ID
-	withdrawIDBytes := util.GenerateID(amountRaw)
-	withdrawID := hex.EncodeToString(withdrawIDBytes[:])
-
-	// Sign withdrawal
-	signature := util.SignWithdrawal(constants.SPRITE_VAULT, userKey, withdrawIDBytes, constants.WITHDRAW_AUTHORITY_KEY)
-	signatureHex := hex.EncodeToString(signature[:])
+	runWithdraw(database, c, newRequest(c, args))
+}
 
-	// Create withdrawal and debit user atomically
-	err = database.CreateWithdrawal(withdrawID, m.Author.ID, balanceRaw, amountRaw, signatureHex)
+func runWithdraw(database db.Database, c *Context, req core.Request) {
+	result, err := core.CreateWithdrawal(database, req)
 	if err != nil {
-		DmError(s, m.Author.ID, fmt.Sprintf("Error processing withdrawal: %v", err))
+		renderActionError(database, c, err, WITHDRAW_USAGE, WITHDRAW_DETAILS)
 		return
 	}
 
-	// Get new balance
-	newBalanceRaw, _ := database.GetUserBalanceRaw(m.Author.ID)
-	newBalance := float64(newBalanceRaw) / constants.IVY_FACTOR
-
-	// Get user info
-	user, err := s.User(m.Author.ID)
-	if err != nil {
-		DmError(s, m.Author.ID, "Error getting user info")
-		return
-	}
+	amount := float64(result.AmountRaw) / constants.IVY_FACTOR
+	newBalance := float64(result.BalanceRaw) / constants.IVY_FACTOR
 
 	// Create withdrawal URL
-	withdrawURL := getWithdrawUrl(
-		withdrawID,
-		m.Author.ID,
-		user.Username,
-		signatureHex,
+	withdrawURL := core.WithdrawURL(
+		result.WithdrawID,
+		c.Author.ID,
+		c.Author.Username,
+		result.Signature,
 	)
 
 	// Send success embed
@@ -121,7 +62,7 @@ func WithdrawCommand(database db.Database, args []string, s *discordgo.Session,
 			},
 			{
 				Name:   "Withdrawal ID",
-				Value:  fmt.Sprintf("`%s`", withdrawID[:8]+"..."),
+				Value:  fmt.Sprintf("`%s`", result.WithdrawID[:8]+"..."),
 				Inline: false,
 			},
 			{
@@ -132,18 +73,28 @@ func WithdrawCommand(database db.Database, args []string, s *discordgo.Session,
 		},
 	}
 
-	channel, err := s.UserChannelCreate(m.Author.ID)
+	c.Reply(embed)
+}
+
+func cancelWithdrawal(database db.Database, args []string, c *Context) {
+	result, err := core.CancelWithdrawal(database, newRequest(c, args))
 	if err != nil {
+		renderError(c, err, "$withdraw cancel <withdraw_id>", "Cancel an unclaimed withdrawal and refund it")
 		return
 	}
-	s.ChannelMessageSendEmbed(channel.ID, embed)
+
+	c.Success(
+		fmt.Sprintf("Refunded **%.9f IVY** from withdrawal `%s...`\nNew balance: **%.9f IVY**\n\nIts claim link is now void. If it is used anyway, the refund will be taken back.",
+			float64(result.AmountRaw)/constants.IVY_FACTOR,
+			result.WithdrawID[:8],
+			float64(result.BalanceRaw)/constants.IVY_FACTOR),
+		"Withdrawal Cancelled", "")
 }
 
-func listWithdrawals(database db.Database, s *discordgo.Session, m *discordgo.MessageCreate) {
-	// This requires adding a method to Database for listing withdrawals
-	withdrawals, err := database.ListWithdrawals(m.Author.ID, 10)
+func listWithdrawals(database db.Database, c *Context) {
+	withdrawals, err := core.ListWithdrawals(database, newRequest(c, nil))
 	if err != nil {
-		DmError(s, m.Author.ID, "Error fetching withdrawals")
+		renderError(c, err, WITHDRAW_USAGE, WITHDRAW_DETAILS)
 		return
 	}
 
@@ -159,26 +110,31 @@ func listWithdrawals(database db.Database, s *discordgo.Session, m *discordgo.Me
 		for _, withdrawal := range withdrawals {
 			amount := float64(withdrawal.AmountRaw) / constants.IVY_FACTOR
 
-			// Get user info for URL
-			user, _ := s.User(m.Author.ID)
-			withdrawURL := getWithdrawUrl(
-				withdrawal.WithdrawID,
-				m.Author.ID,
-				user.Username,
-				withdrawal.Signature,
-			)
+			value := fmt.Sprintf("ID: `%s`\n", withdrawal.WithdrawID[:8]+"...")
+			switch withdrawal.Status {
+			case db.WITHDRAWAL_CLAIMED:
+				value += "✅ Claimed"
+			case db.WITHDRAWAL_REVOKING:
+				value += "⏳ Cancelling"
+			case db.WITHDRAWAL_REVOKED:
+				value += "🚫 Cancelled and refunded"
+			default:
+				withdrawURL := core.WithdrawURL(
+					withdrawal.WithdrawID,
+					c.Author.ID,
+					c.Author.Username,
+					withdrawal.Signature,
+				)
+				value += fmt.Sprintf("⏳ Pending • [Claim Link](%s)", withdrawURL)
+			}
 
 			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
 				Name:   fmt.Sprintf("%.9f IVY", amount),
-				Value:  fmt.Sprintf("ID: `%s`\n[Claim Link](%s)", withdrawal.WithdrawID[:8]+"...", withdrawURL),
+				Value:  value,
 				Inline: false,
 			})
 		}
 	}
 
-	channel, err := s.UserChannelCreate(m.Author.ID)
-	if err != nil {
-		return
-	}
-	s.ChannelMessageSendEmbed(channel.ID, embed)
+	c.Reply(embed)
 }
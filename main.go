@@ -8,30 +8,63 @@ import (
 	"syscall"
 
 	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 	"github.com/ivypowered/ivy-sprite-bot/db"
 	"github.com/ivypowered/ivy-sprite-bot/discord"
 	"github.com/ivypowered/ivy-sprite-bot/telegram"
+	"github.com/ivypowered/ivy-sprite-bot/worker"
 	"github.com/smallnest/chanx"
 )
 
 var DISCORD_TOKEN string = os.Getenv("DISCORD_TOKEN")
 var TELEGRAM_TOKEN string = os.Getenv("TELEGRAM_TOKEN")
 
+// "sqlite" (default) or "postgres"
+var DB_BACKEND string = os.Getenv("DB_BACKEND")
+
+// SQLite file path or PostgreSQL connection string
+var DB_DSN string = os.Getenv("DB_DSN")
+
 func main() {
+	// Fail now rather than at the first withdrawal if the key is missing
+	constants.WithdrawAuthorityKey()
+
 	// Initialize database
 	var err error
-	database, err := db.New("./bot.db")
+	dsn := DB_DSN
+	if dsn == "" && (DB_BACKEND == "" || DB_BACKEND == db.BACKEND_SQLITE) {
+		dsn = "./bot.db"
+	}
+	database, err := db.Open(DB_BACKEND, dsn, constants.PRICE.Get)
 	if err != nil {
 		log.Fatal("Error initializing database:", err)
 	}
 	defer database.Close()
 
-	// Queue initial price update
-	go constants.PRICE.Update(constants.RPC_CLIENT)
+	// Make sure balances still agree with the ledger
+	mismatches, err := database.VerifyLedger()
+	if err != nil {
+		log.Fatal("Error verifying ledger:", err)
+	}
+	for _, mm := range mismatches {
+		log.Printf("Ledger mismatch for %s: balance %d, ledger %d", mm.UserID, mm.BalanceRaw, mm.LedgerRaw)
+	}
+
+	// Keep price samples in the database, and start from the last one
+	if err := constants.PRICE.SetStore(database); err != nil {
+		log.Fatal("Error loading price history:", err)
+	}
 
 	// Track cleanup functions
 	var cleanupFuncs []func() error
 
+	// Background workers are stopped first so they don't notify into closed bots
+	workerCtx, stopWorkersFn := context.WithCancel(context.Background())
+	cleanupFuncs = append(cleanupFuncs, func() error {
+		stopWorkersFn()
+		return nil
+	})
+
 	// Create submit channel for submitting links Telegram->Discord
 	submitCtx, stopSubmitFn := context.WithCancel(context.Background())
 	cleanupFuncs = append(cleanupFuncs, func() error {
@@ -40,8 +73,13 @@ func main() {
 	})
 	submit := chanx.NewUnboundedChan[string](submitCtx, 1)
 
+	// Create notification channels for background workers -> users
+	discordNotify := chanx.NewUnboundedChan[core.Notification](submitCtx, 1)
+	telegramNotify := chanx.NewUnboundedChan[core.Notification](submitCtx, 1)
+	notifier := core.Notifier{Discord: discordNotify.In, Telegram: telegramNotify.In}
+
 	// Start Telegram bot if token is provided
-	stopTelegramFn, err := telegram.Start(database, TELEGRAM_TOKEN, submit.In)
+	stopTelegramFn, err := telegram.Start(database, TELEGRAM_TOKEN, submit.In, telegramNotify.Out)
 	if err != nil {
 		log.Fatal("Error starting Telegram bot:", err)
 	}
@@ -49,13 +87,22 @@ func main() {
 	log.Println("Telegram bot online")
 
 	// Start Discord bot
-	stopDiscordFn, err := discord.Start(database, DISCORD_TOKEN, submit.Out)
+	stopDiscordFn, err := discord.Start(database, DISCORD_TOKEN, submit.Out, discordNotify.Out)
 	if err != nil {
 		log.Fatal("Error starting Discord bot:", err)
 	}
 	cleanupFuncs = append(cleanupFuncs, stopDiscordFn)
 	log.Println("Discord bot online")
 
+	// Start background workers
+	go worker.WatchDeposits(workerCtx, database, notifier)
+	go worker.ReconcileWithdrawals(workerCtx, database, notifier)
+	go worker.RunScheduledRains(workerCtx, database, notifier)
+	go worker.DrawRaffles(workerCtx, database, notifier)
+	go worker.ReleaseVestings(workerCtx, database, notifier)
+	constants.PRICE.OnUpdate(worker.PriceAlerts(database, notifier))
+	go worker.SamplePrices(workerCtx, database, constants.PRICE)
+
 	log.Println("Send SIGINT to exit")
 
 	// Wait for interrupt signal
@@ -0,0 +1,127 @@
+package price
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+)
+
+var (
+	CHART_BACKGROUND = color.RGBA{0x2b, 0x2d, 0x31, 0xff}
+	CHART_UP         = color.RGBA{0x4c, 0xaf, 0x50, 0xff}
+	CHART_DOWN       = color.RGBA{0xe5, 0x39, 0x35, 0xff}
+)
+
+// Space left around the line so it doesn't touch the edges
+const CHART_PADDING = 8
+
+// Sparkline draws samples as a PNG line chart, green if the price ended at
+// or above where it started and red otherwise. It needs at least two samples.
+func Sparkline(samples []Sample, width, height int) ([]byte, error) {
+	if len(samples) < 2 {
+		return nil, errors.New("not enough price history to chart")
+	}
+	if width <= 2*CHART_PADDING || height <= 2*CHART_PADDING {
+		return nil, errors.New("chart too small")
+	}
+
+	line := CHART_UP
+	if samples[len(samples)-1].PriceUSD < samples[0].PriceUSD {
+		line = CHART_DOWN
+	}
+	fill := color.RGBA{line.R / 4, line.G / 4, line.B / 4, 0x40}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{CHART_BACKGROUND}, image.Point{}, draw.Src)
+
+	// Scale time onto x and price onto y, high prices at the top
+	first, last := samples[0].Timestamp, samples[len(samples)-1].Timestamp
+	low, high := samples[0].PriceUSD, samples[0].PriceUSD
+	for _, sample := range samples {
+		low = min(low, sample.PriceUSD)
+		high = max(high, sample.PriceUSD)
+	}
+	plotWidth := float64(width - 2*CHART_PADDING - 1)
+	plotHeight := float64(height - 2*CHART_PADDING - 1)
+	point := func(sample Sample) image.Point {
+		x := 0.5
+		if last > first {
+			x = float64(sample.Timestamp-first) / float64(last-first)
+		}
+		y := 0.5
+		if high > low {
+			y = (sample.PriceUSD - low) / (high - low)
+		}
+		return image.Point{
+			X: CHART_PADDING + int(x*plotWidth+0.5),
+			Y: CHART_PADDING + int((1-y)*plotHeight+0.5),
+		}
+	}
+
+	points := make([]image.Point, len(samples))
+	for i, sample := range samples {
+		points[i] = point(sample)
+	}
+
+	// Shade under the line, then draw it on top
+	bottom := height - CHART_PADDING
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		for x := a.X; x <= b.X; x++ {
+			y := a.Y
+			if b.X > a.X {
+				y = a.Y + (b.Y-a.Y)*(x-a.X)/(b.X-a.X)
+			}
+			draw.Draw(img, image.Rect(x, y, x+1, bottom), &image.Uniform{fill}, image.Point{}, draw.Over)
+		}
+	}
+	for i := 1; i < len(points); i++ {
+		drawLine(img, points[i-1], points[i], line)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawLine draws a two pixel thick line from a to b
+func drawLine(img *image.RGBA, a, b image.Point, c color.RGBA) {
+	dx, dy := abs(b.X-a.X), -abs(b.Y-a.Y)
+	sx, sy := 1, 1
+	if a.X > b.X {
+		sx = -1
+	}
+	if a.Y > b.Y {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		img.SetRGBA(a.X, a.Y, c)
+		img.SetRGBA(a.X, a.Y+1, c)
+		img.SetRGBA(a.X+1, a.Y, c)
+		if a == b {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			a.X += sx
+		}
+		if e2 <= dx {
+			err += dx
+			a.Y += sy
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
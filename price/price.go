@@ -2,8 +2,13 @@ package price
 
 import (
 	"context"
+	"database/sql"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,14 +16,62 @@ import (
 	"github.com/gagliardetto/solana-go/rpc"
 )
 
+// The IVY/USDC pool the bot has always priced from
 var IVY_POOL = solana.MustPublicKeyFromBase58("2NuvyEVTus5PgrTzJcCKXdF1kJczBJmuPm41y5BZbpqC")
 var USDC_POOL = solana.MustPublicKeyFromBase58("CyehsvWv3pVzbf7gSs98MUm3vTjfRjqixTQjRfFEwxnF")
 
-type Price struct {
-	mu          sync.Mutex
-	price       float64
-	lastUpdated uint64
-	updating    bool
+const (
+	IVY_DECIMALS  = 9
+	USDC_DECIMALS = 6
+)
+
+// ErrNoPrice means no source has been sampled yet
+var ErrNoPrice = errors.New("IVY price is unavailable, try again in a minute")
+
+// StaleError means the latest price is too old to convert with
+type StaleError struct {
+	Age time.Duration
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("IVY price is out of date (last updated %s ago), try again in a minute", e.Age.Truncate(time.Second))
+}
+
+// Sample is the IVY price at one point in time
+type Sample struct {
+	// Unix time the sample was taken
+	Timestamp int64
+	// USD per IVY, the median of the sources that answered
+	PriceUSD float64
+	// How many sources answered
+	Sources int
+}
+
+// Store keeps the samples an Oracle takes
+type Store interface {
+	AddPriceSample(sample Sample) error
+	// LatestPriceSample returns the newest sample, or sql.ErrNoRows
+	LatestPriceSample() (Sample, error)
+	// ListPriceSamples returns the samples taken at or after since, oldest first
+	ListPriceSamples(since int64) ([]Sample, error)
+}
+
+// Source is somewhere the IVY price can be read from
+type Source interface {
+	Name() string
+	// Fetch returns the current USD price of one IVY
+	Fetch(ctx context.Context) (float64, error)
+}
+
+// Pool prices IVY by the ratio of the token vaults of an IVY/USDC pool
+type Pool struct {
+	Client    *rpc.Client
+	IVYVault  solana.PublicKey
+	USDCVault solana.PublicKey
+}
+
+func (p Pool) Name() string {
+	return "pool " + p.IVYVault.String()
 }
 
 func getTokenBalance(a *rpc.Account) (uint64, error) {
@@ -32,49 +85,203 @@ func getTokenBalance(a *rpc.Account) (uint64, error) {
 	return binary.LittleEndian.Uint64(bytes[64:72]), nil
 }
 
-func (p *Price) Update(r *rpc.Client) error {
-	res, err := r.GetMultipleAccounts(context.Background(), IVY_POOL, USDC_POOL)
+func (p Pool) Fetch(ctx context.Context) (float64, error) {
+	res, err := p.Client.GetMultipleAccounts(ctx, p.IVYVault, p.USDCVault)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if len(res.Value) != 2 {
-		return errors.New("not enough accounts returned")
+		return 0, errors.New("not enough accounts returned")
 	}
-	ivy_balance, err := getTokenBalance(res.Value[0])
+	ivyBalance, err := getTokenBalance(res.Value[0])
 	if err != nil {
-		return err
+		return 0, err
 	}
-	usdc_balance, err := getTokenBalance(res.Value[1])
+	usdcBalance, err := getTokenBalance(res.Value[1])
 	if err != nil {
+		return 0, err
+	}
+	if ivyBalance == 0 {
+		return 0, errors.New("pool has no IVY")
+	}
+	return (float64(usdcBalance) / math.Pow10(USDC_DECIMALS)) / (float64(ivyBalance) / math.Pow10(IVY_DECIMALS)), nil
+}
+
+// ParsePools reads pools written as ivy_vault:usdc_vault, separated by commas
+func ParsePools(client *rpc.Client, s string) ([]Source, error) {
+	var sources []Source
+	for _, pair := range strings.Split(s, ",") {
+		ivy, usdc, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("pool %q isn't ivy_vault:usdc_vault", pair)
+		}
+		ivyVault, err := solana.PublicKeyFromBase58(ivy)
+		if err != nil {
+			return nil, fmt.Errorf("pool %q: %v", pair, err)
+		}
+		usdcVault, err := solana.PublicKeyFromBase58(usdc)
+		if err != nil {
+			return nil, fmt.Errorf("pool %q: %v", pair, err)
+		}
+		sources = append(sources, Pool{Client: client, IVYVault: ivyVault, USDCVault: usdcVault})
+	}
+	return sources, nil
+}
+
+// Median returns the middle of prices, or the mean of the middle two
+func Median(prices []float64) float64 {
+	if len(prices) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), prices...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// How many samples a listener can fall behind by before it misses new ones
+const LISTENER_BACKLOG = 16
+
+// Oracle samples the IVY price from its sources, and only hands out prices
+// newer than maxAge
+type Oracle struct {
+	sources []Source
+	maxAge  time.Duration
+
+	mu        sync.Mutex
+	store     Store
+	latest    Sample
+	listeners []chan<- Sample
+}
+
+func New(maxAge time.Duration, sources ...Source) *Oracle {
+	return &Oracle{sources: sources, maxAge: maxAge}
+}
+
+// SetStore makes the oracle keep its samples in store, and picks up the
+// latest one already there
+func (o *Oracle) SetStore(store Store) error {
+	latest, err := store.LatestPriceSample()
+	if err != nil && err != sql.ErrNoRows {
 		return err
 	}
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.updating = false
-	p.price = (float64(usdc_balance) / (1_000_000.0)) / (float64(ivy_balance) / (1_000_000_000.0))
-	p.lastUpdated = uint64(time.Now().Unix())
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.store = store
+	if latest.Timestamp > o.latest.Timestamp {
+		o.latest = latest
+	}
 	return nil
 }
 
-func saturatingSubU64(a, b uint64) uint64 {
-	if a < b {
-		return 0
+// OnUpdate calls fn with every new sample Update takes. fn runs on its own
+// goroutine, one sample at a time, so a slow listener doesn't hold up
+// sampling; one that falls LISTENER_BACKLOG samples behind misses the newest.
+func (o *Oracle) OnUpdate(fn func(Sample)) {
+	samples := make(chan Sample, LISTENER_BACKLOG)
+	go func() {
+		for sample := range samples {
+			fn(sample)
+		}
+	}()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.listeners = append(o.listeners, samples)
+}
+
+// Update samples every source and records their median
+func (o *Oracle) Update(ctx context.Context) (Sample, error) {
+	prices := make([]float64, len(o.sources))
+	errs := make([]error, len(o.sources))
+	var wg sync.WaitGroup
+	for i, source := range o.sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			price, err := source.Fetch(ctx)
+			if err == nil && (math.IsNaN(price) || math.IsInf(price, 0) || price <= 0) {
+				err = fmt.Errorf("invalid price %v", price)
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", source.Name(), err)
+			}
+			prices[i] = price
+		}()
+	}
+	wg.Wait()
+
+	var good []float64
+	for i, price := range prices {
+		if errs[i] == nil {
+			good = append(good, price)
+		}
+	}
+	if len(good) == 0 {
+		return Sample{}, errors.Join(append(errs, errors.New("no price source answered"))...)
+	}
+
+	sample := Sample{
+		Timestamp: time.Now().Unix(),
+		PriceUSD:  Median(good),
+		Sources:   len(good),
+	}
+	o.mu.Lock()
+	o.latest = sample
+	store := o.store
+	listeners := o.listeners
+	o.mu.Unlock()
+
+	var problems []error
+	if store != nil {
+		if err := store.AddPriceSample(sample); err != nil {
+			problems = append(problems, fmt.Errorf("can't store price sample: %w", err))
+		}
+	}
+	for _, samples := range listeners {
+		select {
+		case samples <- sample:
+		default:
+			problems = append(problems, fmt.Errorf("a price listener is %d samples behind and missed this one", LISTENER_BACKLOG))
+		}
+	}
+	return sample, errors.Join(problems...)
+}
+
+// Latest returns the newest sample however old it is, or ErrNoPrice
+func (o *Oracle) Latest() (Sample, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.latest.Timestamp == 0 {
+		return Sample{}, ErrNoPrice
+	}
+	return o.latest, nil
+}
+
+// Get returns the USD price of one IVY, or an error if there's no fresh one
+func (o *Oracle) Get() (float64, error) {
+	latest, err := o.Latest()
+	if err != nil {
+		return 0, err
+	}
+	age := time.Since(time.Unix(latest.Timestamp, 0))
+	if age > o.maxAge {
+		return 0, &StaleError{Age: age}
 	}
-	return a - b
+	return latest.PriceUSD, nil
 }
 
-func (p *Price) Get(r *rpc.Client) float64 {
-	p.mu.Lock()
-	price := p.price
-	timestamp := uint64(time.Now().Unix())
-	delta := saturatingSubU64(timestamp, p.lastUpdated)
-	if delta > 60 && !p.updating {
-		// queue update
-		p.updating = true
-		p.mu.Unlock()
-		go p.Update(r)
-	} else {
-		p.mu.Unlock()
+// History returns the samples taken at or after since, oldest first
+func (o *Oracle) History(since int64) ([]Sample, error) {
+	o.mu.Lock()
+	store := o.store
+	o.mu.Unlock()
+	if store == nil {
+		return nil, errors.New("no price history is kept")
 	}
-	return price
+	return store.ListPriceSamples(since)
 }
@@ -0,0 +1,176 @@
+package price
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+)
+
+type fixedSource struct {
+	price float64
+	err   error
+}
+
+func (s fixedSource) Name() string { return "fixed" }
+
+func (s fixedSource) Fetch(ctx context.Context) (float64, error) { return s.price, s.err }
+
+type memoryStore struct {
+	samples []Sample
+}
+
+func (s *memoryStore) AddPriceSample(sample Sample) error {
+	s.samples = append(s.samples, sample)
+	return nil
+}
+
+func (s *memoryStore) LatestPriceSample() (Sample, error) {
+	if len(s.samples) == 0 {
+		return Sample{}, sql.ErrNoRows
+	}
+	return s.samples[len(s.samples)-1], nil
+}
+
+func (s *memoryStore) ListPriceSamples(since int64) ([]Sample, error) {
+	return s.samples, nil
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		prices []float64
+		want   float64
+	}{
+		{nil, 0},
+		{[]float64{2}, 2},
+		{[]float64{3, 1, 2}, 2},
+		{[]float64{4, 1, 3, 2}, 2.5},
+	}
+	for _, tt := range tests {
+		if got := Median(tt.prices); got != tt.want {
+			t.Errorf("Median(%v) = %v, want %v", tt.prices, got, tt.want)
+		}
+	}
+}
+
+func TestOracle(t *testing.T) {
+	oracle := New(time.Minute,
+		fixedSource{price: 1},
+		fixedSource{price: 3},
+		fixedSource{err: errors.New("down")},
+		fixedSource{price: -1},
+		fixedSource{price: 2},
+	)
+	if _, err := oracle.Get(); err != ErrNoPrice {
+		t.Fatalf("Get before any update = %v", err)
+	}
+
+	store := &memoryStore{}
+	if err := oracle.SetStore(&memoryStore{samples: []Sample{{Timestamp: 1, PriceUSD: 9, Sources: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	// A sample picked up from the store can be stale
+	var stale *StaleError
+	if _, err := oracle.Get(); !errors.As(err, &stale) {
+		t.Fatalf("Get with an old sample = %v", err)
+	}
+
+	if err := oracle.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	heard := make(chan Sample, 1)
+	oracle.OnUpdate(func(sample Sample) { heard <- sample })
+	sample, err := oracle.Update(context.Background())
+	if err != nil || sample.PriceUSD != 2 || sample.Sources != 3 {
+		t.Fatalf("Update = %+v, %v", sample, err)
+	}
+	select {
+	case got := <-heard:
+		if got != sample {
+			t.Fatalf("OnUpdate heard %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnUpdate heard nothing")
+	}
+	if len(store.samples) != 1 || store.samples[0] != sample {
+		t.Fatalf("stored samples = %+v", store.samples)
+	}
+	if price, err := oracle.Get(); err != nil || price != 2 {
+		t.Fatalf("Get = %v, %v", price, err)
+	}
+
+	// With every source down the last price stays, and Update says so
+	down := New(time.Minute, fixedSource{err: errors.New("down")})
+	if _, err := down.Update(context.Background()); err == nil {
+		t.Fatal("Update with no sources answering succeeded")
+	}
+	if _, err := down.Get(); err != ErrNoPrice {
+		t.Fatalf("Get after a failed update = %v", err)
+	}
+}
+
+func TestOracleSlowListener(t *testing.T) {
+	oracle := New(time.Minute, fixedSource{price: 1})
+	stuck := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+	oracle.OnUpdate(func(Sample) {
+		select {
+		case stuck <- struct{}{}:
+		default:
+		}
+		<-release
+	})
+
+	// A listener stuck on one sample holds up neither Update nor the samples
+	// after it, until its backlog is full
+	if _, err := oracle.Update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-stuck
+	for i := 0; i < LISTENER_BACKLOG; i++ {
+		if _, err := oracle.Update(context.Background()); err != nil {
+			t.Fatalf("Update %d with a stuck listener = %v", i, err)
+		}
+	}
+	if _, err := oracle.Update(context.Background()); err == nil {
+		t.Fatal("Update didn't report a sample the listener missed")
+	}
+	if price, err := oracle.Get(); err != nil || price != 1 {
+		t.Fatalf("Get = %v, %v", price, err)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if _, err := Sparkline([]Sample{{Timestamp: 1, PriceUSD: 1}}, 100, 50); err == nil {
+		t.Fatal("charted a single sample")
+	}
+
+	samples := []Sample{
+		{Timestamp: 0, PriceUSD: 1},
+		{Timestamp: 10, PriceUSD: 3},
+		{Timestamp: 20, PriceUSD: 2},
+	}
+	data, err := Sparkline(samples, 100, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 100 || size.Y != 50 {
+		t.Fatalf("chart size = %v", size)
+	}
+	// The line starts at the bottom left and peaks at the top
+	if got := img.At(CHART_PADDING, 50-CHART_PADDING-1); got != color.Color(CHART_UP) {
+		t.Errorf("start of line = %v", got)
+	}
+	if got := img.At(50, CHART_PADDING); got != color.Color(CHART_UP) {
+		t.Errorf("peak of line = %v", got)
+	}
+}
@@ -0,0 +1,123 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const ADMIN_USAGE = `Manage who can administer the bot. Roles apply to the group they're granted in, or everywhere with <code>global</code>.
+
+<b>Usage:</b>
+• Reply with /admin grant [role] [global] - Grant a role
+• Reply with /admin revoke [global] - Revoke a role
+• /admin grant [id] [role] global - Grant a role by Ivy Sprite ID
+• /admin list [global] - List roles
+
+<b>Roles:</b>
+• moderator (default) - Change settings
+• admin - Also grant and revoke roles
+
+Group administrators are admins of their own group.`
+
+// Ivy Sprite ID of a Telegram or Discord user
+var ADMIN_TARGET_REGEX = regexp.MustCompile(`^(tg:\d+|\d+)$`)
+
+func AdminCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	if len(args) == 0 {
+		sendUsage(ctx, b, msg.Chat.ID, "/admin", ADMIN_USAGE)
+		return
+	}
+
+	switch args[0] {
+	case "grant", "revoke":
+		// Target is the replied-to user, or an explicit ID
+		rest := args[1:]
+		var targetID string
+		if msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil {
+			targetID = getDatabaseID(msg.ReplyToMessage.From.ID)
+		} else if len(rest) > 0 && ADMIN_TARGET_REGEX.MatchString(rest[0]) {
+			targetID = rest[0]
+			rest = rest[1:]
+		} else {
+			sendUsage(ctx, b, msg.Chat.ID, "/admin", ADMIN_USAGE)
+			return
+		}
+		req := newAdminRequest(ctx, b, msg, rest)
+		req.Mentions = []string{targetID}
+
+		if args[0] == "grant" {
+			result, err := core.GrantRole(database, req)
+			if err != nil {
+				sendCoreError(ctx, b, msg.Chat.ID, err, "/admin", ADMIN_USAGE)
+				return
+			}
+			sendSuccess(ctx, b, msg.Chat.ID,
+				fmt.Sprintf("%s is now <b>%s</b> in %s", formatUser(result.UserID), result.Role, formatScope(result.Scope)),
+				"✅ <b>Role Granted</b>")
+		} else {
+			result, err := core.RevokeRole(database, req)
+			if err != nil {
+				sendCoreError(ctx, b, msg.Chat.ID, err, "/admin", ADMIN_USAGE)
+				return
+			}
+			sendSuccess(ctx, b, msg.Chat.ID,
+				fmt.Sprintf("%s no longer has a role in %s", formatUser(result.UserID), formatScope(result.Scope)),
+				"✅ <b>Role Revoked</b>")
+		}
+
+	case "list":
+		scope, grants, err := core.ListRoles(database, newAdminRequest(ctx, b, msg, args[1:]))
+		if err != nil {
+			sendCoreError(ctx, b, msg.Chat.ID, err, "/admin", ADMIN_USAGE)
+			return
+		}
+
+		var text strings.Builder
+		text.WriteString(fmt.Sprintf("🛡 <b>Roles in %s</b>\n\n", formatScope(scope)))
+		for _, g := range grants {
+			text.WriteString(fmt.Sprintf("• %s - %s\n", formatUser(g.UserID), g.Role))
+		}
+		if len(grants) == 0 {
+			text.WriteString("No roles granted")
+		}
+
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    msg.Chat.ID,
+			Text:      text.String(),
+			ParseMode: models.ParseModeHTML,
+		})
+
+	default:
+		sendUsage(ctx, b, msg.Chat.ID, "/admin", ADMIN_USAGE)
+	}
+}
+
+// newAdminRequest is newRequest for privileged commands, which also asks
+// Telegram whether the caller administers the group
+func newAdminRequest(ctx context.Context, b *bot.Bot, msg *models.Message, args []string) core.Request {
+	req := newRequest(msg, args)
+	if !req.Private {
+		member, err := b.GetChatMember(ctx, &bot.GetChatMemberParams{
+			ChatID: msg.Chat.ID,
+			UserID: msg.From.ID,
+		})
+		req.ChatAdmin = err == nil &&
+			(member.Type == models.ChatMemberTypeOwner || member.Type == models.ChatMemberTypeAdministrator)
+	}
+	return req
+}
+
+// formatScope renders a role scope for display
+func formatScope(scope string) string {
+	if scope == db.SCOPE_GLOBAL {
+		return "all servers and groups"
+	}
+	return "this group"
+}
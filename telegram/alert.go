@@ -0,0 +1,83 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const ALERT_USAGE = `Get a message when the IVY price crosses a level
+
+<b>Usage:</b>
+• /alert above [price] - Tell me once IVY reaches the price
+• /alert below [price] - Tell me once IVY drops to the price
+• /alert above [price] rearm - Tell me every time, after the price has crossed back
+• /alert list - Show your alerts
+• /alert remove [id] - Remove an alert
+
+<b>Note:</b>
+• Alerts are sent in private, so start a chat with me first`
+
+func AlertCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	if len(args) < 1 {
+		sendUsage(ctx, b, msg.Chat.ID, "/alert", ALERT_USAGE)
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		handleAlertList(ctx, database, b, msg, newRequest(msg, args[1:]))
+	case "remove":
+		alertID, err := core.RemoveAlert(database, newRequest(msg, args[1:]))
+		if err != nil {
+			sendCoreError(ctx, b, msg.Chat.ID, err, "/alert remove [id]", ALERT_USAGE)
+			return
+		}
+		sendSuccess(ctx, b, msg.Chat.ID, fmt.Sprintf("Alert #%d was removed", alertID), "✅ <b>Alert Removed</b>")
+	default:
+		alert, err := core.CreateAlert(database, newRequest(msg, args))
+		if err != nil {
+			sendCoreError(ctx, b, msg.Chat.ID, err, "/alert", ALERT_USAGE)
+			return
+		}
+		note := "It fires once, then stays in /alert list until you remove it"
+		if alert.Rearm {
+			note = "It fires again each time the price crosses back"
+		}
+		sendSuccess(ctx, b, msg.Chat.ID,
+			fmt.Sprintf("I'll message you when IVY goes %s <b>%s</b>\n\n<i>%s</i>", alert.Direction, core.FormatPriceUSD(alert.TargetUSD), note),
+			fmt.Sprintf("🔔 <b>Alert #%d Set</b>", alert.AlertID))
+	}
+}
+
+func handleAlertList(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, req core.Request) {
+	alerts, err := core.ListAlerts(database, req)
+	if err != nil {
+		sendCoreError(ctx, b, msg.Chat.ID, err, "/alert list", ALERT_USAGE)
+		return
+	}
+
+	title := "🔔 <b>Your Alerts</b>"
+	if len(alerts) == 0 {
+		sendSuccess(ctx, b, msg.Chat.ID, "No alerts yet. Set one with /alert above [price] or /alert below [price]", title)
+		return
+	}
+	var text strings.Builder
+	for _, alert := range alerts {
+		text.WriteString(fmt.Sprintf("<b>#%d</b> • %s <b>%s</b>", alert.AlertID, alert.Direction, core.FormatPriceUSD(alert.TargetUSD)))
+		if alert.Rearm {
+			text.WriteString(" • rearms")
+		}
+		if !alert.Armed {
+			text.WriteString(" • fired " + time.Unix(alert.FiredAt, 0).UTC().Format("2006-01-02 15:04 UTC"))
+		}
+		text.WriteString("\n")
+	}
+	sendSuccess(ctx, b, msg.Chat.ID, text.String(), title)
+}
@@ -7,41 +7,62 @@ import (
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 	"github.com/ivypowered/ivy-sprite-bot/db"
 )
 
 func BalanceCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message) {
-	id := getDatabaseID(msg.From.ID)
-	database.EnsureUserExists(id)
-
-	balanceRaw, err := database.GetUserBalanceRaw(id)
+	result, err := core.Balance(database, newRequest(msg, nil))
 	if err != nil {
 		sendError(ctx, b, msg.Chat.ID, err.Error())
 		return
 	}
 
 	// Convert RAW to display value
-	balance := float64(balanceRaw) / constants.IVY_FACTOR
-	price := constants.PRICE.Get(constants.RPC_CLIENT)
+	balance := float64(result.BalanceRaw) / constants.IVY_FACTOR
+	price := result.Price
 
 	// Format the balance message
-	name := msg.From.FirstName
-	if msg.From.Username != "" {
-		name = "@" + msg.From.Username
+	name := displayName(msg.From)
+
+	// USD values are left out while there's no fresh price
+	usd := func(ivy float64) string {
+		if price <= 0 {
+			return "└ 💵 ≈ ? USD"
+		}
+		return fmt.Sprintf("└ 💵 ≈ $%.2f USD", ivy*price)
 	}
 
 	text := fmt.Sprintf(`<b>%s's Ivy Wallet</b>
 
 <b>Balance</b>
 ├ 🌿 %.9f IVY
-└ 💵 ≈ $%.2f USD
+%s`,
+		escapeHTML(name),
+		balance,
+		usd(balance))
+	if result.LockedRaw > 0 {
+		locked := float64(result.LockedRaw) / constants.IVY_FACTOR
+		text += fmt.Sprintf(`
+
+🔒 <b>Locked in Vesting</b>
+├ 🌿 %.9f IVY
+%s`,
+			locked,
+			usd(locked))
+	}
+	if price > 0 {
+		text += fmt.Sprintf(`
 
 📊 <b>Current Price</b>
 └ $%.4f per IVY`,
-		escapeHTML(name),
-		balance,
-		balance*price,
-		price)
+			price)
+	} else {
+		text += `
+
+📊 <b>Current Price</b>
+└ Unavailable right now, try again in a minute`
+	}
 
 	b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:    msg.Chat.ID,
@@ -0,0 +1,157 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const BOUNTY_USAGE = `Offer IVY for a task, like a bug report or art, and pay whoever does it.
+
+<b>Usage:</b>
+• /bounty create [amount] [description] - Post a bounty in this group
+• /bounty award [id] @username - Pay a bounty, or reply to the winner with /bounty award [id]
+• /bounty cancel [id] - Take a bounty down and get the reward back
+• /bounty list - Show this group's open bounties, or your own in private
+
+The reward is taken from your balance when the bounty is posted and held until you award or cancel it.`
+
+func BountyCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	if len(args) < 1 {
+		sendUsage(ctx, b, msg.Chat.ID, "/bounty", BOUNTY_USAGE)
+		return
+	}
+
+	switch args[0] {
+	case "create":
+		runBountyCreate(ctx, database, b, msg, newRequest(msg, args[1:]))
+	case "award":
+		handleBountyAward(ctx, database, b, msg, args[1:])
+	case "cancel":
+		bounty, err := core.CancelBounty(database, newRequest(msg, args[1:]))
+		if err != nil {
+			sendCoreError(ctx, b, msg.Chat.ID, err, "/bounty cancel [id]", BOUNTY_USAGE)
+			return
+		}
+		sendSuccess(ctx, b, msg.Chat.ID,
+			fmt.Sprintf("Bounty #%d was cancelled and its <b>%.9f IVY</b> reward returned to your balance",
+				bounty.BountyID, float64(bounty.AmountRaw)/constants.IVY_FACTOR),
+			"✅ <b>Bounty Cancelled</b>")
+	case "list":
+		handleBountyList(ctx, database, b, msg, newRequest(msg, args[1:]))
+	default:
+		sendUsage(ctx, b, msg.Chat.ID, "/bounty", BOUNTY_USAGE)
+	}
+}
+
+func runBountyCreate(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, req core.Request) {
+	bounty, err := core.CreateBounty(database, req)
+	if err != nil {
+		sendActionError(ctx, database, b, msg, err, "/bounty create [amount] [description]", BOUNTY_USAGE)
+		return
+	}
+
+	sendSuccess(ctx, b, msg.Chat.ID,
+		fmt.Sprintf("%s\n\n<b>Reward:</b> %.9f IVY, posted by %s\n\n<i>The reward is held in escrow until it's awarded with /bounty award %d</i>",
+			escapeHTML(bounty.Description), float64(bounty.AmountRaw)/constants.IVY_FACTOR, escapeHTML(displayName(msg.From)), bounty.BountyID),
+		fmt.Sprintf("💰 <b>Bounty #%d</b>", bounty.BountyID))
+}
+
+func handleBountyAward(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	// The winner is a mention of a user without a username, an @username,
+	// or the sender of the message replied to
+	var winner *models.User
+	var rest []string
+	for _, entity := range msg.Entities {
+		if entity.Type == models.MessageEntityTypeTextMention && entity.User != nil {
+			winner = entity.User
+		}
+	}
+	textMention := winner != nil
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "@") {
+			rest = append(rest, arg)
+			continue
+		}
+		userID, err := core.ResolveTelegramUsername(database, arg)
+		if err != nil {
+			sendError(ctx, b, msg.Chat.ID, err.Error())
+			return
+		}
+		tgID, _ := fromDatabaseID(userID)
+		winner = &models.User{ID: tgID, Username: strings.TrimPrefix(arg, "@")}
+	}
+	if winner == nil && msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil {
+		winner = msg.ReplyToMessage.From
+	}
+	if winner == nil {
+		sendUsage(ctx, b, msg.Chat.ID, "/bounty award [id] @username", BOUNTY_USAGE)
+		return
+	}
+	if winner.IsBot {
+		sendError(ctx, b, msg.Chat.ID, "You can't award a bounty to a bot")
+		return
+	}
+
+	// A text mention's name ends up in the arguments, after the ID
+	if textMention && len(rest) > 1 {
+		rest = rest[:1]
+	}
+	req := newRequest(msg, rest)
+	req.Mentions = []string{getDatabaseID(winner.ID)}
+	bounty, err := core.AwardBounty(database, req)
+	if err != nil {
+		sendCoreError(ctx, b, msg.Chat.ID, err, "/bounty award [id] @username", BOUNTY_USAGE)
+		return
+	}
+
+	amount := float64(bounty.AmountRaw) / constants.IVY_FACTOR
+	sendSuccess(ctx, b, msg.Chat.ID,
+		fmt.Sprintf("%s earned <b>%.9f IVY</b> for:\n%s", escapeHTML(displayName(winner)), amount, escapeHTML(bounty.Description)),
+		fmt.Sprintf("💰 <b>Bounty #%d Awarded</b>", bounty.BountyID))
+
+	// Let the winner know in private
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: winner.ID,
+		Text: fmt.Sprintf("💰 <b>Bounty Awarded</b>\n\nYou earned <b>%.9f IVY</b> from %s's bounty #%d:\n%s",
+			amount, escapeHTML(displayName(msg.From)), bounty.BountyID, escapeHTML(bounty.Description)),
+		ParseMode: models.ParseModeHTML,
+	})
+}
+
+func handleBountyList(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, req core.Request) {
+	bounties, err := core.ListBounties(database, req)
+	if err != nil {
+		sendCoreError(ctx, b, msg.Chat.ID, err, "/bounty list", BOUNTY_USAGE)
+		return
+	}
+
+	title := "💰 <b>Open Bounties</b>"
+	if req.Private {
+		title = "💰 <b>Your Bounties</b>"
+	}
+	if len(bounties) == 0 {
+		sendSuccess(ctx, b, msg.Chat.ID, "No bounties yet. Post one with /bounty create [amount] [description]", title)
+		return
+	}
+
+	var text strings.Builder
+	for _, bounty := range bounties {
+		text.WriteString(fmt.Sprintf("<b>#%d</b> • <b>%.9f IVY</b> • %s",
+			bounty.BountyID, float64(bounty.AmountRaw)/constants.IVY_FACTOR, escapeHTML(bounty.Description)))
+		switch {
+		case bounty.Status == db.BOUNTY_AWARDED:
+			text.WriteString(" • awarded to " + formatUser(bounty.WinnerID))
+		case bounty.Status != db.BOUNTY_OPEN:
+			text.WriteString(" • " + string(bounty.Status))
+		}
+		text.WriteString("\n")
+	}
+	sendSuccess(ctx, b, msg.Chat.ID, text.String(), title)
+}
@@ -0,0 +1,55 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const CONFIG_USAGE = `Show or change this group's rain rules. Changing them requires the moderator role or group admin.
+
+<b>Usage:</b>
+• /config rain - Show rain settings
+• /config rain [setting] [value] - Change a setting
+• /config rain reset - Restore the defaults
+
+<b>Examples:</b>
+• /config rain min_usd 1.00
+• /config rain delta_max 30m`
+
+func ConfigCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	if len(args) == 0 || args[0] != "rain" {
+		sendUsage(ctx, b, msg.Chat.ID, "/config", CONFIG_USAGE)
+		return
+	}
+	args = args[1:]
+
+	var settings db.RainSettings
+	var err error
+	title := "🌧 <b>Rain Settings</b>"
+	switch {
+	case len(args) == 0:
+		settings, err = core.RainConfig(database, newRequest(msg, args))
+	case len(args) == 1 && args[0] == "reset":
+		settings, err = core.ResetRainConfig(database, newAdminRequest(ctx, b, msg, nil))
+		title = "✅ <b>Rain Settings Reset</b>"
+	default:
+		settings, err = core.SetRainConfig(database, newAdminRequest(ctx, b, msg, args))
+		title = "✅ <b>Rain Settings Updated</b>"
+	}
+	if err != nil {
+		sendCoreError(ctx, b, msg.Chat.ID, err, "/config", CONFIG_USAGE)
+		return
+	}
+
+	var text strings.Builder
+	for _, key := range core.RAIN_CONFIG_KEYS {
+		text.WriteString(fmt.Sprintf("<code>%s</code> <b>%s</b> - %s\n", key.Name, escapeHTML(key.Get(settings)), key.Description))
+	}
+	sendSuccess(ctx, b, msg.Chat.ID, text.String(), title)
+}
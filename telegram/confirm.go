@@ -0,0 +1,137 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// actionFunc runs a request that may be held back for confirmation. msg is
+// the message to answer, from the caller in the chat the command was sent in.
+type actionFunc func(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, req core.Request)
+
+// Commands that can be resumed once the caller confirms them
+var CONFIRMABLE = map[core.ActionKind]actionFunc{
+	core.ACTION_TIP: func(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, req core.Request) {
+		recipients := make([]*models.User, len(req.Mentions))
+		for i, recipientID := range req.Mentions {
+			recipients[i] = lookupUser(ctx, b, msg.Chat.ID, recipientID)
+		}
+		runTip(ctx, database, b, msg, req, recipients)
+	},
+	core.ACTION_RAIN:     runRain,
+	core.ACTION_WITHDRAW: runWithdraw,
+	core.ACTION_RAFFLE:   runRaffleStart,
+	core.ACTION_BOUNTY:   runBountyCreate,
+	core.ACTION_VEST:     runVest,
+}
+
+// sendActionError asks the caller to confirm a request core held back, or
+// reports any other error like sendCoreError
+func sendActionError(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, err error, command string, usage string) {
+	var confirm *core.ConfirmationRequiredError
+	if !errors.As(err, &confirm) {
+		sendCoreError(ctx, b, msg.Chat.ID, err, command, usage)
+		return
+	}
+
+	actionID, err := core.SavePendingAction(database, confirm)
+	if err != nil {
+		sendCoreError(ctx, b, msg.Chat.ID, err, command, usage)
+		return
+	}
+
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text: fmt.Sprintf("🕒 <b>Confirm %s</b>\n\n%s\n\n<i>Expires in %s</i>",
+			confirm.Kind, escapeHTML(confirm.Error()), constants.CONFIRM_EXPIRY),
+		ParseMode: models.ParseModeHTML,
+		ReplyParameters: &models.ReplyParameters{
+			MessageID:                msg.ID,
+			AllowSendingWithoutReply: true,
+		},
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{{
+				{Text: "✅ Confirm", CallbackData: "confirm:" + actionID},
+				{Text: "Cancel", CallbackData: "cancel:" + actionID},
+			}},
+		},
+	})
+}
+
+// handleConfirmation answers a press on a confirmation prompt's buttons
+func handleConfirmation(ctx context.Context, database db.Database, b *bot.Bot, query *models.CallbackQuery) {
+	answer, actionID, ok := strings.Cut(query.Data, ":")
+	prompt := query.Message.Message
+	if !ok || (answer != "confirm" && answer != "cancel") || prompt == nil {
+		return
+	}
+
+	kind, req, err := core.TakePendingAction(database, getDatabaseID(query.From.ID), actionID)
+	if err != nil {
+		b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: query.ID,
+			Text:            err.Error(),
+			ShowAlert:       true,
+		})
+		return
+	}
+	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: query.ID})
+
+	// Take the buttons off the prompt, so it can't be answered again
+	status := "✖ Cancelled"
+	if answer == "confirm" {
+		status = "✅ Confirmed"
+	}
+	b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    prompt.Chat.ID,
+		MessageID: prompt.ID,
+		Text:      fmt.Sprintf("<b>%s</b>\n\n%s", status, escapeHTML(prompt.Text)),
+		ParseMode: models.ParseModeHTML,
+	})
+	if answer == "cancel" {
+		return
+	}
+
+	run, ok := CONFIRMABLE[kind]
+	if !ok {
+		return
+	}
+	// Answer as if the caller had sent the prompt
+	msg := &models.Message{
+		ID:   prompt.ID,
+		From: &query.From,
+		Chat: prompt.Chat,
+	}
+	run(ctx, database, b, msg, req)
+}
+
+// lookupUser finds a member of a chat, falling back to just their ID
+func lookupUser(ctx context.Context, b *bot.Bot, chatID int64, userID string) *models.User {
+	tgID, _ := fromDatabaseID(userID)
+	member, err := b.GetChatMember(ctx, &bot.GetChatMemberParams{ChatID: chatID, UserID: tgID})
+	if err == nil {
+		switch member.Type {
+		case models.ChatMemberTypeOwner:
+			return member.Owner.User
+		case models.ChatMemberTypeAdministrator:
+			return &member.Administrator.User
+		case models.ChatMemberTypeMember:
+			return member.Member.User
+		case models.ChatMemberTypeRestricted:
+			return member.Restricted.User
+		case models.ChatMemberTypeLeft:
+			return member.Left.User
+		case models.ChatMemberTypeBanned:
+			return member.Banned.User
+		}
+	}
+	return &models.User{ID: tgID, FirstName: userID}
+}
@@ -2,29 +2,17 @@ package telegram
 
 import (
 	"context"
-	"database/sql"
-	"encoding/hex"
 	"fmt"
-	"net/url"
-	"strconv"
 	"strings"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 	"github.com/ivypowered/ivy-sprite-bot/db"
-	"github.com/ivypowered/ivy-sprite-bot/util"
 )
 
-func DepositCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
-	// Check if it's a private chat
-	if msg.Chat.Type != "private" {
-		sendError(ctx, b, msg.Chat.ID, "Deposit commands must be used in private chat for security.")
-		return
-	}
-
-	if len(args) == 0 {
-		sendUsage(ctx, b, msg.Chat.ID, "/deposit", `Create a new deposit or check an existing one
+const DEPOSIT_USAGE = `Create a new deposit or check an existing one
 
 <b>Usage:</b>
 • /deposit [amount] - Create new deposit
@@ -33,17 +21,18 @@ func DepositCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *
 
 <b>Examples:</b>
 • /deposit 0.75
-• /deposit check 3a8fb7`)
+• /deposit $5
+• /deposit check 3a8fb7`
+
+func DepositCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	if len(args) == 0 {
+		sendCoreError(ctx, b, msg.Chat.ID, core.ErrUsage, "/deposit", DEPOSIT_USAGE)
 		return
 	}
 
 	// Check if this is a deposit check
 	if args[0] == "check" {
-		if len(args) != 2 {
-			sendUsage(ctx, b, msg.Chat.ID, "/deposit check", "Check the status of a pending deposit\n\n<b>Example:</b> /deposit check 3a8fb7")
-			return
-		}
-		checkDeposit(ctx, database, b, msg, args[1])
+		checkDeposit(ctx, database, b, msg, args[1:])
 		return
 	}
 
@@ -52,27 +41,9 @@ func DepositCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *
 		return
 	}
 
-	// Parse amount for new deposit
-	amount, err := strconv.ParseFloat(args[0], 64)
-	if err != nil || amount <= 0 {
-		sendError(ctx, b, msg.Chat.ID, "Please enter a valid positive amount")
-		return
-	}
-
-	// Convert to RAW
-	amountRaw := uint64(amount * constants.IVY_FACTOR)
-	userID := getDatabaseID(msg.From.ID)
-
-	database.EnsureUserExists(userID)
-
-	// Generate deposit ID
-	depositIDBytes := util.GenerateID(amountRaw)
-	depositID := hex.EncodeToString(depositIDBytes[:])
-
-	// Create deposit record
-	err = database.CreateDeposit(depositID, userID, amountRaw)
+	result, err := core.CreateDeposit(database, newRequest(msg, args))
 	if err != nil {
-		sendError(ctx, b, msg.Chat.ID, "Error creating deposit")
+		sendCoreError(ctx, b, msg.Chat.ID, err, "/deposit", DEPOSIT_USAGE)
 		return
 	}
 
@@ -81,13 +52,8 @@ func DepositCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *
 	if username == "" {
 		username = msg.From.FirstName
 	}
-
-	depositURL := fmt.Sprintf(
-		"https://sprite.ivypowered.com/deposit?deposit_id=%s&user_id=%s&name=%s",
-		depositID,
-		userID,
-		url.QueryEscape(username),
-	)
+	depositURL := core.DepositURL(result.DepositID, getDatabaseID(msg.From.ID), username)
+	amount := float64(result.AmountRaw) / constants.IVY_FACTOR
 
 	// Send success message
 	text := fmt.Sprintf(`⤵ <b>Deposit Created</b>
@@ -97,13 +63,14 @@ func DepositCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *
 
 📋 <b>Instructions:</b>
 1. Click the link below to complete your deposit
-2. After sending, use /deposit check %s to verify
+2. Your balance is credited automatically once it arrives
+3. Didn't get a message? Use /deposit check %s to verify
 
 🔗 <b>Deposit Link:</b>
 %s`,
 		amount,
-		depositID[:8]+"...",
-		depositID[:6],
+		result.DepositID[:8]+"...",
+		result.DepositID[:6],
 		depositURL)
 
 	isDisabled := true
@@ -117,68 +84,31 @@ func DepositCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *
 	})
 }
 
-func checkDeposit(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, depositIDPrefix string) {
-	userID := getDatabaseID(msg.From.ID)
-
-	// Find matching deposit
-	fullDepositID, amountRaw, completed, err := database.FindDepositByPrefix(userID, depositIDPrefix)
-
-	if err == sql.ErrNoRows {
-		sendError(ctx, b, msg.Chat.ID, "No deposit found with that ID")
-		return
-	} else if err != nil {
-		sendError(ctx, b, msg.Chat.ID, fmt.Sprintf("Error checking deposit: %v", err))
-		return
-	}
-
-	if completed == 1 {
-		sendSuccess(ctx, b, msg.Chat.ID, "This deposit has already been completed!", "✅ Deposit Already Processed")
-		return
-	}
-
-	// Decode deposit ID
-	depositIDBytes, err := hex.DecodeString(fullDepositID)
-	if err != nil || len(depositIDBytes) != 32 {
-		sendError(ctx, b, msg.Chat.ID, "Invalid deposit ID format")
-		return
-	}
-	var depositID32 [32]byte
-	copy(depositID32[:], depositIDBytes)
-
-	// Check if deposit is complete on-chain
-	isComplete, err := util.IsDepositComplete(constants.RPC_CLIENT, constants.SPRITE_VAULT, depositID32)
+func checkDeposit(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	result, err := core.CheckDeposit(database, newRequest(msg, args))
 	if err != nil {
-		sendError(ctx, b, msg.Chat.ID, fmt.Sprintf("Error checking deposit status: %v", err))
+		sendCoreError(ctx, b, msg.Chat.ID, err, "/deposit check", "Check the status of a pending deposit\n\n<b>Example:</b> /deposit check 3a8fb7")
 		return
 	}
 
-	if !isComplete {
-		sendClock(ctx, b, msg.Chat.ID, "Deposit Incomplete", fmt.Sprintf("Deposit <code>%s...</code> is not yet complete. Please try again later!", fullDepositID[:8]))
-		return
-	}
-
-	// Complete the deposit
-	err = database.CompleteDeposit(fullDepositID)
-	if err != nil {
-		sendError(ctx, b, msg.Chat.ID, "Error completing deposit")
-		return
+	switch result.Status {
+	case core.DEPOSIT_ALREADY_COMPLETE:
+		sendSuccess(ctx, b, msg.Chat.ID, "This deposit has already been completed!", "✅ Deposit Already Processed")
+	case core.DEPOSIT_PENDING:
+		sendClock(ctx, b, msg.Chat.ID, "Deposit Incomplete", fmt.Sprintf("Deposit <code>%s...</code> is not yet complete. Please try again later!", result.DepositID[:8]))
+	case core.DEPOSIT_COMPLETED:
+		newBalance := float64(result.BalanceRaw) / constants.IVY_FACTOR
+		amount := float64(result.AmountRaw) / constants.IVY_FACTOR
+		sendSuccess(ctx, b, msg.Chat.ID,
+			fmt.Sprintf("Deposited <b>%.9f IVY</b>\nNew balance: <b>%.9f IVY</b>", amount, newBalance),
+			"✅ Deposit Complete")
 	}
-
-	// Get new balance
-	newBalanceRaw, _ := database.GetUserBalanceRaw(userID)
-	newBalance := float64(newBalanceRaw) / constants.IVY_FACTOR
-	amount := float64(amountRaw) / constants.IVY_FACTOR
-
-	sendSuccess(ctx, b, msg.Chat.ID,
-		fmt.Sprintf("Deposited <b>%.9f IVY</b>\nNew balance: <b>%.9f IVY</b>", amount, newBalance),
-		"✅ Deposit Complete")
 }
 
 func listDeposits(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message) {
-	userID := getDatabaseID(msg.From.ID)
-	deposits, err := database.ListDeposits(userID, 10)
+	deposits, err := core.ListDeposits(database, newRequest(msg, nil))
 	if err != nil {
-		sendError(ctx, b, msg.Chat.ID, "Error fetching deposits")
+		sendCoreError(ctx, b, msg.Chat.ID, err, "/deposit", DEPOSIT_USAGE)
 		return
 	}
 
@@ -190,6 +120,7 @@ func listDeposits(ctx context.Context, database db.Database, b *bot.Bot, msg *mo
 	var text strings.Builder
 	text.WriteString("📋 <b>Recent Deposits</b>\n\n")
 
+	userID := getDatabaseID(msg.From.ID)
 	username := msg.From.Username
 	if username == "" {
 		username = msg.From.FirstName
@@ -202,12 +133,7 @@ func listDeposits(ctx context.Context, database db.Database, b *bot.Bot, msg *mo
 			status = "✅ Complete"
 		}
 
-		depositURL := fmt.Sprintf(
-			"https://sprite.ivypowered.com/deposit?deposit_id=%s&user_id=%s&name=%s",
-			deposit.DepositID,
-			userID,
-			url.QueryEscape(username),
-		)
+		depositURL := core.DepositURL(deposit.DepositID, userID, username)
 
 		text.WriteString(fmt.Sprintf("%d. %s <b>%.9f IVY</b>\n", i+1, status, amount))
 		text.WriteString(fmt.Sprintf("   ID: <code>%s</code>\n", deposit.DepositID[:8]+"..."))
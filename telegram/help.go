@@ -14,6 +14,12 @@ func HelpCommand(ctx context.Context, b *bot.Bot, msg *models.Message) {
 💰 <b>Balance</b>
 • /balance - Check your current balance
 
+📊 <b>Price</b>
+• /price [1h|24h|7d] - Show the IVY price and a chart of it
+• /alert above|below [price] [rearm] - Get a message when the price crosses a level
+• /alert list - List your alerts
+• /alert remove [id] - Remove an alert
+
 📥 <b>Deposit</b>
 • /deposit [amount] - Create a new deposit
 • /deposit check [id] - Check deposit status
@@ -22,14 +28,36 @@ func HelpCommand(ctx context.Context, b *bot.Bot, msg *models.Message) {
 📤 <b>Withdraw</b> <i>(Private chat only)</i>
 • /withdraw [amount] [address] - Withdraw coins
 • /withdraw list - List recent withdrawals
+• /withdraw cancel [id] - Cancel an unclaimed withdrawal
 
 💸 <b>Tip</b>
-• Reply to a message with /tip [amount] - Send coins to user
+• /tip @username [amount] - Send coins to user
+• Reply to a message with /tip [amount] - Send coins to its sender
+
+🔒 <b>Vest</b>
+• /vest @username [amount] over 30d [cliff 7d] - Send coins that unlock gradually
 
-🌧 <b>Rain</b> <i>(Ivy channel only)</i>
+🌧 <b>Rain</b> <i>(Registered groups only)</i>
 • /rain [amount] - Rain coins on active users
 • /rain [amount] max=[users] - Rain on limited users
-• /rain check - Check eligible users (DM only)
+• /rain [amount] mode=weighted - Split by activity score
+• /rain check - Check eligible users
+• /register - Enable rain in a group (Group admins only)
+
+🎟️ <b>Raffle</b> <i>(Registered groups only)</i>
+• /raffle start [prize] [duration] - Raffle a prize to one random entrant
+• /raffle join - Enter this group's raffle
+• /raffle verify [id] - Check a raffle's draw
+
+💰 <b>Bounty</b>
+• /bounty create [amount] [description] - Offer a reward for a task
+• /bounty award [id] @username - Pay a bounty
+• /bounty cancel [id] - Cancel a bounty and get the reward back
+• /bounty list - List open bounties
+
+📜 <b>History</b> <i>(Private chat only)</i>
+• /history [page] - Show your transaction history
+• /history kind=tip,rain from=2025-01-01 to=2025-01-31 - Filter by kind and date
 
 ℹ️ <b>Help</b>
 • /help - Show this help message
@@ -43,6 +71,12 @@ func HelpCommand(ctx context.Context, b *bot.Bot, msg *models.Message) {
 📄 <b>Submit</b>
 • /submit [link] - Submit link to Discord game jam
 
+🛡 <b>Admin</b>
+• /admin list - List roles in this group
+• Reply with /admin grant [role] - Grant a role
+• Reply with /admin revoke - Revoke a role
+• /config rain - Show or change this group's rain rules
+
 📝 <b>Examples:</b>
 • /deposit 10.5
 • /withdraw 5.0 YourSolanaAddress
@@ -0,0 +1,92 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const HISTORY_USAGE = `Show every movement of your balance: tips, rains, moves, deposits and withdrawals.
+
+<b>Usage:</b>
+• /history [page] - Show a page of transactions
+• /history kind=tip,rain - Only show some kinds
+• /history from=2025-01-01 to=2025-01-31 - Only show a date range
+
+<b>Kinds:</b> tip, rain, move, raffle, bounty, vesting, deposit, withdrawal, refund, clawback, admin, opening`
+
+func HistoryCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	// Check if it's a private chat
+	if msg.Chat.Type != "private" {
+		sendError(ctx, b, msg.Chat.ID, "History can only be viewed in private chat.")
+		return
+	}
+
+	result, err := core.History(database, newRequest(msg, args))
+	if err != nil {
+		sendCoreError(ctx, b, msg.Chat.ID, err, "/history", HISTORY_USAGE)
+		return
+	}
+
+	if len(result.Entries) == 0 {
+		sendInfo(ctx, b, msg.Chat.ID, "📜 Transaction History", "No transactions found")
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString("📜 <b>Transaction History</b>\n\n")
+
+	for _, e := range result.Entries {
+		arrow := "⬇️"
+		if e.AmountRaw < 0 {
+			arrow = "⬆️"
+		}
+		amount := float64(e.AmountRaw) / constants.IVY_FACTOR
+		text.WriteString(fmt.Sprintf("%s <b>%+.9f IVY</b> • %s\n", arrow, amount, e.Kind))
+		if party := formatCounterparty(e); party != "" {
+			text.WriteString(fmt.Sprintf("   %s\n", party))
+		}
+		if e.PriceUSD > 0 {
+			if amount < 0 {
+				amount = -amount
+			}
+			text.WriteString(fmt.Sprintf("   ≈ $%.2f\n", amount*e.PriceUSD))
+		}
+		text.WriteString(fmt.Sprintf("   %s\n\n", time.Unix(e.Timestamp, 0).UTC().Format("2006-01-02 15:04 UTC")))
+	}
+
+	text.WriteString(fmt.Sprintf("<i>Page %d/%d • %d transactions</i>", result.Page, result.Pages, result.Total))
+
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    msg.Chat.ID,
+		Text:      text.String(),
+		ParseMode: models.ParseModeHTML,
+	})
+}
+
+func formatCounterparty(e db.LedgerEntry) string {
+	if e.Counterparty == "" {
+		return ""
+	}
+	switch e.Kind {
+	case db.LEDGER_DEPOSIT, db.LEDGER_WITHDRAWAL, db.LEDGER_REFUND, db.LEDGER_CLAWBACK:
+		// Counterparty is the deposit or withdrawal ID
+		return fmt.Sprintf("ID: <code>%s...</code>", e.Counterparty[:min(8, len(e.Counterparty))])
+	case db.LEDGER_RAFFLE:
+		return fmt.Sprintf("Raffle #%s", e.Counterparty)
+	case db.LEDGER_BOUNTY:
+		return fmt.Sprintf("Bounty #%s", e.Counterparty)
+	}
+	direction := "From"
+	if e.AmountRaw < 0 {
+		direction = "To"
+	}
+	return fmt.Sprintf("%s: %s", direction, formatUser(e.Counterparty))
+}
@@ -0,0 +1,58 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const PRICE_USAGE = `Show the IVY price, how much it moved and a chart of it
+
+<b>Usage:</b>
+• /price - Over the last day
+• /price [1h|24h|7d] - Over the last hour, day or week`
+
+func PriceCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	result, err := core.Price(newRequest(msg, args))
+	if err != nil {
+		sendCoreError(ctx, b, msg.Chat.ID, err, "/price", PRICE_USAGE)
+		return
+	}
+
+	text := fmt.Sprintf("📊 <b>IVY Price</b>\n\n🌿 <b>$%.6f</b> per IVY", result.Latest.PriceUSD)
+	if result.HasChange {
+		arrow := "🔼"
+		if result.ChangePercent < 0 {
+			arrow = "🔽"
+		}
+		text += fmt.Sprintf("\n%s <b>%+.2f%%</b> over %s", arrow, result.ChangePercent, result.Window)
+	}
+	text += fmt.Sprintf("\n\n<i>Median of %d price sources, updated %s</i>",
+		result.Latest.Sources, time.Unix(result.Latest.Timestamp, 0).UTC().Format("2006-01-02 15:04 UTC"))
+	if result.Stale {
+		text += "\n⚠️ This price is out of date"
+	}
+
+	if result.Chart == nil {
+		text += "\n<i>Not enough history for a chart yet</i>"
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    msg.Chat.ID,
+			Text:      text,
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	b.SendPhoto(ctx, &bot.SendPhotoParams{
+		ChatID:    msg.Chat.ID,
+		Photo:     &models.InputFileUpload{Filename: "ivy-price.png", Data: bytes.NewReader(result.Chart)},
+		Caption:   text,
+		ParseMode: models.ParseModeHTML,
+	})
+}
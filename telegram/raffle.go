@@ -0,0 +1,173 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const RAFFLE_USAGE = `Give away a prize to one random entrant in a registered group.
+
+<b>Usage:</b>
+• /raffle start [prize] [duration] - Start a raffle, e.g. /raffle start 10 24h
+• /raffle join - Enter this group's raffle, or press Join on it
+• /raffle verify [id] - Show everything needed to check a raffle's draw
+
+The prize is taken from your balance when the raffle starts. A hash of the secret seed is published at the start and the seed itself at the draw, so anyone can check the winner wasn't picked by hand.`
+
+// Callback data prefix of the Join button on raffle announcements
+const RAFFLE_JOIN_PREFIX = "raffle:"
+
+// Most entrants listed by /raffle verify
+const RAFFLE_VERIFY_MAX_ENTRANTS = 100
+
+func RaffleCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	if len(args) < 1 {
+		sendUsage(ctx, b, msg.Chat.ID, "/raffle", RAFFLE_USAGE)
+		return
+	}
+
+	if args[0] == "verify" {
+		draw, err := core.VerifyRaffle(database, newRequest(msg, args[1:]))
+		if err != nil {
+			sendCoreError(ctx, b, msg.Chat.ID, err, "/raffle verify [id]", RAFFLE_USAGE)
+			return
+		}
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    msg.Chat.ID,
+			Text:      raffleVerifyText(draw),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	// Raffles only run in groups that opted in
+	if msg.Chat.Type != "private" {
+		registered, err := database.IsTelegramChatRegistered(getDatabaseID(msg.Chat.ID))
+		if err != nil {
+			sendError(ctx, b, msg.Chat.ID, "Error checking group registration")
+			return
+		}
+		if !registered {
+			sendError(ctx, b, msg.Chat.ID, "Raffles aren't enabled in this group. A group admin can enable them with /register")
+			return
+		}
+	}
+
+	switch args[0] {
+	case "start":
+		runRaffleStart(ctx, database, b, msg, newRequest(msg, args[1:]))
+	case "join":
+		raffle, err := core.JoinRaffle(database, newRequest(msg, args[1:]))
+		if err != nil {
+			sendCoreError(ctx, b, msg.Chat.ID, err, "/raffle join", RAFFLE_USAGE)
+			return
+		}
+		sendSuccess(ctx, b, msg.Chat.ID,
+			fmt.Sprintf("%s is in raffle #%d. Good luck!", escapeHTML(displayName(msg.From)), raffle.RaffleID),
+			"🎟️ <b>Raffle Joined</b>")
+	default:
+		sendUsage(ctx, b, msg.Chat.ID, "/raffle", RAFFLE_USAGE)
+	}
+}
+
+func runRaffleStart(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, req core.Request) {
+	raffle, err := core.StartRaffle(database, req, req.ChatID)
+	if err != nil {
+		sendActionError(ctx, database, b, msg, err, "/raffle start [prize] [duration]", RAFFLE_USAGE)
+		return
+	}
+
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: msg.Chat.ID,
+		Text: fmt.Sprintf(`🎟️ <b>Raffle #%d</b>
+
+%s is raffling <b>%.9f IVY</b>!
+
+Press Join or send /raffle join to enter. The winner is drawn in %s.
+
+<b>Seed hash:</b> <code>%s</code>
+
+<i>The seed is revealed at the draw. Check it with /raffle verify %d</i>`,
+			raffle.RaffleID,
+			escapeHTML(displayName(msg.From)),
+			float64(raffle.PrizeRaw)/constants.IVY_FACTOR,
+			time.Until(time.Unix(raffle.EndsAt, 0)).Round(time.Minute),
+			raffle.SeedHash,
+			raffle.RaffleID,
+		),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{{
+				{Text: "🎟️ Join", CallbackData: RAFFLE_JOIN_PREFIX + strconv.FormatInt(raffle.RaffleID, 10)},
+			}},
+		},
+	})
+}
+
+// handleRaffleJoin enters whoever pressed Join on a raffle announcement
+func handleRaffleJoin(ctx context.Context, database db.Database, b *bot.Bot, query *models.CallbackQuery) {
+	raffleID, err := strconv.ParseInt(strings.TrimPrefix(query.Data, RAFFLE_JOIN_PREFIX), 10, 64)
+	if err != nil {
+		return
+	}
+
+	text := ""
+	raffle, err := core.JoinRaffleByID(database, getDatabaseID(query.From.ID), raffleID)
+	if err != nil {
+		text = err.Error()
+	} else {
+		text = fmt.Sprintf("You're in raffle #%d for %.9f IVY. Good luck!", raffle.RaffleID, float64(raffle.PrizeRaw)/constants.IVY_FACTOR)
+	}
+	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+		Text:            text,
+		ShowAlert:       true,
+	})
+}
+
+// raffleVerifyText shows a raffle's commitment, and once drawn, its seed
+// and how the winner follows from it
+func raffleVerifyText(draw core.RaffleDraw) string {
+	raffle := draw.Raffle
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("🎟️ <b>Raffle #%d</b>\n\n", raffle.RaffleID))
+	text.WriteString(fmt.Sprintf("<b>Status:</b> %s\n", raffle.Status))
+	text.WriteString(fmt.Sprintf("<b>Prize:</b> %.9f IVY\n", float64(raffle.PrizeRaw)/constants.IVY_FACTOR))
+	text.WriteString(fmt.Sprintf("<b>Seed hash:</b> <code>%s</code>\n", raffle.SeedHash))
+	if raffle.Seed == "" {
+		text.WriteString("\n<i>The seed is revealed once the raffle is drawn.</i>\n")
+	} else {
+		text.WriteString(fmt.Sprintf("<b>Seed:</b> <code>%s</code>\n", raffle.Seed))
+	}
+	if raffle.WinnerID != "" {
+		text.WriteString(fmt.Sprintf("<b>Winner:</b> %s (entrant %d)\n",
+			formatUser(raffle.WinnerID), core.RaffleWinnerIndex(raffle.Seed, draw.Entrants)))
+	}
+
+	entrants := draw.Entrants
+	more := ""
+	if len(entrants) > RAFFLE_VERIFY_MAX_ENTRANTS {
+		more = fmt.Sprintf("\n...and %d more", len(entrants)-RAFFLE_VERIFY_MAX_ENTRANTS)
+		entrants = entrants[:RAFFLE_VERIFY_MAX_ENTRANTS]
+	}
+	text.WriteString(fmt.Sprintf("\n<b>Entrants (%d, sorted):</b>\n", len(draw.Entrants)))
+	if len(entrants) == 0 {
+		text.WriteString("None\n")
+	} else {
+		text.WriteString(fmt.Sprintf("<code>%s</code>%s\n", escapeHTML(strings.Join(entrants, ",")), more))
+	}
+
+	if raffle.Seed != "" {
+		text.WriteString("\n<i>SHA-256 of the seed must equal the seed hash. The winner is entrant number SHA-256(seed + \":\" + entrants joined by \",\") mod entrant count, counting from 0.</i>")
+	}
+	return text.String()
+}
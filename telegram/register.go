@@ -0,0 +1,35 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const REGISTER_USAGE = `Enable rain in this group. Members earn activity by chatting, and /rain shares IVY between the active ones.
+
+Requires group admin or the admin role.`
+
+func RegisterCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message) {
+	err := core.RegisterChat(database, newAdminRequest(ctx, b, msg, nil), msg.Chat.Title)
+	if err != nil {
+		sendCoreError(ctx, b, msg.Chat.ID, err, "/register", REGISTER_USAGE)
+		return
+	}
+	sendSuccess(ctx, b, msg.Chat.ID,
+		fmt.Sprintf("Rain is now enabled in this group. Chat to build up activity, then use /rain!\n\nGroup ID: <code>%s</code>", getDatabaseID(msg.Chat.ID)),
+		"✅ <b>Group Registered</b>")
+}
+
+func UnregisterCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message) {
+	err := core.UnregisterChat(database, newAdminRequest(ctx, b, msg, nil))
+	if err != nil {
+		sendCoreError(ctx, b, msg.Chat.ID, err, "/unregister", "Disable rain in this group. Requires group admin or the admin role.")
+		return
+	}
+	sendSuccess(ctx, b, msg.Chat.ID, "Rain is now disabled in this group.", "✅ <b>Group Unregistered</b>")
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 	"github.com/ivypowered/ivy-sprite-bot/db"
 )
 
@@ -19,13 +20,27 @@ type CommandFunc func(
 	args []string,
 )
 
-func Start(database db.Database, token string, submitC chan<- string) (func() error, error) {
+func Start(database db.Database, token string, submitC chan<- string, notifyC <-chan core.Notification) (func() error, error) {
 	if token == "" {
 		return nil, errors.New("no token passed to telegram.Start")
 	}
 
+	// Move activity kept under the shared "telegram" ID to the Ivy channel
+	err := database.MigrateLegacyTelegramChat(getDatabaseID(IVY_TELEGRAM_CHANNEL_ID), "Ivy")
+	if err != nil {
+		return nil, fmt.Errorf("Error migrating legacy Telegram chat: %v", err)
+	}
+
 	// Handler function
 	handler := func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		if update.CallbackQuery != nil {
+			if strings.HasPrefix(update.CallbackQuery.Data, RAFFLE_JOIN_PREFIX) {
+				handleRaffleJoin(ctx, database, b, update.CallbackQuery)
+			} else {
+				handleConfirmation(ctx, database, b, update.CallbackQuery)
+			}
+			return
+		}
 		if update.Message == nil {
 			return
 		}
@@ -40,15 +55,27 @@ func Start(database db.Database, token string, submitC chan<- string) (func() er
 			return
 		}
 
+		// Keep track of who holds which username, for /tip @username
+		learnUsernames(database, msg)
+
 		text := strings.TrimSpace(msg.Text)
 
 		// Parse command
 		if !strings.HasPrefix(text, "/") {
-			// not IVY server?
-			if msg.Chat.ID != IVY_TELEGRAM_CHANNEL_ID {
+			// Only track activity in registered groups
+			if msg.Chat.Type == "private" {
+				return
+			}
+			chatID := getDatabaseID(msg.Chat.ID)
+			registered, err := database.IsTelegramChatRegistered(chatID)
+			if err != nil {
+				log.Printf("error checking TG chat registration: %v\n", err)
+				return
+			}
+			if !registered {
 				return
 			}
-			err := database.UpdateActivityScore("telegram", getDatabaseID(msg.From.ID))
+			err = database.UpdateActivityScore(chatID, getDatabaseID(msg.From.ID))
 			if err != nil {
 				log.Printf("error updating TG activity score: %v\n", err)
 			}
@@ -79,10 +106,30 @@ func Start(database db.Database, token string, submitC chan<- string) (func() er
 			DepositCommand(ctx, database, b, msg, args)
 		case "withdraw":
 			WithdrawCommand(ctx, database, b, msg, args)
+		case "price":
+			PriceCommand(ctx, database, b, msg, args)
+		case "alert":
+			AlertCommand(ctx, database, b, msg, args)
 		case "tip":
 			TipCommand(ctx, database, b, msg, args)
+		case "vest":
+			VestCommand(ctx, database, b, msg, args)
 		case "rain":
 			RainCommand(ctx, database, b, msg, args)
+		case "raffle":
+			RaffleCommand(ctx, database, b, msg, args)
+		case "bounty":
+			BountyCommand(ctx, database, b, msg, args)
+		case "history":
+			HistoryCommand(ctx, database, b, msg, args)
+		case "admin":
+			AdminCommand(ctx, database, b, msg, args)
+		case "config":
+			ConfigCommand(ctx, database, b, msg, args)
+		case "register":
+			RegisterCommand(ctx, database, b, msg)
+		case "unregister":
+			UnregisterCommand(ctx, database, b, msg)
 		case "submit":
 			SubmitCommand(ctx, b, msg, args, submitC)
 		default:
@@ -105,12 +152,22 @@ func Start(database db.Database, token string, submitC chan<- string) (func() er
 			{Command: "balance", Description: "Check your Ivy balance"},
 			{Command: "deposit", Description: "Deposit Ivy tokens (Private chat only)"},
 			{Command: "withdraw", Description: "Withdraw Ivy tokens (Private chat only)"},
+			{Command: "price", Description: "Show the Ivy price and a chart of it"},
+			{Command: "alert", Description: "Get a message when the Ivy price crosses a level"},
 			{Command: "tip", Description: "Tip Ivy tokens to another user"},
-			{Command: "rain", Description: "Rain Ivy tokens on active users"},
+			{Command: "vest", Description: "Send Ivy tokens that unlock gradually"},
+			{Command: "rain", Description: "Rain Ivy tokens on active users in this group"},
+			{Command: "raffle", Description: "Raffle Ivy tokens to one random entrant in this group"},
+			{Command: "bounty", Description: "Offer Ivy tokens for a task and pay whoever does it"},
+			{Command: "history", Description: "Show your transaction history (Private chat only)"},
 			{Command: "id", Description: "See your Ivy Sprite ID"},
 			{Command: "help", Description: "Show available commands"},
 			{Command: "move", Description: "Move funds to Discord (Private chat only)"},
 			{Command: "submit", Description: "Submit game to Discord game jam"},
+			{Command: "admin", Description: "Manage bot admins and moderators"},
+			{Command: "config", Description: "Show or change this group's rain rules"},
+			{Command: "register", Description: "Enable rain in this group (Group admins only)"},
+			{Command: "unregister", Description: "Disable rain in this group (Group admins only)"},
 		},
 	})
 	if err != nil {
@@ -121,6 +178,24 @@ func Start(database db.Database, token string, submitC chan<- string) (func() er
 	ctx, cancelFn := context.WithCancel(context.Background())
 	go b.Start(ctx)
 
+	// Deliver notifications
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case notification, ok := <-notifyC:
+				if !ok {
+					// closed
+					return
+				}
+				if err := sendNotification(ctx, b, notification); err != nil {
+					log.Printf("can't notify %s: %v\n", notification.UserID, err)
+				}
+			}
+		}
+	}()
+
 	log.Println("Telegram bot started successfully")
 
 	// Return close function
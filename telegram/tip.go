@@ -3,90 +3,167 @@ package telegram
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf16"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 	"github.com/ivypowered/ivy-sprite-bot/db"
-	"github.com/ivypowered/ivy-sprite-bot/util"
 )
 
-func TipCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
-	if len(args) < 1 || msg.ReplyToMessage == nil || msg.ReplyToMessage.From == nil {
-		sendUsage(ctx, b, msg.Chat.ID, "/tip", `Send coins to another user
+const TIP_USAGE = `Send coins to other users
 
 <b>Usage:</b>
+• /tip @username [amount]
 • Reply to a message with /tip [amount]
+• /tip @user1 @user2 [amount] each - Send everyone the amount
+• /tip @user1 @user2 [amount] split - Divide the amount between them
 
 <b>Examples:</b>
-• /tip 10
+• /tip @alice 10
 • /tip $5
+• /tip @alice @bob 5 each
 
 <b>Note:</b>
-• Due to Telegram API limitations, it is not possible to support username mentions`)
+• I only know usernames of people I've seen send a message`
+
+// withoutTextMentions returns a message's text with the names of users
+// mentioned without a username blanked out, leaving the other arguments
+func withoutTextMentions(msg *models.Message) string {
+	// Entity offsets count UTF-16 code units
+	text := utf16.Encode([]rune(msg.Text))
+	for _, entity := range msg.Entities {
+		if entity.Type != models.MessageEntityTypeTextMention || entity.User == nil {
+			continue
+		}
+		for i := entity.Offset; i < entity.Offset+entity.Length && i < len(text); i++ {
+			text[i] = ' '
+		}
+	}
+	return string(utf16.Decode(text))
+}
+
+func TipCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	if len(args) < 1 {
+		sendUsage(ctx, b, msg.Chat.ID, "/tip", TIP_USAGE)
 		return
 	}
 
+	// Tip via mentions of users without a username, whose names may span
+	// several words, so they're taken out of the arguments first
+	var recipients []*models.User
+	for _, entity := range msg.Entities {
+		if entity.Type == models.MessageEntityTypeTextMention && entity.User != nil {
+			recipients = append(recipients, entity.User)
+		}
+	}
+	args = strings.Fields(withoutTextMentions(msg))[1:]
+
+	// Then via @username: mentions come first, then the amount and maybe
+	// how to share it
+	for len(args) > 0 && strings.HasPrefix(args[0], "@") {
+		userID, err := core.ResolveTelegramUsername(database, args[0])
+		if err != nil {
+			sendError(ctx, b, msg.Chat.ID, err.Error())
+			return
+		}
+		tgID, _ := fromDatabaseID(userID)
+		recipients = append(recipients, &models.User{ID: tgID, Username: strings.TrimPrefix(args[0], "@")})
+		args = args[1:]
+	}
+
 	// Tip via reply
-	recipientID := getDatabaseID(msg.ReplyToMessage.From.ID)
-	recipientName := msg.ReplyToMessage.From.FirstName
-	if msg.ReplyToMessage.From.Username != "" {
-		recipientName = "@" + msg.ReplyToMessage.From.Username
+	if len(recipients) == 0 && msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil {
+		recipients = append(recipients, msg.ReplyToMessage.From)
+	}
+	if len(recipients) == 0 || len(args) == 0 || len(args) > 2 {
+		sendUsage(ctx, b, msg.Chat.ID, "/tip", TIP_USAGE)
+		return
+	}
+	if len(args) == 2 && args[1] != core.TIP_EACH && args[1] != core.TIP_SPLIT {
+		sendUsage(ctx, b, msg.Chat.ID, "/tip", TIP_USAGE)
+		return
 	}
 
-	// Get amount
-	amount, err := util.ParseAmount(args[0])
+	req := newRequest(msg, args)
+	for _, recipient := range recipients {
+		req.Mentions = append(req.Mentions, getDatabaseID(recipient.ID))
+	}
+	runTip(ctx, database, b, msg, req, recipients)
+}
 
-	// Validate amount
-	if err != nil || amount <= 0 {
-		sendError(ctx, b, msg.Chat.ID, "Please enter a valid positive amount")
+// runTip tips the users in req.Mentions, given in the same order as recipients
+func runTip(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, req core.Request, recipients []*models.User) {
+	if len(req.Mentions) > 1 || len(req.Args) > 1 {
+		runTipMany(ctx, database, b, msg, req, recipients)
 		return
 	}
 
-	// Convert to RAW
-	amountRaw := uint64(amount * constants.IVY_FACTOR)
-	senderID := getDatabaseID(msg.From.ID)
-
-	// Don't allow tipping yourself
-	if senderID == recipientID {
-		sendError(ctx, b, msg.Chat.ID, "You cannot tip yourself!")
+	result, err := core.Tip(database, req)
+	if err != nil {
+		sendActionError(ctx, database, b, msg, err, "/tip", TIP_USAGE)
 		return
 	}
 
-	// Ensure both users exist in database
-	database.EnsureUserExists(senderID)
-	database.EnsureUserExists(recipientID)
+	amount := float64(result.AmountRaw) / constants.IVY_FACTOR
+	senderName := displayName(msg.From)
+	recipient := recipients[0]
 
-	// Check sender's balance
-	senderBalanceRaw, err := database.GetUserBalanceRaw(senderID)
+	// Send brief public acknowledgment (reply to the tip message)
+	sendTipAck(ctx, b, msg, fmt.Sprintf("🌿 %s tipped %.9f IVY to %s", escapeHTML(senderName), amount, escapeHTML(displayName(recipient))))
+
+	// Send notification to recipient via DM
+	recipientBalance := float64(result.RecipientBalanceRaw) / constants.IVY_FACTOR
+	sendTipReceived(ctx, b, recipient.ID, senderName, amount, recipientBalance)
+}
+
+func runTipMany(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, req core.Request, recipients []*models.User) {
+	result, err := core.TipMany(database, req)
 	if err != nil {
-		sendError(ctx, b, msg.Chat.ID, "Error checking balance")
+		sendActionError(ctx, database, b, msg, err, "/tip", TIP_USAGE)
 		return
 	}
 
-	if senderBalanceRaw < amountRaw {
-		senderBalance := float64(senderBalanceRaw) / constants.IVY_FACTOR
-		sendError(ctx, b, msg.Chat.ID, fmt.Sprintf("Insufficient balance. Your balance: <b>%.9f</b> IVY", senderBalance))
-		return
+	amountPerUser := float64(result.AmountPerUserRaw) / constants.IVY_FACTOR
+	amountMax := float64(slices.Max(result.RecipientAmountsRaw)) / constants.IVY_FACTOR
+	senderName := displayName(msg.From)
+
+	// core drops repeated mentions, keep the users that were tipped
+	tipped := make(map[string]*models.User)
+	for _, recipient := range recipients {
+		tipped[getDatabaseID(recipient.ID)] = recipient
+	}
+	names := make([]string, len(result.Recipients))
+	for i, recipientID := range result.Recipients {
+		names[i] = escapeHTML(displayName(tipped[recipientID]))
 	}
 
-	// Perform transfer
-	err = database.TransferFundsRaw(senderID, recipientID, amountRaw)
-	if err != nil {
-		sendError(ctx, b, msg.Chat.ID, fmt.Sprintf("Error processing transfer: %v", err))
-		return
+	// A split that doesn't divide evenly gives some recipients one RAW more
+	each := fmt.Sprintf("%.9f IVY", amountPerUser)
+	if amountMax != amountPerUser {
+		each = fmt.Sprintf("%.9f to %.9f IVY", amountPerUser, amountMax)
 	}
 
-	// Get sender name for notifications
-	senderName := msg.From.FirstName
-	if msg.From.Username != "" {
-		senderName = "@" + msg.From.Username
+	// Send a single public summary (reply to the tip message)
+	sendTipAck(ctx, b, msg, fmt.Sprintf("🌿 %s tipped %s each to %s (%.9f IVY total)",
+		escapeHTML(senderName), each, strings.Join(names, ", "), float64(result.AmountRaw)/constants.IVY_FACTOR))
+
+	// Send notification to each recipient via DM
+	for i, recipientID := range result.Recipients {
+		recipientAmount := float64(result.RecipientAmountsRaw[i]) / constants.IVY_FACTOR
+		recipientBalance := float64(result.RecipientBalancesRaw[i]) / constants.IVY_FACTOR
+		sendTipReceived(ctx, b, tipped[recipientID].ID, senderName, recipientAmount, recipientBalance)
 	}
+}
 
-	// Send brief public acknowledgment (reply to the tip message)
-	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+// sendTipAck replies to a tip command in its chat
+func sendTipAck(ctx context.Context, b *bot.Bot, msg *models.Message, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:    msg.Chat.ID,
-		Text:      fmt.Sprintf("🌿 %s tipped %.9f IVY to %s", escapeHTML(senderName), amount, escapeHTML(recipientName)),
+		Text:      text,
 		ParseMode: models.ParseModeHTML,
 		ReplyParameters: &models.ReplyParameters{
 			MessageID: msg.ID,
@@ -96,23 +173,22 @@ func TipCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *mode
 		// Fallback to non-reply if reply fails
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:    msg.Chat.ID,
-			Text:      fmt.Sprintf("🌿 %s tipped %.9f IVY to %s", escapeHTML(senderName), amount, escapeHTML(recipientName)),
+			Text:      text,
 			ParseMode: models.ParseModeHTML,
 		})
 	}
+}
 
-	// Send notification to recipient via DM
-	recipientBalanceRaw, _ := database.GetUserBalanceRaw(recipientID)
-	recipientBalance := float64(recipientBalanceRaw) / constants.IVY_FACTOR
-
+// sendTipReceived tells a recipient about a tip via DM
+func sendTipReceived(ctx context.Context, b *bot.Bot, recipientID int64, senderName string, amount float64, balance float64) {
 	b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: msg.ReplyToMessage.From.ID,
+		ChatID: recipientID,
 		Text: fmt.Sprintf(`<b>You received a tip!</b>
 
 %s sent you <b>%.9f IVY</b>
 
 🌿 Your new balance: <b>%.9f IVY</b>`,
-			escapeHTML(senderName), amount, recipientBalance),
+			escapeHTML(senderName), amount, balance),
 		ParseMode: models.ParseModeHTML,
 	})
 }
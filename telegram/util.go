@@ -4,13 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
 )
 
+// The Ivy channel, which rain was limited to before groups could register.
+// Its legacy activity and settings are migrated to it on startup.
 const IVY_TELEGRAM_CHANNEL_ID int64 = -1002894078752
 
 // Convert tg id -> database id
@@ -26,6 +33,57 @@ func fromDatabaseID(dbId string) (int64, error) {
 	return strconv.ParseInt(dbId[3:], 10, 64)
 }
 
+// How often an unchanged username is recorded again, so it doesn't go stale
+const USERNAME_REFRESH_INTERVAL = time.Hour
+
+// seenUsername is the username last recorded for a user, and when
+type seenUsername struct {
+	username string
+	at       time.Time
+}
+
+// Usernames learnUsernames recorded, by database ID, so it doesn't write
+// to the database for every message
+var (
+	seenUsernamesMu sync.Mutex
+	seenUsernames   = make(map[string]seenUsername)
+)
+
+// learnUsernames records the current username of everyone a message shows
+func learnUsernames(database db.Database, msg *models.Message) {
+	users := []*models.User{msg.From}
+	if msg.ReplyToMessage != nil {
+		users = append(users, msg.ReplyToMessage.From)
+	}
+	for _, entity := range msg.Entities {
+		users = append(users, entity.User)
+	}
+	for _, user := range users {
+		if user == nil || user.IsBot {
+			continue
+		}
+		userID := getDatabaseID(user.ID)
+		now := time.Now()
+
+		seenUsernamesMu.Lock()
+		seen, ok := seenUsernames[userID]
+		if ok && seen.username == user.Username && now.Sub(seen.at) < USERNAME_REFRESH_INTERVAL {
+			seenUsernamesMu.Unlock()
+			continue
+		}
+		seenUsernames[userID] = seenUsername{username: user.Username, at: now}
+		seenUsernamesMu.Unlock()
+
+		if err := database.SeeTelegramUsername(userID, user.Username); err != nil {
+			log.Printf("error recording TG username: %v\n", err)
+			// Try again next time
+			seenUsernamesMu.Lock()
+			delete(seenUsernames, userID)
+			seenUsernamesMu.Unlock()
+		}
+	}
+}
+
 // Helper functions for consistent message formatting
 
 func sendError(ctx context.Context, b *bot.Bot, chatID int64, message string) {
@@ -80,3 +138,78 @@ func escapeHTML(text string) string {
 	text = strings.ReplaceAll(text, ">", "&gt;")
 	return text
 }
+
+// newRequest builds a core request from a message
+func newRequest(msg *models.Message, args []string) core.Request {
+	private := msg.Chat.Type == "private"
+	chatID := ""
+	if !private {
+		chatID = getDatabaseID(msg.Chat.ID)
+	}
+	return core.Request{
+		CallerID: getDatabaseID(msg.From.ID),
+		ChatID:   chatID,
+		Private:  private,
+		Args:     args,
+	}
+}
+
+// sendCoreError reports an error returned by core, showing usage if needed
+func sendCoreError(ctx context.Context, b *bot.Bot, chatID int64, err error, command string, usage string) {
+	switch {
+	case errors.Is(err, core.ErrUsage):
+		sendUsage(ctx, b, chatID, command, usage)
+	case errors.Is(err, core.ErrPrivateOnly):
+		sendError(ctx, b, chatID, "This command must be used in private chat for security.")
+	case errors.Is(err, core.ErrGroupOnly):
+		sendError(ctx, b, chatID, "This command can only be used in a group")
+	default:
+		sendError(ctx, b, chatID, err.Error())
+	}
+}
+
+// formatUser renders a database ID for display
+func formatUser(userID string) string {
+	if core.IsTelegramID(userID) {
+		return fmt.Sprintf("<code>%s</code>", escapeHTML(userID))
+	}
+	return fmt.Sprintf("Discord user <code>%s</code>", escapeHTML(userID))
+}
+
+// displayName returns @username if the user has one, otherwise their first name
+func displayName(user *models.User) string {
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	return user.FirstName
+}
+
+// mentionHTML links to a user by ID, which notifies them even without a username
+func mentionHTML(user *models.User) string {
+	return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>`, user.ID, escapeHTML(displayName(user)))
+}
+
+// sendNotification delivers a core notification to a Telegram user, or to its group
+func sendNotification(ctx context.Context, b *bot.Bot, n core.Notification) error {
+	recipient := n.UserID
+	if n.ChannelID != "" {
+		recipient = n.ChannelID
+	}
+	chatID, err := fromDatabaseID(recipient)
+	if err != nil {
+		return err
+	}
+	message := escapeHTML(n.Message)
+	if n.ChannelID != "" && n.Mention != "" {
+		message = mentionHTML(lookupUser(ctx, b, chatID, n.Mention)) + "\n\n" + message
+	}
+	switch n.Kind {
+	case core.NOTIFY_SUCCESS:
+		sendSuccess(ctx, b, chatID, message, "✅ <b>"+escapeHTML(n.Title)+"</b>")
+	case core.NOTIFY_CLOCK:
+		sendClock(ctx, b, chatID, n.Title, message)
+	case core.NOTIFY_ERROR:
+		sendError(ctx, b, chatID, n.Message)
+	}
+	return nil
+}
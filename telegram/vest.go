@@ -0,0 +1,107 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+const VEST_USAGE = `Send IVY that unlocks gradually, like a grant or a team allocation
+
+<b>Usage:</b>
+• /vest @username [amount] over [duration] cliff [duration]
+• Reply to a message with /vest [amount] over [duration]
+
+<b>Examples:</b>
+• /vest @alice 1000 over 30d - Unlock 1000 IVY evenly over 30 days
+• /vest @alice 1000 over 365d cliff 90d - Unlock nothing for 90 days, then catch up and keep unlocking until the year is up
+
+The cliff is optional. The amount leaves your balance right away, and the recipient sees what's still locked in /balance.`
+
+func VestCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	// The recipient is a mention of a user without a username, an
+	// @username, or the sender of the message replied to
+	var recipient *models.User
+	for _, entity := range msg.Entities {
+		if entity.Type == models.MessageEntityTypeTextMention && entity.User != nil {
+			recipient = entity.User
+		}
+	}
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "@") {
+			continue
+		}
+		userID, err := core.ResolveTelegramUsername(database, arg)
+		if err != nil {
+			sendError(ctx, b, msg.Chat.ID, err.Error())
+			return
+		}
+		tgID, _ := fromDatabaseID(userID)
+		recipient = &models.User{ID: tgID, Username: strings.TrimPrefix(arg, "@")}
+	}
+	if recipient == nil && msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil {
+		recipient = msg.ReplyToMessage.From
+	}
+
+	// Whatever names the recipient comes before the amount, which comes
+	// right before "over"
+	over := -1
+	for i, arg := range args {
+		if strings.EqualFold(arg, "over") {
+			over = i
+			break
+		}
+	}
+	if recipient == nil || over < 1 {
+		sendUsage(ctx, b, msg.Chat.ID, "/vest", VEST_USAGE)
+		return
+	}
+	if recipient.IsBot {
+		sendError(ctx, b, msg.Chat.ID, "You can't vest to a bot")
+		return
+	}
+
+	req := newRequest(msg, args[over-1:])
+	req.Mentions = []string{getDatabaseID(recipient.ID)}
+	runVest(ctx, database, b, msg, req)
+}
+
+func runVest(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, req core.Request) {
+	vesting, err := core.Vest(database, req)
+	if err != nil {
+		sendActionError(ctx, database, b, msg, err, "/vest", VEST_USAGE)
+		return
+	}
+
+	amount := float64(vesting.AmountRaw) / constants.IVY_FACTOR
+	recipient := lookupUser(ctx, b, msg.Chat.ID, vesting.RecipientID)
+	schedule := fmt.Sprintf("Unlocks gradually until %s", formatVestingTime(vesting.EndAt))
+	if vesting.CliffAt > vesting.StartAt {
+		schedule = fmt.Sprintf("Nothing unlocks until %s, then it unlocks gradually until %s",
+			formatVestingTime(vesting.CliffAt), formatVestingTime(vesting.EndAt))
+	}
+
+	sendSuccess(ctx, b, msg.Chat.ID,
+		fmt.Sprintf("%s is vesting <b>%.9f IVY</b> to %s\n\n%s",
+			escapeHTML(displayName(msg.From)), amount, escapeHTML(displayName(recipient)), schedule),
+		fmt.Sprintf("🔒 <b>Vesting #%d</b>", vesting.VestingID))
+
+	// Let the recipient know in private
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: recipient.ID,
+		Text: fmt.Sprintf("🔒 <b>Incoming Vesting</b>\n\n%s is vesting <b>%.9f IVY</b> to you\n\n%s. Check what's still locked with /balance.",
+			escapeHTML(displayName(msg.From)), amount, schedule),
+		ParseMode: models.ParseModeHTML,
+	})
+}
+
+func formatVestingTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format("2006-01-02 15:04 UTC")
+}
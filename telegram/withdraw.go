@@ -2,103 +2,50 @@ package telegram
 
 import (
 	"context"
-	"encoding/hex"
 	"fmt"
-	"net/url"
-	"strconv"
 	"strings"
 
-	"github.com/gagliardetto/solana-go"
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
 	"github.com/ivypowered/ivy-sprite-bot/db"
-	"github.com/ivypowered/ivy-sprite-bot/util"
 )
 
-func getWithdrawUrl(withdrawId, userId, userName, signature string) string {
-	return fmt.Sprintf(
-		"https://sprite.ivypowered.com/withdraw?withdraw_id=%s&user_id=%s&name=%s&authority=%s&signature=%s",
-		withdrawId,
-		userId,
-		url.QueryEscape(userName),
-		constants.WITHDRAW_AUTHORITY_B58,
-		signature,
-	)
-}
-
-func WithdrawCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
-	// Check if it's a private chat
-	if msg.Chat.Type != "private" {
-		sendError(ctx, b, msg.Chat.ID, "Withdrawals can only be processed in private chat for security. Please send this command directly to me.")
-		return
-	}
-
-	if len(args) == 0 || (args[0] != "list" && len(args) != 2) {
-		sendUsage(ctx, b, msg.Chat.ID, "/withdraw", `Withdraw coins from your account
+const WITHDRAW_USAGE = `Withdraw coins from your account
 
 <b>Usage:</b>
 • /withdraw [amount] [sol_address] - Create withdrawal
 • /withdraw list - List recent withdrawals
+• /withdraw cancel [id] - Cancel an unclaimed withdrawal and refund it
 
-<b>Example:</b>
-• /withdraw 0.5 A32dqo7aTp3eHhxpSA6Cw67zWosKc3ymiYz2DbPVx8BK`)
-		return
-	}
+<b>Examples:</b>
+• /withdraw 0.5 A32dqo7aTp3eHhxpSA6Cw67zWosKc3ymiYz2DbPVx8BK
+• /withdraw cancel 3a8fb7`
 
-	if args[0] == "list" {
+func WithdrawCommand(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	if len(args) > 0 && args[0] == "list" {
 		listWithdrawals(ctx, database, b, msg)
 		return
 	}
 
-	amount, err := strconv.ParseFloat(args[0], 64)
-	if err != nil || amount <= 0 {
-		sendError(ctx, b, msg.Chat.ID, "Please enter a valid positive amount")
+	if len(args) > 0 && args[0] == "cancel" {
+		cancelWithdrawal(ctx, database, b, msg, args[1:])
 		return
 	}
 
-	// Convert to RAW
-	amountRaw := uint64(amount * constants.IVY_FACTOR)
-
-	userKey, err := solana.PublicKeyFromBase58(args[1])
-	if err != nil {
-		sendError(ctx, b, msg.Chat.ID, "Please enter a valid base58-encoded Solana address")
-		return
-	}
-
-	userID := getDatabaseID(msg.From.ID)
-	database.EnsureUserExists(userID)
-
-	balanceRaw, err := database.GetUserBalanceRaw(userID)
-	if err != nil {
-		sendError(ctx, b, msg.Chat.ID, "Error checking balance")
-		return
-	}
-
-	if balanceRaw < amountRaw {
-		balance := float64(balanceRaw) / constants.IVY_FACTOR
-		sendError(ctx, b, msg.Chat.ID, fmt.Sprintf("Insufficient balance. Your balance: <b>%.9f</b> IVY", balance))
-		return
-	}
-
-	// Generate withdrawal ID
-	withdrawIDBytes := util.GenerateID(amountRaw)
-	withdrawID := hex.EncodeToString(withdrawIDBytes[:])
-
-	// Sign withdrawal
-	signature := util.SignWithdrawal(constants.SPRITE_VAULT, userKey, withdrawIDBytes, constants.WITHDRAW_AUTHORITY_KEY)
-	signatureHex := hex.EncodeToString(signature[:])
+	runWithdraw(ctx, database, b, msg, newRequest(msg, args))
+}
 
-	// Create withdrawal and debit user atomically
-	err = database.CreateWithdrawal(withdrawID, userID, balanceRaw, amountRaw, signatureHex)
+func runWithdraw(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, req core.Request) {
+	result, err := core.CreateWithdrawal(database, req)
 	if err != nil {
-		sendError(ctx, b, msg.Chat.ID, fmt.Sprintf("Error processing withdrawal: %v", err))
+		sendActionError(ctx, database, b, msg, err, "/withdraw", WITHDRAW_USAGE)
 		return
 	}
 
-	// Get new balance
-	newBalanceRaw, _ := database.GetUserBalanceRaw(userID)
-	newBalance := float64(newBalanceRaw) / constants.IVY_FACTOR
+	amount := float64(result.AmountRaw) / constants.IVY_FACTOR
+	newBalance := float64(result.BalanceRaw) / constants.IVY_FACTOR
 
 	// Get user info
 	username := msg.From.Username
@@ -107,11 +54,11 @@ func WithdrawCommand(ctx context.Context, database db.Database, b *bot.Bot, msg
 	}
 
 	// Create withdrawal URL
-	withdrawURL := getWithdrawUrl(
-		withdrawID,
-		userID,
+	withdrawURL := core.WithdrawURL(
+		result.WithdrawID,
+		getDatabaseID(msg.From.ID),
 		username,
-		signatureHex,
+		result.Signature,
 	)
 
 	// Send success message
@@ -127,7 +74,7 @@ func WithdrawCommand(ctx context.Context, database db.Database, b *bot.Bot, msg
 ⚡ Click the link above to claim your withdrawal`,
 		amount,
 		newBalance,
-		withdrawID[:8]+"...",
+		result.WithdrawID[:8]+"...",
 		withdrawURL)
 
 	isDisabled := true
@@ -141,11 +88,25 @@ func WithdrawCommand(ctx context.Context, database db.Database, b *bot.Bot, msg
 	})
 }
 
+func cancelWithdrawal(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message, args []string) {
+	result, err := core.CancelWithdrawal(database, newRequest(msg, args))
+	if err != nil {
+		sendCoreError(ctx, b, msg.Chat.ID, err, "/withdraw cancel", "Cancel an unclaimed withdrawal and refund it\n\n<b>Example:</b> /withdraw cancel 3a8fb7")
+		return
+	}
+
+	sendSuccess(ctx, b, msg.Chat.ID,
+		fmt.Sprintf("Refunded <b>%.9f IVY</b> from withdrawal <code>%s...</code>\nNew balance: <b>%.9f IVY</b>\n\nIts claim link is now void. If it is used anyway, the refund will be taken back.",
+			float64(result.AmountRaw)/constants.IVY_FACTOR,
+			result.WithdrawID[:8],
+			float64(result.BalanceRaw)/constants.IVY_FACTOR),
+		"✅ <b>Withdrawal Cancelled</b>")
+}
+
 func listWithdrawals(ctx context.Context, database db.Database, b *bot.Bot, msg *models.Message) {
-	userID := getDatabaseID(msg.From.ID)
-	withdrawals, err := database.ListWithdrawals(userID, 10)
+	withdrawals, err := core.ListWithdrawals(database, newRequest(msg, nil))
 	if err != nil {
-		sendError(ctx, b, msg.Chat.ID, "Error fetching withdrawals")
+		sendCoreError(ctx, b, msg.Chat.ID, err, "/withdraw", WITHDRAW_USAGE)
 		return
 	}
 
@@ -157,6 +118,7 @@ func listWithdrawals(ctx context.Context, database db.Database, b *bot.Bot, msg
 	var text strings.Builder
 	text.WriteString("📤 <b>Recent Withdrawals</b>\n\n")
 
+	userID := getDatabaseID(msg.From.ID)
 	username := msg.From.Username
 	if username == "" {
 		username = msg.From.FirstName
@@ -165,16 +127,24 @@ func listWithdrawals(ctx context.Context, database db.Database, b *bot.Bot, msg
 	for i, withdrawal := range withdrawals {
 		amount := float64(withdrawal.AmountRaw) / constants.IVY_FACTOR
 
-		withdrawURL := getWithdrawUrl(
-			withdrawal.WithdrawID,
-			userID,
-			username,
-			withdrawal.Signature,
-		)
-
 		text.WriteString(fmt.Sprintf("%d. <b>%.9f IVY</b>\n", i+1, amount))
 		text.WriteString(fmt.Sprintf("   ID: <code>%s</code>\n", withdrawal.WithdrawID[:8]+"..."))
-		text.WriteString(fmt.Sprintf("   <a href=\"%s\">Claim Link</a>\n\n", withdrawURL))
+		switch withdrawal.Status {
+		case db.WITHDRAWAL_CLAIMED:
+			text.WriteString("   ✅ Claimed\n\n")
+		case db.WITHDRAWAL_REVOKING:
+			text.WriteString("   ⏳ Cancelling\n\n")
+		case db.WITHDRAWAL_REVOKED:
+			text.WriteString("   🚫 Cancelled and refunded\n\n")
+		default:
+			withdrawURL := core.WithdrawURL(
+				withdrawal.WithdrawID,
+				userID,
+				username,
+				withdrawal.Signature,
+			)
+			text.WriteString(fmt.Sprintf("   ⏳ Pending • <a href=\"%s\">Claim Link</a>\n\n", withdrawURL))
+		}
 	}
 
 	isDisabled := true
@@ -5,10 +5,12 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
-	"strconv"
+	"math"
+	"math/big"
 	"strings"
 	"time"
 
@@ -78,13 +80,33 @@ const VAULT_WITHDRAW_PREFIX string = "vault_withdraw"
 
 var IVY_PROGRAM_ID solana.PublicKey = solana.MustPublicKeyFromBase58("DkGdbW8SJmUoVE9KaBRwrvsQVhcuidy47DimjrhSoySE")
 
-// Check whether a deposit is complete or not
-func IsDepositComplete(r *rpc.Client, vault [32]byte, id [32]byte) (bool, error) {
+// Maximum number of accounts per GetMultipleAccounts call
+const MAX_MULTIPLE_ACCOUNTS = 100
+
+// Decode a hex deposit/withdraw ID
+func DecodeID(id string) ([32]byte, error) {
+	var id32 [32]byte
+	bytes, err := hex.DecodeString(id)
+	if err != nil || len(bytes) != 32 {
+		return id32, errors.New("invalid ID format")
+	}
+	copy(id32[:], bytes)
+	return id32, nil
+}
+
+// Derive the deposit account for a deposit ID
+func DepositAddress(vault [32]byte, id [32]byte) (solana.PublicKey, error) {
 	deposit, _, err := solana.FindProgramAddress([][]byte{
 		[]byte(VAULT_DEPOSIT_PREFIX),
 		vault[:],
 		id[:],
 	}, IVY_PROGRAM_ID)
+	return deposit, err
+}
+
+// Check whether a deposit is complete or not
+func IsDepositComplete(r *rpc.Client, vault [32]byte, id [32]byte) (bool, error) {
+	deposit, err := DepositAddress(vault, id)
 	if err != nil {
 		return false, err
 	}
@@ -98,32 +120,150 @@ func IsDepositComplete(r *rpc.Client, vault [32]byte, id [32]byte) (bool, error)
 	return info.Value != nil && info.Value.Lamports > 0, nil
 }
 
-// parse an amount string and convert it to IVY
-func ParseAmount(amount string) (float64, error) {
+// Derive the withdraw account for a withdraw ID, created when it is claimed
+func WithdrawAddress(vault [32]byte, id [32]byte) (solana.PublicKey, error) {
+	withdraw, _, err := solana.FindProgramAddress([][]byte{
+		[]byte(VAULT_WITHDRAW_PREFIX),
+		vault[:],
+		id[:],
+	}, IVY_PROGRAM_ID)
+	return withdraw, err
+}
+
+// Check whether many withdrawals have been claimed, batching RPC calls
+func AreWithdrawalsClaimed(ctx context.Context, r *rpc.Client, vault [32]byte, ids [][32]byte) ([]bool, error) {
+	addresses := make([]solana.PublicKey, len(ids))
+	for i, id := range ids {
+		var err error
+		addresses[i], err = WithdrawAddress(vault, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return accountsExist(ctx, r, addresses)
+}
+
+// Check whether many deposits are complete, batching RPC calls
+func AreDepositsComplete(ctx context.Context, r *rpc.Client, vault [32]byte, ids [][32]byte) ([]bool, error) {
+	addresses := make([]solana.PublicKey, len(ids))
+	for i, id := range ids {
+		var err error
+		addresses[i], err = DepositAddress(vault, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return accountsExist(ctx, r, addresses)
+}
+
+// Check which of the given accounts exist and hold lamports
+func accountsExist(ctx context.Context, r *rpc.Client, addresses []solana.PublicKey) ([]bool, error) {
+	exists := make([]bool, 0, len(addresses))
+	for start := 0; start < len(addresses); start += MAX_MULTIPLE_ACCOUNTS {
+		end := min(start+MAX_MULTIPLE_ACCOUNTS, len(addresses))
+		res, err := r.GetMultipleAccounts(ctx, addresses[start:end]...)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Value) != end-start {
+			return nil, errors.New("wrong number of accounts returned")
+		}
+		for _, account := range res.Value {
+			exists = append(exists, account != nil && account.Lamports > 0)
+		}
+	}
+	return exists, nil
+}
+
+// Number of decimal places in an IVY amount, i.e. log10(IVY_FACTOR)
+const IVY_DECIMALS = 9
+
+// Parse an amount string, either IVY ("1.5") or USD ("$1.50"), into RAW.
+// IVY amounts are parsed exactly; USD amounts are converted at the current
+// price and rounded down to the nearest RAW unit, so nobody ever sends more
+// than the dollar value they typed.
+func ParseAmount(amount string) (uint64, error) {
+	amountRaw, err := parseAmount(amount)
+	if err != nil {
+		return 0, err
+	}
+	// Balances are stored as signed 64-bit integers
+	if amountRaw > math.MaxInt64 {
+		return 0, errors.New("amount too large")
+	}
+	return amountRaw, nil
+}
+
+func parseAmount(amount string) (uint64, error) {
 	amount = strings.TrimSpace(amount)
 	if len(amount) == 0 {
-		return 0.0, nil
+		return 0, errors.New("empty amount")
 	}
 
-	var x float64
 	if amount[0] == '$' {
-		usd, err := strconv.ParseFloat(amount[1:], 64)
+		usdNano, err := ParseDecimal(amount[1:], IVY_DECIMALS)
 		if err != nil {
-			return 0.0, err
+			return 0, err
 		}
-		x = usd / constants.PRICE.Get(constants.RPC_CLIENT)
-	} else {
-		var err error
-		x, err = strconv.ParseFloat(amount, 64)
+		usd := new(big.Rat).SetFrac(
+			new(big.Int).SetUint64(usdNano),
+			big.NewInt(constants.IVY_FACTOR),
+		)
+		price, err := constants.PRICE.Get()
 		if err != nil {
-			return 0.0, err
+			return 0, err
 		}
+		return usdRatToRaw(usd, price)
 	}
-	if x < 0 {
-		return 0.0, errors.New("no negative amounts allowed")
+
+	return ParseDecimal(amount, IVY_DECIMALS)
+}
+
+// Convert a USD value (such as a configured minimum) into RAW at the given price,
+// rounding down to the nearest RAW unit
+func USDToRaw(usd float64, price float64) (uint64, error) {
+	if math.IsNaN(usd) || math.IsInf(usd, 0) || usd < 0 {
+		return 0, errors.New("invalid USD amount")
 	}
-	if x != x {
-		return 0.0, errors.New("no NaNs allowed")
+	return usdRatToRaw(new(big.Rat).SetFloat64(usd), price)
+}
+
+func usdRatToRaw(usd *big.Rat, price float64) (uint64, error) {
+	if math.IsNaN(price) || math.IsInf(price, 0) || price <= 0 {
+		return 0, errors.New("IVY price is unavailable, try again in a minute")
+	}
+	// raw = floor(usd / price * IVY_FACTOR)
+	ivy := new(big.Rat).Quo(usd, new(big.Rat).SetFloat64(price))
+	ivy.Mul(ivy, new(big.Rat).SetInt64(constants.IVY_FACTOR))
+	raw := new(big.Int).Quo(ivy.Num(), ivy.Denom())
+	if !raw.IsUint64() {
+		return 0, errors.New("amount too large")
+	}
+	return raw.Uint64(), nil
+}
+
+// Parse a non-negative decimal string such as "12.345" into an integer scaled
+// by 10^decimals, rejecting signs, exponents, Inf/NaN, excess precision and overflow
+func ParseDecimal(s string, decimals int) (uint64, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount: %q", s)
+	}
+	if len(frac) > decimals {
+		return 0, fmt.Errorf("at most %d decimal places are allowed", decimals)
+	}
+
+	var x uint64
+	digits := whole + frac + strings.Repeat("0", decimals-len(frac))
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid amount: %q", s)
+		}
+		// x = x*10 + digit, checking for overflow
+		if x > (math.MaxUint64-uint64(c-'0'))/10 {
+			return 0, errors.New("amount too large")
+		}
+		x = x*10 + uint64(c-'0')
 	}
 	return x, nil
 }
@@ -0,0 +1,147 @@
+package util
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/price"
+)
+
+// fixedSource always answers with the same price
+type fixedSource float64
+
+func (s fixedSource) Name() string { return "fixed" }
+
+func (s fixedSource) Fetch(ctx context.Context) (float64, error) {
+	return float64(s), nil
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"1", 1_000_000_000, false},
+		{"1.5", 1_500_000_000, false},
+		{".5", 500_000_000, false},
+		{"1.", 1_000_000_000, false},
+		{"0.000000001", 1, false},
+		{"18446744073.709551615", 18446744073709551615, false},
+		{"", 0, true},
+		{".", 0, true},
+		{"1e3", 0, true},
+		{"-1", 0, true},
+		{"+1", 0, true},
+		{"1.2.3", 0, true},
+		{"Inf", 0, true},
+		{"NaN", 0, true},
+		{"0.0000000001", 0, true},
+		{"18446744073.709551616", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDecimal(tt.in, IVY_DECIMALS)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDecimal(%q) = %d, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDecimal(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	// $1 buys 4 IVY
+	oracle := price.New(time.Hour, fixedSource(0.25))
+	if _, err := oracle.Update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	previous := constants.PRICE
+	constants.PRICE = oracle
+	defer func() { constants.PRICE = previous }()
+
+	tests := []struct {
+		in      string
+		want    uint64
+		wantErr bool
+	}{
+		{"1.5", 1_500_000_000, false},
+		{" 2 ", 2_000_000_000, false},
+		{"$1", 4_000_000_000, false},
+		{"$.5", 2_000_000_000, false},
+		{"$0.000000001", 4, false},
+		// Balances are signed, so MaxInt64 is the largest amount
+		{"9223372036.854775807", 9223372036854775807, false},
+		{"9223372036.854775808", 0, true},
+		{"$2305843009.213693952", 0, true},
+		{"", 0, true},
+		{"$", 0, true},
+		{"$-1", 0, true},
+		{"$0.0000000001", 0, true},
+		{"$1e3", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseAmount(%q) = %d, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAmount(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseAmountWithoutPrice(t *testing.T) {
+	previous := constants.PRICE
+	constants.PRICE = price.New(time.Hour)
+	defer func() { constants.PRICE = previous }()
+
+	if _, err := ParseAmount("$1"); err == nil {
+		t.Error("USD amount parsed without a price")
+	}
+	if got, err := ParseAmount("1"); err != nil || got != 1_000_000_000 {
+		t.Errorf("ParseAmount(\"1\") without a price = %d, %v", got, err)
+	}
+}
+
+func TestUSDRatToRaw(t *testing.T) {
+	tests := []struct {
+		usd     *big.Rat
+		price   float64
+		want    uint64
+		wantErr bool
+	}{
+		{big.NewRat(1, 1), 1, 1_000_000_000, false},
+		// 1/3 IVY is 333333333.33 RAW, rounded down
+		{big.NewRat(1, 1), 3, 333_333_333, false},
+		// 2/3 IVY is 666666666.67 RAW, still rounded down
+		{big.NewRat(2, 1), 3, 666_666_666, false},
+		{big.NewRat(1, 1_000_000_000_000), 1, 0, false},
+		{big.NewRat(0, 1), 1, 0, false},
+		{big.NewRat(1, 1), 0, 0, true},
+		{big.NewRat(1, 1), -1, 0, true},
+		{big.NewRat(1_000_000_000_000, 1), 1e-12, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := usdRatToRaw(tt.usd, tt.price)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("usdRatToRaw(%s, %v) = %d, want an error", tt.usd, tt.price, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("usdRatToRaw(%s, %v) = %d, %v, want %d", tt.usd, tt.price, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+	"github.com/ivypowered/ivy-sprite-bot/price"
+)
+
+// PriceAlerts returns a price listener that fires the alerts each new
+// sample reaches, letting their owners know
+func PriceAlerts(database db.Database, notifier core.Notifier) func(price.Sample) {
+	return func(sample price.Sample) {
+		fired, err := core.CheckPriceAlerts(database, sample)
+		if err != nil {
+			log.Printf("error checking price alerts: %v\n", err)
+		}
+		for _, alert := range fired {
+			message := fmt.Sprintf("IVY is now %s, %s your %s alert (#%d).",
+				core.FormatPriceUSD(sample.PriceUSD), alert.Direction, core.FormatPriceUSD(alert.TargetUSD), alert.AlertID)
+			if alert.Rearm {
+				message += " It fires again once the price has crossed back."
+			} else {
+				message += fmt.Sprintf(" It won't fire again, remove it with: alert remove %d", alert.AlertID)
+			}
+			notifier.Notify(core.Notification{
+				UserID:  alert.UserID,
+				Kind:    core.NOTIFY_SUCCESS,
+				Title:   fmt.Sprintf("IVY Price %s %s", alertVerb(alert.Direction), core.FormatPriceUSD(alert.TargetUSD)),
+				Message: message,
+			})
+		}
+	}
+}
+
+func alertVerb(direction db.AlertDirection) string {
+	if direction == db.ALERT_BELOW {
+		return "Below"
+	}
+	return "Above"
+}
@@ -0,0 +1,114 @@
+// Package worker runs the bot's background jobs, independently of any
+// chat platform. Results are reported to users through a core.Notifier.
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+	"github.com/ivypowered/ivy-sprite-bot/util"
+)
+
+// WatchDeposits polls pending deposits until ctx is cancelled, crediting the
+// ones that arrive on-chain and expiring the ones left unpaid for too long
+func WatchDeposits(ctx context.Context, database db.Database, notifier core.Notifier) {
+	ticker := time.NewTicker(constants.DEPOSIT_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		if err := pollDeposits(ctx, database, notifier); err != nil {
+			log.Printf("error polling deposits: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func pollDeposits(ctx context.Context, database db.Database, notifier core.Notifier) error {
+	deposits, err := database.ListPendingDeposits()
+	if err != nil {
+		return err
+	}
+	if len(deposits) == 0 {
+		return nil
+	}
+
+	// Skip any deposit whose ID is malformed rather than failing the batch
+	var valid []db.Deposit
+	var ids [][32]byte
+	for _, d := range deposits {
+		id, err := util.DecodeID(d.DepositID)
+		if err != nil {
+			log.Printf("skipping deposit %s: %v\n", d.DepositID, err)
+			continue
+		}
+		valid = append(valid, d)
+		ids = append(ids, id)
+	}
+
+	complete, err := util.AreDepositsComplete(ctx, constants.RPC_CLIENT, constants.SPRITE_VAULT, ids)
+	if err != nil {
+		return err
+	}
+
+	expiry := time.Now().Add(-constants.DEPOSIT_EXPIRY).Unix()
+	for i, d := range valid {
+		switch {
+		case complete[i]:
+			completeDeposit(database, notifier, d)
+		case d.Timestamp < expiry:
+			expireDeposit(database, notifier, d)
+		}
+	}
+	return nil
+}
+
+func completeDeposit(database db.Database, notifier core.Notifier, d db.Deposit) {
+	err := database.CompleteDeposit(d.DepositID)
+	if err != nil {
+		// Most likely completed by a manual check in the meantime
+		log.Printf("can't complete deposit %s: %v\n", d.DepositID, err)
+		return
+	}
+
+	balanceRaw, _ := database.GetUserBalanceRaw(d.UserID)
+	notifier.Notify(core.Notification{
+		UserID: d.UserID,
+		Kind:   core.NOTIFY_SUCCESS,
+		Title:  "Deposit Complete",
+		Message: fmt.Sprintf(
+			"Deposited %.9f IVY\nNew balance: %.9f IVY",
+			float64(d.AmountRaw)/constants.IVY_FACTOR,
+			float64(balanceRaw)/constants.IVY_FACTOR,
+		),
+	})
+}
+
+func expireDeposit(database db.Database, notifier core.Notifier, d db.Deposit) {
+	if err := database.ExpireDeposit(d.DepositID); err != nil {
+		log.Printf("can't expire deposit %s: %v\n", d.DepositID, err)
+		return
+	}
+
+	notifier.Notify(core.Notification{
+		UserID: d.UserID,
+		Kind:   core.NOTIFY_CLOCK,
+		Title:  "Deposit Expired",
+		Message: fmt.Sprintf(
+			"Deposit %s... for %.9f IVY wasn't received within %v and is no longer being watched. "+
+				"If you already sent it, run deposit check %s to credit it.",
+			d.DepositID[:8],
+			float64(d.AmountRaw)/constants.IVY_FACTOR,
+			constants.DEPOSIT_EXPIRY,
+			d.DepositID[:6],
+		),
+	})
+}
@@ -0,0 +1,41 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+	"github.com/ivypowered/ivy-sprite-bot/price"
+)
+
+// SamplePrices updates oracle from its sources until ctx is cancelled,
+// dropping samples older than PRICE_HISTORY_RETENTION as it goes
+func SamplePrices(ctx context.Context, database db.Database, oracle *price.Oracle) {
+	ticker := time.NewTicker(constants.PRICE_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		samplePrice(ctx, database, oracle)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func samplePrice(ctx context.Context, database db.Database, oracle *price.Oracle) {
+	// Don't let a hung source hold up the next sample
+	ctx, cancel := context.WithTimeout(ctx, constants.PRICE_POLL_INTERVAL)
+	defer cancel()
+
+	if _, err := oracle.Update(ctx); err != nil {
+		log.Printf("error sampling price: %v\n", err)
+	}
+	before := time.Now().Add(-constants.PRICE_HISTORY_RETENTION).Unix()
+	if _, err := database.PrunePriceSamples(before); err != nil {
+		log.Printf("error pruning price samples: %v\n", err)
+	}
+}
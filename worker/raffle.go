@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// DrawRaffles draws raffles as they end until ctx is cancelled, announcing
+// each result and the revealed seed where the raffle was started
+func DrawRaffles(ctx context.Context, database db.Database, notifier core.Notifier) {
+	ticker := time.NewTicker(constants.RAFFLE_DRAW_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		if err := pollRaffles(database, notifier); err != nil {
+			log.Printf("error polling raffles: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func pollRaffles(database db.Database, notifier core.Notifier) error {
+	raffles, err := database.ListDueRaffles(time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	for _, raffle := range raffles {
+		draw, err := core.DrawRaffle(database, raffle)
+		if errors.Is(err, core.ErrRaffleEnded) {
+			// Drawn by someone else in the meantime
+			continue
+		}
+		if err != nil {
+			log.Printf("can't draw raffle %d: %v\n", raffle.RaffleID, err)
+			continue
+		}
+		announceRaffle(notifier, draw)
+	}
+	return nil
+}
+
+func announceRaffle(notifier core.Notifier, draw core.RaffleDraw) {
+	raffle := draw.Raffle
+	prize := float64(raffle.PrizeRaw) / constants.IVY_FACTOR
+
+	if raffle.WinnerID == "" {
+		notifier.Notify(core.Notification{
+			UserID:    raffle.OwnerID,
+			ChannelID: raffle.ChannelID,
+			Kind:      core.NOTIFY_CLOCK,
+			Title:     fmt.Sprintf("Raffle #%d Ended", raffle.RaffleID),
+			Message:   fmt.Sprintf("Nobody entered, so the %.9f IVY prize went back to its owner.", prize),
+		})
+		notifier.Notify(core.Notification{
+			UserID:  raffle.OwnerID,
+			Kind:    core.NOTIFY_CLOCK,
+			Title:   "Raffle Refunded",
+			Message: fmt.Sprintf("Nobody entered raffle #%d, so its %.9f IVY prize was returned to your balance.", raffle.RaffleID, prize),
+		})
+		return
+	}
+
+	notifier.Notify(core.Notification{
+		UserID:    raffle.OwnerID,
+		ChannelID: raffle.ChannelID,
+		Mention:   raffle.WinnerID,
+		Kind:      core.NOTIFY_SUCCESS,
+		Title:     fmt.Sprintf("🎟️ Raffle #%d Winner", raffle.RaffleID),
+		Message: fmt.Sprintf(
+			"Won %.9f IVY out of %d entrants!\n\nSeed: %s\nSeed hash: %s\n\nAnyone can check the draw with: raffle verify %d",
+			prize, len(draw.Entrants), raffle.Seed, raffle.SeedHash, raffle.RaffleID,
+		),
+	})
+	notifier.Notify(core.Notification{
+		UserID:  raffle.WinnerID,
+		Kind:    core.NOTIFY_SUCCESS,
+		Title:   "You Won a Raffle!",
+		Message: fmt.Sprintf("You won %.9f IVY in raffle #%d! It has been added to your balance.", prize, raffle.RaffleID),
+	})
+	notifier.Notify(core.Notification{
+		UserID:  raffle.OwnerID,
+		Kind:    core.NOTIFY_SUCCESS,
+		Title:   "Raffle Drawn",
+		Message: fmt.Sprintf("Raffle #%d was drawn among %d entrants and its %.9f IVY prize paid out.", raffle.RaffleID, len(draw.Entrants), prize),
+	})
+}
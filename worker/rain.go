@@ -0,0 +1,132 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// RunScheduledRains fires due scheduled rains until ctx is cancelled. Each
+// run is claimed in the database before it happens, so a restart never
+// repeats one.
+func RunScheduledRains(ctx context.Context, database db.Database, notifier core.Notifier) {
+	ticker := time.NewTicker(constants.RAIN_SCHEDULE_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		if err := pollScheduledRains(database, notifier); err != nil {
+			log.Printf("error polling scheduled rains: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func pollScheduledRains(database db.Database, notifier core.Notifier) error {
+	now := time.Now().Unix()
+	rains, err := database.ListDueScheduledRains(now)
+	if err != nil {
+		return err
+	}
+
+	for _, rain := range rains {
+		ok, err := database.ClaimScheduledRain(rain.ScheduleID, rain.NextRun, core.NextScheduledRun(rain, now))
+		if err != nil {
+			log.Printf("can't claim scheduled rain %d: %v\n", rain.ScheduleID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		runScheduledRain(database, notifier, rain)
+	}
+	return nil
+}
+
+func runScheduledRain(database db.Database, notifier core.Notifier, rain db.ScheduledRain) {
+	result, err := core.RunScheduledRain(database, rain)
+
+	var insufficient *core.InsufficientBalanceError
+	if errors.As(err, &insufficient) {
+		if err := database.PauseScheduledRain(rain.ScheduleID); err != nil {
+			log.Printf("can't pause scheduled rain %d: %v\n", rain.ScheduleID, err)
+		}
+		notifier.Notify(core.Notification{
+			UserID: rain.OwnerID,
+			Kind:   core.NOTIFY_ERROR,
+			Message: fmt.Sprintf(
+				"Scheduled rain #%d of %.9f IVY is paused: your balance is only %.9f IVY. Top up, then resume it with `$rain schedule resume %d`",
+				rain.ScheduleID,
+				float64(rain.AmountRaw)/constants.IVY_FACTOR,
+				float64(insufficient.BalanceRaw)/constants.IVY_FACTOR,
+				rain.ScheduleID,
+			),
+		})
+		return
+	}
+	var confirm *core.ConfirmationRequiredError
+	if errors.As(err, &confirm) && confirm.Unpriced {
+		err = errors.New("there's no fresh IVY price to value it with")
+	} else if errors.As(err, &confirm) {
+		// Nobody is there to confirm the run, so wait for the owner
+		if err := database.PauseScheduledRain(rain.ScheduleID); err != nil {
+			log.Printf("can't pause scheduled rain %d: %v\n", rain.ScheduleID, err)
+		}
+		notifier.Notify(core.Notification{
+			UserID: rain.OwnerID,
+			Kind:   core.NOTIFY_ERROR,
+			Message: fmt.Sprintf(
+				"Scheduled rain #%d of %.9f IVY is paused: it's now worth enough to need confirming. Cancel it with `$rain schedule cancel %d` and schedule it again",
+				rain.ScheduleID,
+				float64(rain.AmountRaw)/constants.IVY_FACTOR,
+				rain.ScheduleID,
+			),
+		})
+		return
+	}
+	if err != nil {
+		notifier.Notify(core.Notification{
+			UserID:  rain.OwnerID,
+			Kind:    core.NOTIFY_CLOCK,
+			Title:   "Scheduled Rain Skipped",
+			Message: fmt.Sprintf("Scheduled rain #%d was skipped this time: %v", rain.ScheduleID, err),
+		})
+		return
+	}
+
+	amountPerUser := float64(result.AmountPerUserRaw) / constants.IVY_FACTOR
+	notifier.Notify(core.Notification{
+		UserID: rain.OwnerID,
+		Kind:   core.NOTIFY_SUCCESS,
+		Title:  "Scheduled Rain Sent",
+		Message: fmt.Sprintf(
+			"Scheduled rain #%d rained %.9f IVY on %d active users\n\nAmount per user: %.9f IVY\nYour new balance: %.9f IVY",
+			rain.ScheduleID,
+			float64(result.AmountRaw)/constants.IVY_FACTOR,
+			len(result.Recipients),
+			amountPerUser,
+			float64(result.SenderBalanceRaw)/constants.IVY_FACTOR,
+		),
+	})
+	for i, recipientID := range result.Recipients {
+		notifier.Notify(core.Notification{
+			UserID: recipientID,
+			Kind:   core.NOTIFY_SUCCESS,
+			Title:  "Rain Received",
+			Message: fmt.Sprintf(
+				"You received %.9f IVY from a scheduled rain!\n\nYour new balance: %.9f IVY",
+				float64(result.RecipientAmountsRaw[i])/constants.IVY_FACTOR,
+				float64(result.RecipientBalancesRaw[i])/constants.IVY_FACTOR,
+			),
+		})
+	}
+}
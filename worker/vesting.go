@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+)
+
+// ReleaseVestings credits recipients their vestings as they unlock until
+// ctx is cancelled, letting them know once a vesting is fully released
+func ReleaseVestings(ctx context.Context, database db.Database, notifier core.Notifier) {
+	ticker := time.NewTicker(constants.VESTING_RELEASE_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		if err := pollVestings(database, notifier); err != nil {
+			log.Printf("error polling vestings: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func pollVestings(database db.Database, notifier core.Notifier) error {
+	now := time.Now().Unix()
+	vestings, err := database.ListReleasableVestings(now)
+	if err != nil {
+		return err
+	}
+
+	for _, vesting := range vestings {
+		released, ok, err := core.ReleaseVesting(database, vesting, now)
+		if err != nil {
+			log.Printf("can't release vesting %d: %v\n", vesting.VestingID, err)
+			continue
+		}
+		if !ok || released.ReleasedRaw < released.AmountRaw {
+			continue
+		}
+		notifier.Notify(core.Notification{
+			UserID: released.RecipientID,
+			Kind:   core.NOTIFY_SUCCESS,
+			Title:  "Vesting Complete",
+			Message: fmt.Sprintf("All %.9f IVY of vesting #%d has unlocked and is now in your balance.",
+				float64(released.AmountRaw)/constants.IVY_FACTOR, released.VestingID),
+		})
+	}
+	return nil
+}
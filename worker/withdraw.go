@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ivypowered/ivy-sprite-bot/constants"
+	"github.com/ivypowered/ivy-sprite-bot/core"
+	"github.com/ivypowered/ivy-sprite-bot/db"
+	"github.com/ivypowered/ivy-sprite-bot/util"
+)
+
+// ReconcileWithdrawals polls unclaimed withdrawals until ctx is cancelled,
+// marking the ones whose voucher has been redeemed on-chain as claimed.
+// Cancelled vouchers that get redeemed anyway have their refund taken back.
+func ReconcileWithdrawals(ctx context.Context, database db.Database, notifier core.Notifier) {
+	ticker := time.NewTicker(constants.WITHDRAW_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		if err := pollWithdrawals(ctx, database, notifier); err != nil {
+			log.Printf("error polling withdrawals: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func pollWithdrawals(ctx context.Context, database db.Database, notifier core.Notifier) error {
+	var withdrawals []db.Withdrawal
+	for _, status := range []db.WithdrawalStatus{
+		db.WITHDRAWAL_PENDING,
+		db.WITHDRAWAL_REVOKING,
+		db.WITHDRAWAL_REVOKED,
+	} {
+		ws, err := database.ListWithdrawalsByStatus(status)
+		if err != nil {
+			return err
+		}
+		withdrawals = append(withdrawals, ws...)
+	}
+	if len(withdrawals) == 0 {
+		return nil
+	}
+
+	valid, ids := decodeWithdrawals(withdrawals)
+	claimed, err := util.AreWithdrawalsClaimed(ctx, constants.RPC_CLIENT, constants.SPRITE_VAULT, ids)
+	if err != nil {
+		return err
+	}
+
+	for i, w := range valid {
+		if !claimed[i] {
+			continue
+		}
+		if w.Status == db.WITHDRAWAL_REVOKED {
+			clawback(database, notifier, w)
+			continue
+		}
+		ok, err := database.SwapWithdrawalStatus(w.WithdrawID, w.Status, db.WITHDRAWAL_CLAIMED)
+		if err != nil {
+			log.Printf("can't mark withdrawal %s claimed: %v\n", w.WithdrawID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		notifier.Notify(core.Notification{
+			UserID: w.UserID,
+			Kind:   core.NOTIFY_SUCCESS,
+			Title:  "Withdrawal Claimed",
+			Message: fmt.Sprintf(
+				"Withdrawal %s... for %.9f IVY has been claimed on-chain",
+				w.WithdrawID[:8],
+				float64(w.AmountRaw)/constants.IVY_FACTOR,
+			),
+		})
+	}
+	return nil
+}
+
+func clawback(database db.Database, notifier core.Notifier, w db.Withdrawal) {
+	debtRaw, err := database.ClawbackWithdrawal(w.WithdrawID)
+	if err != nil {
+		log.Printf("can't claw back withdrawal %s: %v\n", w.WithdrawID, err)
+		return
+	}
+
+	message := fmt.Sprintf(
+		"Cancelled withdrawal %s... was claimed on-chain anyway, so its refund of %.9f IVY has been taken back from your balance",
+		w.WithdrawID[:8],
+		float64(w.AmountRaw)/constants.IVY_FACTOR,
+	)
+	if debtRaw > 0 {
+		log.Printf("clawback of withdrawal %s left user %s owing %d RAW\n", w.WithdrawID, w.UserID, debtRaw)
+		message += fmt.Sprintf(
+			". The refund was already spent, so you owe %.9f IVY: you can't send funds until deposits or tips pay it off",
+			float64(debtRaw)/constants.IVY_FACTOR,
+		)
+	}
+	notifier.Notify(core.Notification{
+		UserID:  w.UserID,
+		Kind:    core.NOTIFY_ERROR,
+		Message: message,
+	})
+}
+
+// decodeWithdrawals skips any withdrawal whose ID is malformed rather than
+// failing the batch
+func decodeWithdrawals(withdrawals []db.Withdrawal) ([]db.Withdrawal, [][32]byte) {
+	var valid []db.Withdrawal
+	var ids [][32]byte
+	for _, w := range withdrawals {
+		id, err := util.DecodeID(w.WithdrawID)
+		if err != nil {
+			log.Printf("skipping withdrawal %s: %v\n", w.WithdrawID, err)
+			continue
+		}
+		valid = append(valid, w)
+		ids = append(ids, id)
+	}
+	return valid, ids
+}